@@ -1,13 +1,18 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"slices"
 	"strings"
 	"time"
 
 	"rprj/be/db"
+	"rprj/be/dblayer"
+	"rprj/be/models"
 
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -30,11 +35,17 @@ type Credentials struct {
 }
 
 type TokenResponse struct {
-	AccessToken string   `json:"access_token"`
-	ExpiresAt   int64    `json:"expires_at"`
-	Groups      []string `json:"groups"`
+	AccessToken      string   `json:"access_token"`
+	ExpiresAt        int64    `json:"expires_at"`
+	RefreshToken     string   `json:"refresh_token"`
+	RefreshExpiresAt int64    `json:"refresh_expires_at"`
+	Groups           []string `json:"groups"`
 }
 
+// Durata dei token, configurabile all'avvio
+var AccessTokenDuration = 1 * time.Hour
+var RefreshTokenDuration = 30 * 24 * time.Hour
+
 func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	var creds Credentials
 	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
@@ -43,18 +54,78 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Verifica utente nel DB
-	user, err := db.GetUserByLogin(creds.Login)
-	if err != nil || user == nil || user.Pwd != creds.Pwd {
+	user, err := db.GetUserByLogin(r.Context(), creds.Login)
+	if err != nil || user == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	ok, needsRehash := dblayer.VerifyPassword(creds.Pwd, user.Pwd, user.PwdSalt)
+	if !ok {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
+	// Password in chiaro (o hash obsoleto): lo aggiorna, senza bloccare il login
+	if needsRehash {
+		if err := db.UpdateUserPassword(r.Context(), user.ID, creds.Pwd); err != nil {
+			log.Print("LoginHandler: rehash failed for user ", user.ID, ": ", err)
+		}
+	}
 
-	// Retrieve user groups
-	groups, err := db.GetUserGroupsByUserID(user.ID)
+	// Ogni login apre una nuova famiglia di token
+	familyID, err := db.NewTokenFamily()
 	if err != nil {
-		http.Error(w, "could not retrieve user groups", http.StatusInternalServerError)
+		http.Error(w, "could not generate token", http.StatusInternalServerError)
+		return
+	}
+	issueTokens(w, r, user, familyID)
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// POST /token/refresh
+func RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	userID, familyID, err := db.UseRefreshToken(r.Context(), req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, db.ErrRefreshTokenInvalid) || errors.Is(err, db.ErrRefreshTokenExpired) || errors.Is(err, db.ErrRefreshTokenReused) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		} else {
+			http.Error(w, "could not refresh token", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Login e gruppi possono essere cambiati dall'ultimo token
+	user, err := db.GetUserByID(r.Context(), userID)
+	if err != nil || user == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
+	issueTokens(w, r, user, familyID)
+}
+
+// POST /logout
+func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	if err := db.DeleteToken(r.Context(), bearerToken(r)); err != nil {
+		http.Error(w, "could not delete token", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Returns the groups of the user, primary group included
+func userGroupList(ctx context.Context, user *models.DBUser) ([]string, error) {
+	groups, err := db.GetUserGroupsByUserID(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
 	group_list := []string{}
 	for _, g := range groups {
 		group_list = append(group_list, g.GroupID)
@@ -62,14 +133,31 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	if user.GroupID != "" && slices.Index(group_list, user.GroupID) < 0 {
 		group_list = append(group_list, user.GroupID)
 	}
+	return group_list, nil
+}
 
-	// Genera JWT
-	expiration := time.Now().Add(1 * time.Hour)
+// Generates and saves a new access/refresh token pair, and writes it to the client
+func issueTokens(w http.ResponseWriter, r *http.Request, user *models.DBUser, familyID string) {
+	// Retrieve user groups
+	group_list, err := userGroupList(r.Context(), user)
+	if err != nil {
+		http.Error(w, "could not retrieve user groups", http.StatusInternalServerError)
+		return
+	}
+
+	// Genera JWT: jti lo rende unico anche se emesso nello stesso secondo
+	jti, err := db.NewRefreshToken()
+	if err != nil {
+		http.Error(w, "could not generate token", http.StatusInternalServerError)
+		return
+	}
+	expiration := time.Now().Add(AccessTokenDuration)
 	claims := &jwt.MapClaims{
 		"user_id": user.ID,
 		"login":   user.Login,
 		"groups":  strings.Join(group_list, ","),
 		"exp":     expiration.Unix(),
+		"jti":     jti[:16],
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString(JWTKey)
@@ -78,17 +166,26 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	refreshToken, err := db.NewRefreshToken()
+	if err != nil {
+		http.Error(w, "could not generate token", http.StatusInternalServerError)
+		return
+	}
+	refreshExpiration := time.Now().Add(RefreshTokenDuration)
+
 	// Salva token in tabella oauth_tokens
-	if err := db.SaveToken(user.ID, tokenString, expiration.Unix()); err != nil {
+	if err := db.SaveTokenPair(r.Context(), user.ID, tokenString, expiration.Unix(), refreshToken, refreshExpiration.Unix(), familyID); err != nil {
 		http.Error(w, "could not save token", http.StatusInternalServerError)
 		return
 	}
 
 	// Risposta al client
 	resp := TokenResponse{
-		AccessToken: tokenString,
-		ExpiresAt:   expiration.Unix(),
-		Groups:      group_list,
+		AccessToken:      tokenString,
+		ExpiresAt:        expiration.Unix(),
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: refreshExpiration.Unix(),
+		Groups:           group_list,
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
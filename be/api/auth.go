@@ -6,62 +6,109 @@ import (
 	"strings"
 
 	"rprj/be/db"
+	"rprj/be/dblayer"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// Middleware che controlla il token JWT
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Legge l'header Authorization
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "missing Authorization header", http.StatusUnauthorized)
-			return
-		}
+// Returns the DBContext of the authenticated user, nil if not authenticated.
+// db.NewDBRepository(r.Context()) uses the same context.
+func GetDBContext(r *http.Request) *dblayer.DBContext {
+	return dblayer.FromContext(r.Context())
+}
 
-		// Deve essere nel formato "Bearer <token>"
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			http.Error(w, "invalid Authorization header", http.StatusUnauthorized)
-			return
-		}
-		tokenString := parts[1]
-		log.Printf("Token ricevuto: %s\n", tokenString)
+// Returns the token of the Authorization header, "" if missing
+func bearerToken(r *http.Request) string {
+	parts := strings.Split(r.Header.Get("Authorization"), " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return ""
+	}
+	return parts[1]
+}
 
-		// Valida il token
-		claims := jwt.MapClaims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
-			return JWTKey, nil
-		})
-		log.Printf("Claims: %+v\n", claims)
-		log.Printf("err: %v\n", err)
-		if err != nil || !token.Valid {
-			http.Error(w, "invalid token", http.StatusUnauthorized)
-			log.Print("Deleting token from db due to invalidity.")
-			db.DeleteToken(tokenString)
-			return
-		}
+/*
+Validates the bearer token of the request and returns the DBContext of its user.
+On failure returns nil and the message for the 401 response.
+*/
+func authenticate(r *http.Request) (*dblayer.DBContext, string) {
+	// Legge l'header Authorization
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return nil, "missing Authorization header"
+	}
 
-		// Retrieve user ID from claims
-		userID := claims["user_id"].(string)
-		log.Printf("User ID autenticato: %s\n", userID)
+	// Deve essere nel formato "Bearer <token>"
+	tokenString := bearerToken(r)
+	if tokenString == "" {
+		return nil, "invalid Authorization header"
+	}
 
-		// Retrieve group ids from claims
-		groupIDs := []string{}
-		if g, ok := claims["groups"].(string); ok && g != "" {
-			groupIDs = strings.Split(g, ",")
-		}
-		log.Printf("Group IDs: %+v\n", groupIDs)
+	// Valida il token
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return JWTKey, nil
+	})
+	if err != nil || !token.Valid {
+		log.Printf("AuthMiddleware: invalid token: %v\n", err)
+		// Il token non viene cancellato: la riga contiene anche il refresh token,
+		// le righe scadute vengono eliminate da db.StartTokenSweeper
+		return nil, "invalid token"
+	}
 
-		// Search the token in the database to ensure it's valid
-		if !db.IsTokenValid(tokenString, userID) {
-			http.Error(w, "token not recognized", http.StatusUnauthorized)
-			log.Print("Token not found in the database")
+	// Retrieve user ID from claims
+	userID, ok := claims["user_id"].(string)
+	if !ok || userID == "" {
+		return nil, "invalid token"
+	}
+	log.Printf("User ID autenticato: %s\n", userID)
+
+	// Retrieve group ids from claims
+	groupIDs := []string{}
+	if g, ok := claims["groups"].(string); ok && g != "" {
+		groupIDs = strings.Split(g, ",")
+	}
+	log.Printf("Group IDs: %+v\n", groupIDs)
+
+	// Search the token in the database to ensure it's valid
+	if !db.IsTokenValid(r.Context(), tokenString, userID) {
+		log.Print("Token not found in the database")
+		return nil, "token not recognized"
+	}
+	db.TouchToken(r.Context(), tokenString)
+
+	return db.NewDBContext(userID, groupIDs), ""
+}
+
+// Middleware che controlla il token JWT
+func AuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dbContext, message := authenticate(r)
+		if dbContext == nil {
+			http.Error(w, message, http.StatusUnauthorized)
 			return
 		}
 
-		// Passa la richiesta all'handler successivo
-		next.ServeHTTP(w, r)
+		// Passa la richiesta all'handler successivo, con l'utente autenticato
+		next.ServeHTTP(w, r.WithContext(dblayer.NewContext(r.Context(), dbContext)))
+	})
+}
+
+/*
+Like AuthMiddleware, but without the Authorization header the request goes on
+as the anonymous user: the repository returns only the public objects,
+and the rules of Authorize (but Public) reject it with 401.
+An invalid token is still rejected.
+*/
+func OptionalAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dbContext := db.NewDBContext("", []string{})
+		if r.Header.Get("Authorization") != "" {
+			var message string
+			if dbContext, message = authenticate(r); dbContext == nil {
+				http.Error(w, message, http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(dblayer.NewContext(r.Context(), dbContext)))
 	})
 }
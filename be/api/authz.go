@@ -0,0 +1,113 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"rprj/be/db"
+	"rprj/be/dblayer"
+
+	"github.com/gorilla/mux"
+)
+
+/*
+The system groups (see db.Migrations) are the roles of the users
+*/
+const (
+	RoleAdmin     = dblayer.AdminGroupID
+	RoleUsers     = "-3"
+	RoleGuests    = "-4"
+	RoleProject   = "-5"
+	RoleWebmaster = "-6"
+)
+
+/*
+An authorization rule: true if the user of dbContext can serve the request.
+dbContext is never nil: the rules are applied after AuthMiddleware (or OptionalAuthMiddleware:
+the user can be anonymous, see dblayer.DBContext.IsAnonymous).
+*/
+type Rule func(r *http.Request, dbContext *dblayer.DBContext) bool
+
+/*
+Wraps the handler with the rule, ie. in main.go:
+
+	userRoutes.Handle("/{id}", api.Authorize(api.AdminOrSelf, api.UpdateUserHandler)).Methods("PUT")
+*/
+func Authorize(rule Rule, handler http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dbContext := GetDBContext(r)
+		if dbContext == nil {
+			writeJSONError(w, http.StatusUnauthorized, "Authentication required")
+			return
+		}
+		if !rule(r, dbContext) {
+			if dbContext.IsAnonymous() {
+				writeJSONError(w, http.StatusUnauthorized, "Authentication required")
+				return
+			}
+			log.Printf("Authorize: user %s denied %s %s", dbContext.UserID, r.Method, r.URL.Path)
+			writeJSONError(w, http.StatusForbidden, "Permission denied")
+			return
+		}
+		handler(w, r)
+	})
+}
+
+// Anybody, also the anonymous user
+func Public(r *http.Request, dbContext *dblayer.DBContext) bool {
+	return true
+}
+
+// Any authenticated user
+func Authenticated(r *http.Request, dbContext *dblayer.DBContext) bool {
+	return !dbContext.IsAnonymous()
+}
+
+// Returns a rule granting access to the members of any of the groups
+func HasRole(groupIDs ...string) Rule {
+	return func(r *http.Request, dbContext *dblayer.DBContext) bool {
+		for _, groupID := range groupIDs {
+			if dbContext.IsInGroup(groupID) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Returns a rule granting access if any of the rules does
+func AnyOf(rules ...Rule) Rule {
+	return func(r *http.Request, dbContext *dblayer.DBContext) bool {
+		for _, rule := range rules {
+			if rule(r, dbContext) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+var AdminOnly = HasRole(RoleAdmin)
+
+// The user in the {id} of the path is the caller
+func Self(r *http.Request, dbContext *dblayer.DBContext) bool {
+	return !dbContext.IsAnonymous() && dbContext.IsUser(mux.Vars(r)["id"])
+}
+
+// The group in the {id} of the path is the primary group of the caller
+func PrimaryGroup(r *http.Request, dbContext *dblayer.DBContext) bool {
+	user, err := db.GetUserByID(r.Context(), dbContext.UserID)
+	if err != nil || user == nil {
+		return false
+	}
+	return user.GroupID != "" && user.GroupID == mux.Vars(r)["id"]
+}
+
+var AdminOrSelf = AnyOf(AdminOnly, Self)
+var AdminOrPrimaryGroup = AnyOf(AdminOnly, PrimaryGroup)
+
+// True if the caller is an administrator
+func IsAdmin(r *http.Request) bool {
+	dbContext := GetDBContext(r)
+	return dbContext != nil && dbContext.IsInGroup(RoleAdmin)
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"rprj/be/dblayer"
+
+	"github.com/gorilla/mux"
+)
+
+func TestAuthorizeRules(t *testing.T) {
+	okHandler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+	admin := &dblayer.DBContext{UserID: "-1", GroupIDs: []string{RoleAdmin}}
+	user := &dblayer.DBContext{UserID: "u1", GroupIDs: []string{"g1", RoleUsers}}
+	anonymous := &dblayer.DBContext{GroupIDs: []string{}}
+
+	cases := []struct {
+		name      string
+		rule      Rule
+		dbContext *dblayer.DBContext
+		id        string
+		expected  int
+	}{
+		{"no context", Authenticated, nil, "u1", http.StatusUnauthorized},
+		{"anonymous", Authenticated, anonymous, "u1", http.StatusUnauthorized},
+		{"anonymous, public", Public, anonymous, "u1", http.StatusOK},
+		{"anonymous, self", AdminOrSelf, anonymous, "", http.StatusUnauthorized},
+		{"admin only, admin", AdminOnly, admin, "u1", http.StatusOK},
+		{"admin only, user", AdminOnly, user, "u1", http.StatusForbidden},
+		{"self, own row", AdminOrSelf, user, "u1", http.StatusOK},
+		{"self, other row", AdminOrSelf, user, "u2", http.StatusForbidden},
+		{"self, admin", AdminOrSelf, admin, "u2", http.StatusOK},
+		{"role", HasRole(RoleWebmaster, RoleUsers), user, "", http.StatusOK},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodPut, "/users/"+c.id, nil)
+		req = mux.SetURLVars(req, map[string]string{"id": c.id})
+		if c.dbContext != nil {
+			req = req.WithContext(dblayer.NewContext(req.Context(), c.dbContext))
+		}
+		rr := httptest.NewRecorder()
+		Authorize(c.rule, okHandler).ServeHTTP(rr, req)
+		if rr.Code != c.expected {
+			t.Errorf("%s: got status %d, want %d", c.name, rr.Code, c.expected)
+		}
+	}
+}
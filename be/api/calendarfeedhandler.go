@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+
+	"rprj/be/db"
+
+	"github.com/gorilla/mux"
+)
+
+// GET /users/{id}/calendar_feeds
+// Only the user and the administrators: see AdminOrSelf in main.go
+func GetCalendarFeedsHandler(w http.ResponseWriter, r *http.Request) {
+	feeds, err := db.GetCalendarFeeds(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(feeds)
+}
+
+/*
+POST /users/{id}/calendar_feeds {"name": "Phone"}
+The token and the path of the feed are returned only here: the scope of the feed
+(user=, group=, folder=) can be added to the path, see CalendarICSHandler.
+Only the user can create their feeds, not the admins.
+*/
+func CreateCalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+	if len(req.Name) > 255 {
+		writeJSONError(w, http.StatusBadRequest, "name: value too long")
+		return
+	}
+
+	feed, err := db.CreateCalendarFeed(r.Context(), mux.Vars(r)["id"], req.Name)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to create calendar feed: "+err.Error())
+		return
+	}
+	feed.Path = "/calendar.ics?token=" + url.QueryEscape(feed.Token)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(feed)
+}
+
+// DELETE /users/{id}/calendar_feeds/{feed_id}
+func DeleteCalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	found, err := db.DeleteCalendarFeed(r.Context(), vars["id"], vars["feed_id"])
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to revoke calendar feed: "+err.Error())
+		return
+	}
+	if !found {
+		writeJSONError(w, http.StatusNotFound, "Calendar feed not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
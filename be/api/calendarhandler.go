@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"rprj/be/calendar"
+	"rprj/be/db"
+	"rprj/be/dblayer"
+)
+
+// Maximum width of the window of GET /calendar
+var MaxCalendarDays = 400
+
+// An occurrence of a DBEvent
+type CalendarOccurrence struct {
+	EventID   string `json:"event_id"`
+	Name      string `json:"name"`
+	Category  string `json:"category"`
+	AllDay    bool   `json:"all_day"`
+	Start     string `json:"start"`
+	End       string `json:"end"` // exclusive: for the all day events the day after the last one
+	Recurring bool   `json:"recurring"`
+	Index     int    `json:"index"` // 1 for the first occurrence of the event
+}
+
+// Parses a date or datetime of the query string, in the local time
+func parseCalendarTime(value string) (time.Time, bool) {
+	for _, layout := range []string{dblayer.DateFormat, dblayer.DateTimeFormat, "2006-01-02T15:04:05", time.RFC3339} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Parses from and to: dates (to is exclusive) or datetimes
+func parseCalendarWindow(w http.ResponseWriter, r *http.Request) (time.Time, time.Time, bool) {
+	from, okFrom := parseCalendarTime(r.URL.Query().Get("from"))
+	to, okTo := parseCalendarTime(r.URL.Query().Get("to"))
+	if !okFrom || !okTo {
+		writeJSONError(w, http.StatusBadRequest, "from and to are required, ie. from=2025-03-01&to=2025-04-01")
+		return from, to, false
+	}
+	if !from.Before(to) {
+		writeJSONError(w, http.StatusBadRequest, "from must be before to")
+		return from, to, false
+	}
+	if to.Sub(from) > time.Duration(MaxCalendarDays)*24*time.Hour {
+		writeJSONError(w, http.StatusBadRequest, "The window is too large")
+		return from, to, false
+	}
+	return from, to, true
+}
+
+/*
+Returns the readable DBEvents that can have occurrences in [from, to):
+starting before to, and not ended before from unless recurring
+*/
+func searchCalendarEvents(repo *dblayer.DBRepository, from time.Time, to time.Time) ([]dblayer.DBEntityInterface, error) {
+	// The all day events end at the end of their end_date
+	dayBefore := from.AddDate(0, 0, -1)
+	return repo.Search(dblayer.NewDBEvent(), false, false, "start_date",
+		dblayer.Lt("start_date", to),
+		dblayer.Or(
+			dblayer.Gte("start_date", dayBefore),
+			dblayer.Gte("end_date", dayBefore),
+			dblayer.Eq("recurrence", "1"),
+		),
+	)
+}
+
+func formatOccurrenceTime(t time.Time, allDay bool) string {
+	if allDay {
+		return t.Format(dblayer.DateFormat)
+	}
+	return t.Format(dblayer.DateTimeFormat)
+}
+
+/*
+Expands the events into their occurrences in [from, to), sorted by start
+*/
+func expandEvents(events []dblayer.DBEntityInterface, from time.Time, to time.Time) []CalendarOccurrence {
+	ret := make([]CalendarOccurrence, 0)
+	for _, dbe := range events {
+		ev := calendar.EventFromDB(dbe)
+		for _, o := range calendar.Expand(ev, from, to) {
+			ret = append(ret, CalendarOccurrence{
+				EventID:   dbe.GetValue("id"),
+				Name:      dbe.GetValue("name"),
+				Category:  dbe.GetValue("category"),
+				AllDay:    ev.AllDay,
+				Start:     formatOccurrenceTime(o.Start, ev.AllDay),
+				End:       formatOccurrenceTime(o.End, ev.AllDay),
+				Recurring: ev.Rule.Frequency != calendar.None,
+				Index:     o.Index,
+			})
+		}
+	}
+	// The all day events first, in the same day
+	sort.SliceStable(ret, func(i, j int) bool {
+		if ret[i].Start[:10] != ret[j].Start[:10] {
+			return ret[i].Start < ret[j].Start
+		}
+		if ret[i].AllDay != ret[j].AllDay {
+			return ret[i].AllDay
+		}
+		return ret[i].Start < ret[j].Start
+	})
+	return ret
+}
+
+// GET /calendar?from=2025-03-01&to=2025-04-01
+func CalendarHandler(w http.ResponseWriter, r *http.Request) {
+	from, to, ok := parseCalendarWindow(w, r)
+	if !ok {
+		return
+	}
+
+	repo := db.NewDBRepository(r.Context())
+	events, err := searchCalendarEvents(repo, from, to)
+	if err != nil {
+		writeJSONError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"from":  formatOccurrenceTime(from, false),
+		"to":    formatOccurrenceTime(to, false),
+		"items": expandEvents(events, from, to),
+	})
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"rprj/be/dblayer"
+)
+
+func TestParseCalendarWindow(t *testing.T) {
+	cases := []struct {
+		query string
+		ok    bool
+	}{
+		{"from=2025-03-01&to=2025-04-01", true},
+		{"from=2025-03-01 10:00:00&to=2025-03-01T12:00:00", true},
+		{"from=2025-03-01", false},
+		{"from=2025-04-01&to=2025-03-01", false},
+		{"from=2025-01-01&to=2027-01-01", false},
+		{"from=yesterday&to=2025-03-01", false},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/calendar", nil)
+		req.URL.RawQuery = c.query
+		rr := httptest.NewRecorder()
+		if _, _, ok := parseCalendarWindow(rr, req); ok != c.ok {
+			t.Errorf("%s: got %v, want %v", c.query, ok, c.ok)
+		}
+		if !c.ok && rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: got status %d", c.query, rr.Code)
+		}
+	}
+}
+
+func TestExpandEvents(t *testing.T) {
+	meeting := dblayer.NewDBEvent()
+	meeting.SetValue("id", "meeting")
+	meeting.SetValue("name", "Weekly meeting")
+	meeting.SetTime("start_date", time.Date(2025, 3, 3, 9, 0, 0, 0, time.Local))
+	meeting.SetTime("end_date", time.Date(2025, 3, 3, 10, 0, 0, 0, time.Local))
+	meeting.SetValue("all_day", "0")
+	meeting.SetValue("recurrence", "1")
+	meeting.SetValue("recurrence_type", "2")
+	meeting.SetInt("weekly_every_x", 1)
+
+	holiday := dblayer.NewDBEvent()
+	holiday.SetValue("id", "holiday")
+	holiday.SetValue("name", "Holiday")
+	holiday.SetTime("start_date", time.Date(2025, 3, 10, 0, 0, 0, 0, time.Local))
+	holiday.SetTime("end_date", time.Date(2025, 3, 10, 0, 0, 0, 0, time.Local))
+	holiday.SetValue("all_day", "1")
+
+	from := time.Date(2025, 3, 8, 0, 0, 0, 0, time.Local)
+	to := time.Date(2025, 3, 18, 0, 0, 0, 0, time.Local)
+	items := expandEvents([]dblayer.DBEntityInterface{meeting, holiday}, from, to)
+	expected := []struct{ id, start, end string }{
+		{"holiday", "2025-03-10", "2025-03-11"},
+		{"meeting", "2025-03-10 09:00:00", "2025-03-10 10:00:00"},
+		{"meeting", "2025-03-17 09:00:00", "2025-03-17 10:00:00"},
+	}
+	if len(items) != len(expected) {
+		t.Fatalf("got %+v", items)
+	}
+	for i, e := range expected {
+		if items[i].EventID != e.id || items[i].Start != e.start || items[i].End != e.end {
+			t.Errorf("%d: got %+v, want %+v", i, items[i], e)
+		}
+	}
+	if !items[1].Recurring || items[1].Index != 2 || items[0].Recurring {
+		t.Errorf("unexpected %+v", items)
+	}
+}
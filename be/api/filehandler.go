@@ -0,0 +1,435 @@
+package api
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"rprj/be/db"
+	"rprj/be/dblayer"
+	"rprj/be/storage"
+	"rprj/be/thumbnail"
+
+	"github.com/gorilla/mux"
+)
+
+// Storage of the contents of the DBFiles, set by main
+var FileStorage storage.Storage
+
+// Maximum size of an upload request
+var MaxUploadSize int64 = 100 << 20
+
+// Maximum size of the other fields of the upload form
+const maxFormValueSize = 64 << 10
+
+// The sniffed types that say little: the extension of the file is more precise, ie. zip for docx
+var genericMimeTypes = map[string]bool{
+	"application/octet-stream":  true,
+	"application/zip":           true,
+	"text/plain; charset=utf-8": true,
+}
+
+/*
+Returns the MIME type of the content from its first bytes (see http.DetectContentType),
+or from the extension of the filename when the content is not recognized.
+The Content-Type sent by the client is not trusted.
+*/
+func detectMime(filename string, head []byte) string {
+	sniffed := http.DetectContentType(head)
+	if genericMimeTypes[sniffed] {
+		if byExtension := mime.TypeByExtension(strings.ToLower(filepath.Ext(filename))); byExtension != "" {
+			return byExtension
+		}
+	}
+	return sniffed
+}
+
+// Keeps the first bytes written, for detectMime
+type sniffBuffer struct {
+	head []byte
+}
+
+func (sb *sniffBuffer) Write(p []byte) (int, error) {
+	if missing := 512 - len(sb.head); missing > 0 {
+		if missing > len(p) {
+			missing = len(p)
+		}
+		sb.head = append(sb.head, p[:missing]...)
+	}
+	return len(p), nil
+}
+
+// A new key for the content being uploaded: "tmp/3f2a9c1b7e4d5a60"
+func newUploadKey() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return storage.TmpPrefix + hex.EncodeToString(b), nil
+}
+
+type storedContent struct {
+	Key      string
+	Filename string
+	Checksum string
+	Mime     string
+	Size     int64
+}
+
+/*
+Streams the content of the part to FileStorage, computing its SHA-1 and MIME type,
+then moves it to its blob (see storage.BlobKey): an identical content already stored
+is replaced by the new copy, so the files share the same blob.
+*/
+func storeContent(part *multipart.Part) (*storedContent, error) {
+	filename := part.FileName()
+	if filename == "" || filename == "." || filename == "/" {
+		return nil, &dblayer.ValidationError{Column: "file", Message: "missing file name"}
+	}
+	uploadKey, err := newUploadKey()
+	if err != nil {
+		return nil, err
+	}
+	hash := sha1.New()
+	sniffer := &sniffBuffer{}
+	size, err := FileStorage.Put(uploadKey, io.TeeReader(part, io.MultiWriter(hash, sniffer)))
+	if err != nil {
+		return nil, err
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	// The move also refreshes the date of an existing blob: the gc keeps the recent blobs
+	if err := FileStorage.Move(uploadKey, storage.BlobKey(checksum)); err != nil {
+		if deleteErr := FileStorage.Delete(uploadKey); deleteErr != nil {
+			log.Print("storeContent: cannot delete ", uploadKey, ": ", deleteErr)
+		}
+		return nil, err
+	}
+	return &storedContent{
+		Key:      storage.BlobKey(checksum),
+		Filename: filename,
+		Checksum: checksum,
+		Mime:     detectMime(filename, sniffer.head),
+		Size:     size,
+	}, nil
+}
+
+// Writes the error of reading the upload request
+func writeUploadError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Upload larger than %d MB", tooLarge.Limit>>20))
+		return
+	}
+	var validationError *dblayer.ValidationError
+	if errors.As(err, &validationError) {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	log.Print("UploadFileHandler: ", err)
+	writeJSONError(w, http.StatusBadRequest, "Invalid upload")
+}
+
+/*
+POST /files (multipart/form-data)
+
+The part "file" is the content, streamed to FileStorage; the other parts are the values
+of the DBFile (name, description, father_id, fk_obj_id, permissions...).
+The name defaults to the filename.
+*/
+func UploadFileHandler(w http.ResponseWriter, r *http.Request) {
+	if FileStorage == nil {
+		writeJSONError(w, http.StatusInternalServerError, "File storage not configured")
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, MaxUploadSize)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Expected a multipart/form-data request")
+		return
+	}
+
+	// The blob stored for a DBFile not created is removed by the gc, it can be shared
+	values := make(map[string]any)
+	var content *storedContent
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			writeUploadError(w, err)
+			return
+		}
+		switch {
+		case part.FormName() == "file":
+			if content != nil {
+				writeJSONError(w, http.StatusBadRequest, "Only one file per request")
+				return
+			}
+			if content, err = storeContent(part); err != nil {
+				writeUploadError(w, err)
+				return
+			}
+		case part.FormName() != "":
+			value, err := io.ReadAll(io.LimitReader(part, maxFormValueSize+1))
+			if err != nil {
+				writeUploadError(w, err)
+				return
+			}
+			if len(value) > maxFormValueSize {
+				writeJSONError(w, http.StatusBadRequest, part.FormName()+": value too long")
+				return
+			}
+			values[part.FormName()] = string(value)
+		}
+		part.Close()
+	}
+	if content == nil {
+		writeJSONError(w, http.StatusBadRequest, "Missing file")
+		return
+	}
+
+	file := dblayer.NewDBFile()
+	delete(values, "id")
+	if err := dblayer.SetValuesFromMap(file, values); err != nil {
+		writeJSONError(w, errorStatus(err), err.Error())
+		return
+	}
+	if file.GetValue("filename") == "" {
+		file.SetValue("filename", content.Filename)
+	}
+	if file.GetValue("name") == "" {
+		file.SetValue("name", file.GetValue("filename"))
+	}
+	file.SetValue("path", content.Key)
+	file.SetValue("checksum", content.Checksum)
+	file.SetValue("mime", content.Mime)
+
+	repo := db.NewDBRepository(r.Context())
+	if _, err := repo.Insert(file); err != nil {
+		writeJSONError(w, errorStatus(err), "Failed to create DBFile: "+err.Error())
+		return
+	}
+	log.Printf("UploadFileHandler: %s stored as %s, %d bytes, %s", file.GetValue("id"), content.Key, content.Size, content.Mime)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(file)
+}
+
+/*
+Returns the DBFile {id} of the path, nil if it does not exist or the user cannot read it
+*/
+func loadFile(r *http.Request) (*dblayer.DBFile, error) {
+	criteria := dblayer.NewDBFile()
+	criteria.SetValue("id", mux.Vars(r)["id"])
+	repo := db.NewDBRepository(r.Context())
+	results, err := repo.Search(criteria, false, false, "")
+	if err != nil || len(results) == 0 {
+		return nil, err
+	}
+	file, _ := results[0].(*dblayer.DBFile)
+	return file, nil
+}
+
+/*
+GET /files/{id}/content[?download=1]
+
+Serves the content with its MIME type, inline or as attachment with download.
+Range and conditional requests are handled by http.ServeContent: the ETag is the checksum.
+*/
+func FileContentHandler(w http.ResponseWriter, r *http.Request) {
+	if FileStorage == nil {
+		writeJSONError(w, http.StatusInternalServerError, "File storage not configured")
+		return
+	}
+	file, err := loadFile(r)
+	if err != nil {
+		writeJSONError(w, errorStatus(err), err.Error())
+		return
+	}
+	if file == nil {
+		writeJSONError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	if file.GetValue("path") == "" {
+		writeJSONError(w, http.StatusNotFound, "The file has no content")
+		return
+	}
+	content, err := FileStorage.Open(file.GetValue("path"))
+	if errors.Is(err, storage.ErrNotFound) {
+		log.Print("FileContentHandler: missing content ", file.GetValue("path"), " of ", file.GetValue("id"))
+		writeJSONError(w, http.StatusNotFound, "The file has no content")
+		return
+	}
+	if err != nil {
+		log.Print("FileContentHandler: ", err)
+		writeJSONError(w, http.StatusInternalServerError, "Cannot read the content")
+		return
+	}
+	defer content.Close()
+
+	contentType := file.GetValue("mime")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	disposition := "inline"
+	if r.URL.Query().Get("download") != "" {
+		disposition = "attachment"
+	}
+	if header := mime.FormatMediaType(disposition, map[string]string{"filename": file.GetValue("filename")}); header != "" {
+		w.Header().Set("Content-Disposition", header)
+	} else {
+		w.Header().Set("Content-Disposition", disposition)
+	}
+	if checksum := file.GetValue("checksum"); checksum != "" {
+		w.Header().Set("ETag", `"`+checksum+`"`)
+	}
+	w.Header().Set("Content-Type", contentType)
+	// The uploaded contents must not run in the pages of the application
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	// Cached by the browser, but revalidated: the permissions can change
+	w.Header().Set("Cache-Control", "private, no-cache")
+	http.ServeContent(w, r, file.GetValue("filename"), content.ModTime(), content)
+}
+
+// Widths of the thumbnails, ascending: the requested width is rounded up to one of these
+var ThumbnailWidths = []int{64, 200, 800}
+
+const defaultThumbnailWidth = 200
+
+// At most 4 thumbnails are made at the same time: the decoded images take a lot of memory
+var thumbnailSlots = make(chan struct{}, 4)
+
+/*
+Returns the smallest of ThumbnailWidths not smaller than width, the largest if none
+*/
+func thumbnailWidth(width int) int {
+	for _, w := range ThumbnailWidths {
+		if w >= width {
+			return w
+		}
+	}
+	return ThumbnailWidths[len(ThumbnailWidths)-1]
+}
+
+/*
+The thumbnails are cached in FileStorage by checksum of the image:
+a new content has a new checksum, so the thumbnails of the old one are never served.
+*/
+func thumbnailKey(checksum string, width int) string {
+	return fmt.Sprintf("thumbnails/%s/%d", checksum, width)
+}
+
+/*
+Makes the thumbnail of the content of the file and caches it (if the file has a checksum)
+*/
+func makeThumbnail(file *dblayer.DBFile, width int) ([]byte, error) {
+	thumbnailSlots <- struct{}{}
+	defer func() { <-thumbnailSlots }()
+
+	content, err := FileStorage.Open(file.GetValue("path"))
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+	var buf bytes.Buffer
+	if err := thumbnail.Generate(content, file.GetValue("mime"), width, &buf); err != nil {
+		return nil, err
+	}
+	if checksum := file.GetValue("checksum"); checksum != "" {
+		if _, err := FileStorage.Put(thumbnailKey(checksum, width), bytes.NewReader(buf.Bytes())); err != nil {
+			// Served anyway, the next request will try again
+			log.Print("FileThumbnailHandler: cannot cache ", thumbnailKey(checksum, width), ": ", err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+/*
+GET /files/{id}/thumbnail?w=200
+
+The thumbnail of an image (jpeg, png, gif, webp), made on the first request and then cached.
+The width is rounded up to one of ThumbnailWidths; the images are never enlarged.
+*/
+func FileThumbnailHandler(w http.ResponseWriter, r *http.Request) {
+	if FileStorage == nil {
+		writeJSONError(w, http.StatusInternalServerError, "File storage not configured")
+		return
+	}
+	width := defaultThumbnailWidth
+	if value := r.URL.Query().Get("w"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "Invalid w")
+			return
+		}
+		width = n
+	}
+	width = thumbnailWidth(width)
+
+	file, err := loadFile(r)
+	if err != nil {
+		writeJSONError(w, errorStatus(err), err.Error())
+		return
+	}
+	if file == nil {
+		writeJSONError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	if file.GetValue("path") == "" {
+		writeJSONError(w, http.StatusNotFound, "The file has no content")
+		return
+	}
+	if !thumbnail.Supported(file.GetValue("mime")) {
+		writeJSONError(w, http.StatusUnsupportedMediaType, "Not an image")
+		return
+	}
+
+	checksum := file.GetValue("checksum")
+	if checksum != "" {
+		w.Header().Set("ETag", fmt.Sprintf(`"%s-%d"`, checksum, width))
+	}
+	w.Header().Set("Content-Type", thumbnail.ContentType(file.GetValue("mime")))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, no-cache")
+
+	if checksum != "" {
+		cached, err := FileStorage.Open(thumbnailKey(checksum, width))
+		if err == nil {
+			defer cached.Close()
+			http.ServeContent(w, r, "", cached.ModTime(), cached)
+			return
+		}
+		if !errors.Is(err, storage.ErrNotFound) {
+			log.Print("FileThumbnailHandler: ", err)
+		}
+	}
+
+	data, err := makeThumbnail(file, width)
+	if errors.Is(err, storage.ErrNotFound) {
+		writeJSONError(w, http.StatusNotFound, "The file has no content")
+		return
+	}
+	if err != nil {
+		log.Print("FileThumbnailHandler: ", file.GetValue("id"), ": ", err)
+		writeJSONError(w, http.StatusUnprocessableEntity, "Cannot make the thumbnail")
+		return
+	}
+	http.ServeContent(w, r, "", time.Now(), bytes.NewReader(data))
+}
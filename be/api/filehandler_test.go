@@ -0,0 +1,106 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"strings"
+	"testing"
+
+	"rprj/be/storage"
+)
+
+func TestDetectMime(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	cases := []struct {
+		filename string
+		head     []byte
+		expected string
+	}{
+		{"image.png", png, "image/png"},
+		// The content wins over the extension
+		{"image.pdf", png, "image/png"},
+		{"contract.pdf", []byte("%PDF-1.7\n"), "application/pdf"},
+		// Generic contents use the extension
+		{"data.json", []byte(`{"a": 1}`), "application/json"},
+		{"notes", []byte("hello"), "text/plain; charset=utf-8"},
+		{"blob.unknownext", []byte{0, 1, 2, 3}, "application/octet-stream"},
+	}
+	for _, c := range cases {
+		if got := detectMime(c.filename, c.head); got != c.expected {
+			t.Errorf("%s: got %q, want %q", c.filename, got, c.expected)
+		}
+	}
+}
+
+// A multipart part "file" with the given content
+func filePart(t *testing.T, filename string, content string) *multipart.Part {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("file", filename)
+	fw.Write([]byte(content))
+	mw.Close()
+
+	part, err := multipart.NewReader(&body, mw.Boundary()).NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return part
+}
+
+func TestStoreContent(t *testing.T) {
+	FileStorage = storage.NewLocalStorage(t.TempDir())
+	defer func() { FileStorage = nil }()
+
+	content, err := storeContent(filePart(t, "hello.txt", strings.Repeat("hello world\n", 100)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content.Filename != "hello.txt" || content.Size != 1200 || !strings.HasPrefix(content.Mime, "text/plain") {
+		t.Errorf("unexpected %+v", content)
+	}
+	// sha1sum of the content
+	if content.Checksum != "fa0ada1f50a592836f8cf21eeea3d9fee08b3317" {
+		t.Errorf("checksum: got %s", content.Checksum)
+	}
+	if content.Key != storage.BlobKey(content.Checksum) {
+		t.Errorf("key: got %s", content.Key)
+	}
+
+	stored, err := FileStorage.Open(content.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stored.Close()
+	b, _ := io.ReadAll(stored)
+	if len(b) != 1200 {
+		t.Errorf("stored %d bytes", len(b))
+	}
+
+	// The same content with another name is stored once
+	again, err := storeContent(filePart(t, "copy.txt", strings.Repeat("hello world\n", 100)))
+	if err != nil || again.Key != content.Key {
+		t.Fatalf("got %+v, %v", again, err)
+	}
+	keys := []string{}
+	FileStorage.Walk("", func(info storage.Info) error {
+		keys = append(keys, info.Key)
+		return nil
+	})
+	if len(keys) != 1 {
+		t.Errorf("expected a single blob, got %v", keys)
+	}
+}
+
+func TestThumbnailWidth(t *testing.T) {
+	cases := map[int]int{1: 64, 64: 64, 65: 200, 200: 200, 201: 800, 5000: 800}
+	for requested, expected := range cases {
+		if got := thumbnailWidth(requested); got != expected {
+			t.Errorf("thumbnailWidth(%d) = %d, want %d", requested, got, expected)
+		}
+	}
+	// A new content has new thumbnails
+	if thumbnailKey("aaaa", 200) == thumbnailKey("bbbb", 200) {
+		t.Error("the thumbnails must be cached by checksum")
+	}
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"rprj/be/dblayer"
+)
+
+// "or1.start_date[gte]": optional OR group, column and optional operator
+var filterParamRegexp = regexp.MustCompile(`^(?:(or[0-9]*)\.)?([A-Za-z_][A-Za-z0-9_]*)(?:\[([A-Za-z]+)\])?$`)
+
+/*
+Parses the filters of the query string of the list endpoints:
+
+	?name=Meeting                          exact match (eq)
+	?start_date[gte]=2025-01-01            eq, ne, lt, lte, gt, gte, starts
+	?start_date[between]=2025-01-01,2025-01-31
+	?stato[in]=1,2
+	?fk_obj_id[null]=true                  IS NULL (false: IS NOT NULL)
+	?or.owner=1&or.group_id=2              the parameters with the same "or" prefix
+	                                       (or, or1, or2...) are an OR group
+
+The parameters in skip (ie. order_by) are ignored.
+*/
+func parseFilters(query url.Values, skip map[string]bool) ([]dblayer.Filter, error) {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		if !skip[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	filters := make([]dblayer.Filter, 0)
+	groups := make(map[string][]dblayer.Filter)
+	groupNames := make([]string, 0)
+	for _, name := range names {
+		match := filterParamRegexp.FindStringSubmatch(name)
+		if match == nil {
+			return nil, &dblayer.ValidationError{Column: name, Message: "invalid filter"}
+		}
+		group, column, opName := match[1], match[2], match[3]
+		op := dblayer.OpEq
+		if opName != "" {
+			var ok bool
+			if op, ok = dblayer.ParseFilterOp(opName); !ok {
+				return nil, &dblayer.ValidationError{Column: column, Message: "unknown operator '" + opName + "'"}
+			}
+		}
+		for _, value := range query[name] {
+			filter, err := parseFilter(column, op, value)
+			if err != nil {
+				return nil, err
+			}
+			if group == "" {
+				filters = append(filters, filter)
+				continue
+			}
+			if _, exists := groups[group]; !exists {
+				groupNames = append(groupNames, group)
+			}
+			groups[group] = append(groups[group], filter)
+		}
+	}
+	for _, group := range groupNames {
+		filters = append(filters, dblayer.Or(groups[group]...))
+	}
+	return filters, nil
+}
+
+func parseFilter(column string, op dblayer.FilterOp, value string) (dblayer.Filter, error) {
+	switch op {
+	case dblayer.OpIsNull, dblayer.OpNotNull:
+		isTrue, err := strconv.ParseBool(value)
+		if err != nil {
+			return dblayer.Filter{}, &dblayer.ValidationError{Column: column, Message: string(op) + ": expected true or false"}
+		}
+		if isTrue == (op == dblayer.OpIsNull) {
+			return dblayer.IsNull(column), nil
+		}
+		return dblayer.IsNotNull(column), nil
+	case dblayer.OpIn, dblayer.OpBetween:
+		values := make([]any, 0)
+		for _, v := range strings.Split(value, ",") {
+			values = append(values, v)
+		}
+		return dblayer.Filter{Column: column, Op: op, Values: values}, nil
+	}
+	return dblayer.Filter{Column: column, Op: op, Values: []any{value}}, nil
+}
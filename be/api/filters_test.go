@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"rprj/be/dblayer"
+)
+
+func TestParseFilters(t *testing.T) {
+	query, _ := url.ParseQuery("start_date[gte]=2025-01-01&stato[in]=1,2&fk_obj_id[null]=false&or.owner=u1&or.group_id=g1&name=x&order_by=name")
+	filters, err := parseFilters(query, genericListParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []dblayer.Filter{
+		dblayer.IsNotNull("fk_obj_id"),
+		dblayer.Eq("name", "x"),
+		dblayer.Gte("start_date", "2025-01-01"),
+		dblayer.In("stato", "1", "2"),
+		dblayer.Or(dblayer.Eq("group_id", "g1"), dblayer.Eq("owner", "u1")),
+	}
+	if !reflect.DeepEqual(filters, expected) {
+		t.Errorf("got %+v", filters)
+	}
+
+	for _, raw := range []string{"name[like]=x", "name[null]=maybe", "na-me=x", "and.name=x"} {
+		query, _ := url.ParseQuery(raw)
+		if _, err := parseFilters(query, nil); err == nil {
+			t.Errorf("%s: expected an error", raw)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"rprj/be/db"
+	"rprj/be/dblayer"
+
+	"github.com/gorilla/mux"
+)
+
+/*
+Classes not exposed by the generic endpoints: they have their own handlers,
+and contain passwords and tokens.
+*/
+var genericHiddenClasses = map[string]bool{
+	"DBVersion":      true,
+	"DBUser":         true,
+	"DBGroup":        true,
+	"DBUserGroup":    true,
+	"DBOAuthToken":   true,
+	"DBCalendarFeed": true,
+	"DBSearchIndex":  true,
+}
+
+// Query parameters of the generic list that are not filters
+var genericListParams = map[string]bool{"page": true, "page_size": true, "after": true, "order_by": true}
+
+// Returns a new instance of the {classname} of the path, nil if not exposed
+func genericInstance(r *http.Request) dblayer.DBEntityInterface {
+	classname := mux.Vars(r)["classname"]
+	if genericHiddenClasses[classname] {
+		return nil
+	}
+	return db.Factory.GetInstanceByClassName(classname)
+}
+
+/*
+Authorization rule of the generic reads: the anonymous user can read the public DBObjects only,
+not the other entities.
+*/
+func GenericReadAllowed(r *http.Request, dbContext *dblayer.DBContext) bool {
+	if !dbContext.IsAnonymous() {
+		return true
+	}
+	_, isObject := genericInstance(r).(dblayer.DBObjectInterface)
+	return isObject
+}
+
+/*
+Authorization rule of the generic writes: the DBObjects are protected by
+their permissions, the other entities (ie. countries) can be written by the admins only.
+The anonymous user cannot write.
+*/
+func GenericWriteAllowed(r *http.Request, dbContext *dblayer.DBContext) bool {
+	if dbContext.IsAnonymous() {
+		return false
+	}
+	if _, isObject := genericInstance(r).(dblayer.DBObjectInterface); isObject {
+		return true
+	}
+	return dbContext.IsInGroup(RoleAdmin)
+}
+
+// Sets the single primary key of the entity from {id}
+func setGenericID(w http.ResponseWriter, r *http.Request, dbe dblayer.DBEntityInterface) bool {
+	keys := dbe.GetKeys()
+	if len(keys) != 1 {
+		writeJSONError(w, http.StatusBadRequest, dbe.GetTypeName()+" has not a single primary key")
+		return false
+	}
+	dbe.SetValue(keys[0], mux.Vars(r)["id"])
+	return true
+}
+
+// Returns a new instance with only the primary keys of dbe set
+func genericProbe(dbe dblayer.DBEntityInterface) dblayer.DBEntityInterface {
+	probe := dbe.NewInstance()
+	for _, key := range dbe.GetKeys() {
+		probe.SetValue(key, dbe.GetValue(key))
+	}
+	return probe
+}
+
+// Reads the entity with the primary key of dbe, nil if not found (or not readable)
+func loadGeneric(repo *dblayer.DBRepository, dbe dblayer.DBEntityInterface) (dblayer.DBEntityInterface, error) {
+	results, err := repo.Search(dbe, false, false, "")
+	if err != nil || len(results) == 0 {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+/*
+Decodes the JSON body into the entity: the audit columns are dropped.
+The changes of owner, group_id and permissions are checked by the repository (ErrPermissionDenied).
+*/
+func decodeGeneric(w http.ResponseWriter, r *http.Request, dbe dblayer.DBEntityInterface) bool {
+	var values map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&values); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request format")
+		return false
+	}
+	// The primary keys come from the path (update) or are generated (insert)
+	for _, key := range dbe.GetKeys() {
+		if _, isObject := dbe.(dblayer.DBObjectInterface); isObject || dbe.HasValue(key) {
+			delete(values, key)
+		}
+	}
+	for name := range values {
+		if dblayer.IsAuditColumn(dbe, name) {
+			delete(values, name)
+		}
+	}
+	if err := dblayer.SetValuesFromMap(dbe, values); err != nil {
+		writeJSONError(w, errorStatus(err), err.Error())
+		return false
+	}
+	return true
+}
+
+// GET /objects/{classname}?column=value&column[op]=value&order_by=column&page=&page_size=&after=
+// See parseFilters for the operators
+func GenericListHandler(w http.ResponseWriter, r *http.Request) {
+	criteria := genericInstance(r)
+	if criteria == nil {
+		writeJSONError(w, http.StatusNotFound, "Unknown class")
+		return
+	}
+	page, err := parsePageRequest(r)
+	if err != nil {
+		writeJSONError(w, errorStatus(err), err.Error())
+		return
+	}
+	filters, err := parseFilters(r.URL.Query(), genericListParams)
+	if err != nil {
+		writeJSONError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	repo := db.NewDBRepository(r.Context())
+	result, err := repo.SearchPage(criteria, false, false, r.URL.Query().Get("order_by"), page, filters...)
+	if err != nil {
+		writeJSONError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newPageResponse(result.Items, result.Total, result.NextCursor))
+}
+
+// GET /objects/{classname}/{id}
+func GenericGetHandler(w http.ResponseWriter, r *http.Request) {
+	dbe := genericInstance(r)
+	if dbe == nil {
+		writeJSONError(w, http.StatusNotFound, "Unknown class")
+		return
+	}
+	if !setGenericID(w, r, dbe) {
+		return
+	}
+
+	repo := db.NewDBRepository(r.Context())
+	found, err := loadGeneric(repo, dbe)
+	if err != nil {
+		writeJSONError(w, errorStatus(err), err.Error())
+		return
+	}
+	if found == nil {
+		writeJSONError(w, http.StatusNotFound, "Not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(found)
+}
+
+// POST /objects/{classname}
+func GenericCreateHandler(w http.ResponseWriter, r *http.Request) {
+	dbe := genericInstance(r)
+	if dbe == nil {
+		writeJSONError(w, http.StatusNotFound, "Unknown class")
+		return
+	}
+	if !decodeGeneric(w, r, dbe) {
+		return
+	}
+
+	repo := db.NewDBRepository(r.Context())
+	created, err := repo.Insert(dbe)
+	if err != nil {
+		writeJSONError(w, errorStatus(err), "Failed to create "+dbe.GetTypeName()+": "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// PUT /objects/{classname}/{id}
+func GenericUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	dbe := genericInstance(r)
+	if dbe == nil {
+		writeJSONError(w, http.StatusNotFound, "Unknown class")
+		return
+	}
+	if !setGenericID(w, r, dbe) {
+		return
+	}
+
+	repo := db.NewDBRepository(r.Context())
+	found, err := loadGeneric(repo, genericProbe(dbe))
+	if err != nil {
+		writeJSONError(w, errorStatus(err), err.Error())
+		return
+	}
+	if found == nil {
+		writeJSONError(w, http.StatusNotFound, "Not found")
+		return
+	}
+
+	if !decodeGeneric(w, r, dbe) {
+		return
+	}
+	if _, err := repo.Update(dbe); err != nil {
+		writeJSONError(w, errorStatus(err), "Failed to update "+dbe.GetTypeName()+": "+err.Error())
+		return
+	}
+
+	// Returns the entity as stored
+	updated, err := loadGeneric(repo, genericProbe(dbe))
+	if err != nil || updated == nil {
+		updated = dbe
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DELETE /objects/{classname}/{id}
+func GenericDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	dbe := genericInstance(r)
+	if dbe == nil {
+		writeJSONError(w, http.StatusNotFound, "Unknown class")
+		return
+	}
+	if !setGenericID(w, r, dbe) {
+		return
+	}
+
+	repo := db.NewDBRepository(r.Context())
+	found, err := loadGeneric(repo, dbe)
+	if err != nil {
+		writeJSONError(w, errorStatus(err), err.Error())
+		return
+	}
+	if found == nil {
+		writeJSONError(w, http.StatusNotFound, "Not found")
+		return
+	}
+
+	if _, err := repo.Delete(found); err != nil {
+		writeJSONError(w, errorStatus(err), "Failed to delete "+dbe.GetTypeName()+": "+err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
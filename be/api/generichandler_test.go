@@ -0,0 +1,51 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"rprj/be/dblayer"
+)
+
+func TestDecodeGenericOwnership(t *testing.T) {
+	// owner, group_id and permissions reach the repository, which checks the caller
+	req := httptest.NewRequest(http.MethodPut, "/objects/DBNote/n1",
+		strings.NewReader(`{"name":"note","owner":"u2","group_id":"g2","permissions":"rwxrwxrwx"}`))
+	rr := httptest.NewRecorder()
+	dbe := dblayer.NewDBNote()
+	dbe.SetValue("id", "n1")
+	if !decodeGeneric(rr, req, dbe) {
+		t.Fatalf("rejected with %d: %s", rr.Code, rr.Body.String())
+	}
+	for column, expected := range map[string]string{"owner": "u2", "group_id": "g2", "permissions": "rwxrwxrwx"} {
+		if dbe.GetValue(column) != expected {
+			t.Errorf("%s: got '%s', want '%s'", column, dbe.GetValue(column), expected)
+		}
+	}
+	// The refusal of the repository is a 403
+	err := fmt.Errorf("%w: only the owner can change owner, group and permissions of n1", dblayer.ErrPermissionDenied)
+	if errorStatus(err) != http.StatusForbidden {
+		t.Error("expected 403, got", errorStatus(err))
+	}
+}
+
+func TestDecodeGenericAuditColumns(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/objects/DBNote",
+		strings.NewReader(`{"name":"note","creator":"u9","deleted_date":"2025-01-01 00:00:00","last_modify":"u9","last_modify_date":"2025-01-01 00:00:00"}`))
+	rr := httptest.NewRecorder()
+	dbe := dblayer.NewDBNote()
+	if !decodeGeneric(rr, req, dbe) {
+		t.Fatalf("rejected with %d: %s", rr.Code, rr.Body.String())
+	}
+	for _, column := range []string{"creator", "deleted_date", "last_modify", "last_modify_date"} {
+		if dbe.HasValue(column) {
+			t.Errorf("%s must not be set by the client", column)
+		}
+	}
+	if dbe.GetValue("name") != "note" {
+		t.Error("expected the name, got", dbe.GetValue("name"))
+	}
+}
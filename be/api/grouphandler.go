@@ -11,17 +11,22 @@ import (
 	"github.com/gorilla/mux"
 )
 
-// GET /groups
+// GET /groups?search=&order_by=&page=&page_size=&after=
 func GetAllGroupsHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := parsePageRequest(r)
+	if err != nil {
+		writeJSONError(w, errorStatus(err), err.Error())
+		return
+	}
 	searchBy := r.URL.Query().Get("search")
 	orderBy := r.URL.Query().Get("order_by")
-	groups, err := db.SearchGroupsBy(searchBy, orderBy)
+	groups, total, nextCursor, err := db.SearchGroupsBy(r.Context(), searchBy, orderBy, page)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, errorStatus(err), err.Error())
 		return
 	}
 
-	json.NewEncoder(w).Encode(groups)
+	json.NewEncoder(w).Encode(newPageResponse(groups, total, nextCursor))
 }
 
 // GET /groups/{id}
@@ -33,7 +38,7 @@ func GetGroupHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	group, err := db.GetGroupByID(id)
+	group, err := db.GetGroupByID(r.Context(), id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -44,7 +49,7 @@ func GetGroupHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get group users
-	groupUsers, err := db.GetUserGroupsByGroupID(id)
+	groupUsers, err := db.GetUserGroupsByGroupID(r.Context(), id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -94,7 +99,7 @@ func CreateGroupHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create group with transaction
-	createdGroup, err := db.CreateGroup(g, req.UserIDs)
+	createdGroup, err := db.CreateGroup(r.Context(), g, req.UserIDs)
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		// Check if it's a duplicate name error
@@ -150,8 +155,21 @@ func UpdateGroupHandler(w http.ResponseWriter, r *http.Request) {
 		Description: req.Description,
 	}
 
+	// Only the administrators can change the members of a group
+	if !IsAdmin(r) {
+		userGroups, err := db.GetUserGroupsByGroupID(r.Context(), id)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Failed to update group: "+err.Error())
+			return
+		}
+		req.UserIDs = make([]string, len(userGroups))
+		for i, ug := range userGroups {
+			req.UserIDs[i] = ug.UserID
+		}
+	}
+
 	// Update group with transaction
-	if err := db.UpdateGroup(g, req.UserIDs); err != nil {
+	if err := db.UpdateGroup(r.Context(), g, req.UserIDs); err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update group: " + err.Error()})
@@ -181,7 +199,7 @@ func DeleteGroupHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := db.DeleteGroup(id); err != nil {
+	if err := db.DeleteGroup(r.Context(), id); err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to delete group: " + err.Error()})
@@ -0,0 +1,269 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"rprj/be/calendar"
+	"rprj/be/db"
+	"rprj/be/dblayer"
+)
+
+// Name of the exported calendars (X-WR-CALNAME)
+var ICSCalendarName = "r-prj"
+
+// Days of past events in GET /calendar.ics: the recurring events are always exported
+var ICSPastDays = 365
+
+// A VEVENT imported with approximations, or skipped
+type ICSImportWarning struct {
+	UID     string `json:"uid"`
+	Name    string `json:"name"`
+	Message string `json:"message"`
+}
+
+/*
+Like OptionalAuthMiddleware, but with ?token= the request is authenticated by a calendar feed
+(see CreateCalendarFeedHandler): the calendar clients cannot send the Authorization header.
+*/
+func FeedTokenMiddleware(next http.Handler) http.Handler {
+	optional := OptionalAuthMiddleware(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if token == "" {
+			optional.ServeHTTP(w, r)
+			return
+		}
+
+		userID, err := db.CalendarFeedUser(r.Context(), token)
+		if errors.Is(err, db.ErrCalendarFeedInvalid) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			http.Error(w, "could not verify the feed token", http.StatusInternalServerError)
+			return
+		}
+		// Groups read now: they can be changed since the creation of the feed
+		user, err := db.GetUserByID(r.Context(), userID)
+		if err != nil || user == nil {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		groups, err := userGroupList(r.Context(), user)
+		if err != nil {
+			http.Error(w, "could not read the groups", http.StatusInternalServerError)
+			return
+		}
+		dbContext := db.NewDBContext(user.ID, groups)
+		next.ServeHTTP(w, r.WithContext(dblayer.NewContext(r.Context(), dbContext)))
+	})
+}
+
+/*
+Returns the filters of the scope of the export: user= (owner), group= (group_id), folder= (father_id)
+*/
+func icsScopeFilters(r *http.Request) []dblayer.Filter {
+	filters := make([]dblayer.Filter, 0)
+	query := r.URL.Query()
+	if user := query.Get("user"); user != "" {
+		filters = append(filters, dblayer.Eq("owner", user))
+	}
+	if group := query.Get("group"); group != "" {
+		filters = append(filters, dblayer.Eq("group_id", group))
+	}
+	if folder := query.Get("folder"); folder != "" {
+		filters = append(filters, dblayer.Eq("father_id", folder))
+	}
+	return filters
+}
+
+/*
+Returns the VCALENDAR of the events
+*/
+func eventsToICS(events []dblayer.DBEntityInterface, name string, now time.Time) *calendar.Component {
+	cal := calendar.NewCalendar(name)
+	for _, dbe := range events {
+		if vevent := calendar.VEventFromDB(dbe, now); vevent != nil {
+			cal.Components = append(cal.Components, vevent)
+		}
+	}
+	return cal
+}
+
+/*
+GET /calendar.ics[?user=<id>|group=<id>|folder=<id>][&token=<feed token>]
+The readable DBEvents in the iCalendar format: without token only the public ones
+*/
+func CalendarICSHandler(w http.ResponseWriter, r *http.Request) {
+	filters := icsScopeFilters(r)
+	since := time.Now().AddDate(0, 0, -ICSPastDays)
+	filters = append(filters, dblayer.Or(
+		dblayer.Gte("start_date", since),
+		dblayer.Gte("end_date", since),
+		dblayer.Eq("recurrence", "1"),
+	))
+
+	repo := db.NewDBRepository(r.Context())
+	events, err := repo.Search(dblayer.NewDBEvent(), false, false, "start_date", filters...)
+	if err != nil {
+		writeJSONError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="calendar.ics"`)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if _, err := eventsToICS(events, ICSCalendarName, time.Now()).WriteTo(w); err != nil {
+		log.Print("CalendarICSHandler: ", err)
+	}
+}
+
+/*
+Returns the DBEvents of the VEVENTs of the calendar: the ones that cannot be imported
+are skipped, with a warning
+*/
+func eventsFromICS(cal *calendar.Component, fatherID string) ([]dblayer.DBEntityInterface, []ICSImportWarning) {
+	events := make([]dblayer.DBEntityInterface, 0)
+	warnings := make([]ICSImportWarning, 0)
+	for _, vevent := range cal.Children("VEVENT") {
+		uid := vevent.GetText("UID")
+		name := vevent.GetText("SUMMARY")
+		if vevent.Get("RECURRENCE-ID") != nil {
+			// An exception of a recurring event
+			warnings = append(warnings, ICSImportWarning{UID: uid, Name: name, Message: "RECURRENCE-ID not supported: skipped"})
+			continue
+		}
+		dbe := dblayer.NewDBEvent()
+		messages, err := calendar.VEventToDB(vevent, dbe)
+		if err != nil {
+			warnings = append(warnings, ICSImportWarning{UID: uid, Name: name, Message: err.Error() + ": skipped"})
+			continue
+		}
+		for _, message := range messages {
+			warnings = append(warnings, ICSImportWarning{UID: uid, Name: name, Message: message})
+		}
+		if fatherID != "" {
+			dbe.SetValue("father_id", fatherID)
+		}
+		events = append(events, dbe)
+	}
+	return events, warnings
+}
+
+/*
+Reads the calendar of the import: the part "file" (and the field father_id) of a multipart request,
+or the whole body
+*/
+func readImportedICS(r *http.Request) (*calendar.Component, string, error) {
+	fatherID := r.URL.Query().Get("father_id")
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		cal, err := calendar.ParseICS(r.Body)
+		return cal, fatherID, err
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, fatherID, err
+	}
+	var cal *calendar.Component
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fatherID, err
+		}
+		switch part.FormName() {
+		case "file":
+			if cal, err = calendar.ParseICS(part); err != nil {
+				return nil, fatherID, err
+			}
+		case "father_id":
+			value, err := io.ReadAll(io.LimitReader(part, maxFormValueSize))
+			if err != nil {
+				return nil, fatherID, err
+			}
+			fatherID = string(bytes.TrimSpace(value))
+		}
+		part.Close()
+	}
+	if cal == nil {
+		return nil, fatherID, errors.New("missing file")
+	}
+	return cal, fatherID, nil
+}
+
+/*
+POST /calendar/import[?father_id=<id>]
+Creates a DBEvent for each VEVENT of the .ics (multipart "file", or the body), all or none.
+The father_id must be writable by the user.
+*/
+func ImportCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, calendar.MaxICSSize)
+	cal, fatherID, err := readImportedICS(r)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeUploadError(w, err)
+			return
+		}
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if cal.Name != "VCALENDAR" {
+		writeJSONError(w, http.StatusBadRequest, "Expected a VCALENDAR")
+		return
+	}
+
+	repo := db.NewDBRepository(r.Context())
+	if fatherID != "" {
+		father, err := repo.FullObject(fatherID)
+		if err != nil {
+			writeJSONError(w, errorStatus(err), err.Error())
+			return
+		}
+		if father == nil {
+			writeJSONError(w, http.StatusNotFound, "father_id: object not found")
+			return
+		}
+		if !father.CanWrite(repo.DbContext) {
+			writeJSONError(w, http.StatusForbidden, "father_id: permission denied")
+			return
+		}
+	}
+	events, warnings := eventsFromICS(cal, fatherID)
+
+	tx, err := repo.DbConnection.BeginTx(r.Context(), nil)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	for _, dbe := range events {
+		if err := repo.InsertWithTx(tx, dbe); err != nil {
+			writeJSONError(w, errorStatus(err), "Failed to create DBEvent '"+dbe.GetValue("name")+"': "+err.Error())
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	log.Printf("ImportCalendarHandler: %d events created, %d warnings", len(events), len(warnings))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{
+		"created":  len(events),
+		"items":    events,
+		"warnings": warnings,
+	})
+}
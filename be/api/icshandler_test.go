@@ -0,0 +1,90 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"rprj/be/calendar"
+	"rprj/be/dblayer"
+)
+
+func TestICSScopeFilters(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/calendar.ics?token=x&folder=f1&group=g1", nil)
+	filters := icsScopeFilters(req)
+	if len(filters) != 2 || filters[0].Column != "group_id" || filters[1].Column != "father_id" {
+		t.Errorf("got %+v", filters)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/calendar.ics", nil)
+	if filters := icsScopeFilters(req); len(filters) != 0 {
+		t.Errorf("got %+v", filters)
+	}
+}
+
+func TestEventsICSRoundTrip(t *testing.T) {
+	meeting := dblayer.NewDBEvent()
+	meeting.SetValue("id", "meeting")
+	meeting.SetValue("name", "Weekly meeting")
+	meeting.SetTime("start_date", time.Date(2025, 3, 5, 18, 0, 0, 0, time.Local))
+	meeting.SetTime("end_date", time.Date(2025, 3, 5, 19, 0, 0, 0, time.Local))
+	meeting.SetValue("all_day", "0")
+	meeting.SetValue("recurrence", "1")
+	meeting.SetValue("recurrence_type", "2")
+	meeting.SetInt("weekly_every_x", 1)
+	meeting.SetValue("weekly_day_of_the_week", "3")
+	meeting.SetInt("recurrence_times", 10)
+	meeting.SetValue("alarm", "1")
+	meeting.SetInt("alarm_minute", 10)
+	meeting.SetValue("alarm_unit", "0")
+	meeting.SetValue("before_event", "1")
+	// Without start date: not exported
+	empty := dblayer.NewDBEvent()
+
+	var b strings.Builder
+	if _, err := eventsToICS([]dblayer.DBEntityInterface{meeting, empty}, "Team", time.Now()).WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	cal, err := calendar.ParseICS(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(cal.Children("VEVENT")); n != 1 {
+		t.Fatalf("got %d VEVENTs", n)
+	}
+
+	events, warnings := eventsFromICS(cal, "folder")
+	if len(events) != 1 || len(warnings) != 0 {
+		t.Fatalf("got %v %v", events, warnings)
+	}
+	imported := events[0]
+	for _, column := range []string{"name", "start_date", "end_date", "recurrence_type", "weekly_day_of_the_week",
+		"recurrence_times", "alarm_minute", "alarm_unit", "before_event"} {
+		if imported.GetValue(column) != meeting.GetValue(column) {
+			t.Errorf("%s: got %q, want %q", column, imported.GetValue(column), meeting.GetValue(column))
+		}
+	}
+	if imported.GetValue("father_id") != "folder" || imported.GetValue("id") != "" {
+		t.Errorf("got %v", imported)
+	}
+}
+
+func TestEventsFromICSWarnings(t *testing.T) {
+	data := "BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VEVENT\r\nUID:a\r\nSUMMARY:No start\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nUID:b\r\nSUMMARY:Exception\r\nDTSTART:20250305T100000\r\nRECURRENCE-ID:20250305T100000\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nUID:c\r\nSUMMARY:Hourly\r\nDTSTART:20250305T100000\r\nRRULE:FREQ=HOURLY\r\nEND:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+	cal, err := calendar.ParseICS(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, warnings := eventsFromICS(cal, "")
+	if len(events) != 1 || events[0].GetBool("recurrence") {
+		t.Errorf("got %v", events)
+	}
+	if len(warnings) != 3 || warnings[0].UID != "a" || warnings[1].UID != "b" || warnings[2].UID != "c" {
+		t.Errorf("got %+v", warnings)
+	}
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"rprj/be/db"
+	"rprj/be/dblayer"
+
+	"github.com/gorilla/mux"
+)
+
+// Default depth of GET /nav/{id}/subtree
+const defaultSubtreeDepth = 3
+
+func writeNavResult(w http.ResponseWriter, result any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GET /nav/root
+func NavRootHandler(w http.ResponseWriter, r *http.Request) {
+	repo := db.NewDBRepository(r.Context())
+	roots, err := repo.GetRootObjects()
+	if err != nil {
+		writeJSONError(w, errorStatus(err), err.Error())
+		return
+	}
+	writeNavResult(w, roots)
+}
+
+// GET /nav/{id}/children
+func NavChildrenHandler(w http.ResponseWriter, r *http.Request) {
+	repo := db.NewDBRepository(r.Context())
+	children, err := repo.GetChildren(mux.Vars(r)["id"])
+	if err != nil {
+		writeJSONError(w, errorStatus(err), err.Error())
+		return
+	}
+	if children == nil {
+		writeJSONError(w, http.StatusNotFound, "Object not found")
+		return
+	}
+	writeNavResult(w, children)
+}
+
+// GET /nav/{id}/breadcrumbs
+func NavBreadcrumbsHandler(w http.ResponseWriter, r *http.Request) {
+	repo := db.NewDBRepository(r.Context())
+	path, err := repo.GetBreadcrumbs(mux.Vars(r)["id"])
+	if err != nil {
+		writeJSONError(w, errorStatus(err), err.Error())
+		return
+	}
+	if path == nil {
+		writeJSONError(w, http.StatusNotFound, "Object not found")
+		return
+	}
+	writeNavResult(w, path)
+}
+
+// GET /nav/{id}/subtree?depth=N
+func NavSubtreeHandler(w http.ResponseWriter, r *http.Request) {
+	depth := defaultSubtreeDepth
+	if v := r.URL.Query().Get("depth"); v != "" {
+		var err error
+		if depth, err = strconv.Atoi(v); err != nil || depth < 0 || depth > dblayer.MaxTreeDepth {
+			writeJSONError(w, http.StatusBadRequest, "depth must be between 0 and "+strconv.Itoa(dblayer.MaxTreeDepth))
+			return
+		}
+	}
+
+	repo := db.NewDBRepository(r.Context())
+	tree, err := repo.GetSubtree(mux.Vars(r)["id"], depth)
+	if err != nil {
+		writeJSONError(w, errorStatus(err), err.Error())
+		return
+	}
+	if tree == nil {
+		writeJSONError(w, http.StatusNotFound, "Object not found")
+		return
+	}
+	writeNavResult(w, tree)
+}
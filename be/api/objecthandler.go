@@ -0,0 +1,128 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"rprj/be/db"
+	"rprj/be/dblayer"
+
+	"github.com/gorilla/mux"
+)
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+// Maps the errors of the dblayer to an http status
+func errorStatus(err error) int {
+	if errors.Is(err, dblayer.ErrAuthenticationRequired) {
+		return http.StatusUnauthorized
+	}
+	if errors.Is(err, dblayer.ErrPermissionDenied) {
+		return http.StatusForbidden
+	}
+	var validationError *dblayer.ValidationError
+	if errors.As(err, &validationError) {
+		return http.StatusBadRequest
+	}
+	if errors.Is(err, dblayer.ErrInvalidOrderBy) || errors.Is(err, dblayer.ErrInvalidCursor) {
+		return http.StatusBadRequest
+	}
+	if errors.Is(err, dblayer.ErrTreeCycle) {
+		// The data are inconsistent, not the request
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// GET /trash?search=&page=&page_size=&after=
+func GetTrashHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := parsePageRequest(r)
+	if err != nil {
+		writeJSONError(w, errorStatus(err), err.Error())
+		return
+	}
+	repo := db.NewDBRepository(r.Context())
+
+	criteria := dblayer.NewDBObject()
+	if search := r.URL.Query().Get("search"); search != "" {
+		criteria.SetValue("name", search)
+	}
+	result, err := repo.SearchTrashPage(criteria, page)
+	if err != nil {
+		writeJSONError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newPageResponse(result.Items, result.Total, result.NextCursor))
+}
+
+// POST /objects/{id}/restore
+func RestoreObjectHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if id == "" {
+		writeJSONError(w, http.StatusBadRequest, "Missing object ID")
+		return
+	}
+
+	repo := db.NewDBRepository(r.Context())
+	repo.IncludeDeleted = true
+	obj, err := repo.FullObject(id)
+	if err != nil {
+		writeJSONError(w, errorStatus(err), err.Error())
+		return
+	}
+	if obj == nil || !obj.IsDeleted() {
+		writeJSONError(w, http.StatusNotFound, "Object not found in trash")
+		return
+	}
+
+	restored, err := repo.Restore(obj)
+	if err != nil {
+		writeJSONError(w, errorStatus(err), "Failed to restore object: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(restored)
+}
+
+// DELETE /objects/{id}?purge=true
+func DeleteObjectHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if id == "" {
+		writeJSONError(w, http.StatusBadRequest, "Missing object ID")
+		return
+	}
+	purge := r.URL.Query().Get("purge") == "true"
+
+	repo := db.NewDBRepository(r.Context())
+	// Deleted objects can only be purged
+	repo.IncludeDeleted = purge
+	obj, err := repo.FullObject(id)
+	if err != nil {
+		writeJSONError(w, errorStatus(err), err.Error())
+		return
+	}
+	if obj == nil {
+		writeJSONError(w, http.StatusNotFound, "Object not found")
+		return
+	}
+
+	if purge {
+		_, err = repo.Purge(obj)
+	} else {
+		_, err = repo.Delete(obj)
+	}
+	if err != nil {
+		writeJSONError(w, errorStatus(err), "Failed to delete object: "+err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"rprj/be/dblayer"
+)
+
+func TestErrorStatus(t *testing.T) {
+	cases := []struct {
+		err      error
+		expected int
+	}{
+		{dblayer.ErrAuthenticationRequired, http.StatusUnauthorized},
+		{dblayer.ErrPermissionDenied, http.StatusForbidden},
+		{&dblayer.ValidationError{Column: "name", Message: "cannot be null"}, http.StatusBadRequest},
+		{fmt.Errorf("%w: 'x'", dblayer.ErrInvalidOrderBy), http.StatusBadRequest},
+		{fmt.Errorf("%w: object 42", dblayer.ErrTreeCycle), http.StatusConflict},
+		{errors.New("connection refused"), http.StatusInternalServerError},
+	}
+	for _, c := range cases {
+		if got := errorStatus(c.err); got != c.expected {
+			t.Errorf("%v: got %d, want %d", c.err, got, c.expected)
+		}
+	}
+}
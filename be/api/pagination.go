@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"rprj/be/dblayer"
+)
+
+/*
+The envelope of the list endpoints:
+
+	{"items": [...], "total": 123, "next_cursor": "..."}
+
+next_cursor is null on the last page; pass it as ?after= to read the next one.
+*/
+type PageResponse struct {
+	Items      any     `json:"items"`
+	Total      int     `json:"total"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+func newPageResponse(items any, total int, nextCursor string) PageResponse {
+	ret := PageResponse{Items: items, Total: total}
+	if nextCursor != "" {
+		ret.NextCursor = &nextCursor
+	}
+	return ret
+}
+
+/*
+Reads ?page=&page_size=&after= ; the page size is limited to dblayer.MaxPageSize
+*/
+func parsePageRequest(r *http.Request) (dblayer.PageRequest, error) {
+	query := r.URL.Query()
+	page := dblayer.PageRequest{After: query.Get("after")}
+	var err error
+	if v := query.Get("page"); v != "" {
+		if page.Page, err = strconv.Atoi(v); err != nil || page.Page < 1 {
+			return page, &dblayer.ValidationError{Column: "page", Message: "expected a positive integer"}
+		}
+	}
+	if v := query.Get("page_size"); v != "" {
+		if page.PageSize, err = strconv.Atoi(v); err != nil || page.PageSize < 1 {
+			return page, &dblayer.ValidationError{Column: "page_size", Message: "expected a positive integer"}
+		}
+	}
+	return page.Normalize(), nil
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"rprj/be/db"
+	"rprj/be/dblayer"
+)
+
+// GET /search?q=words&class=DBPage&page=&page_size=
+func SearchHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		writeJSONError(w, http.StatusBadRequest, "Missing q")
+		return
+	}
+	className := r.URL.Query().Get("class")
+	if className != "" {
+		if _, isObject := db.Factory.GetInstanceByClassName(className).(dblayer.DBObjectInterface); !isObject || className == "DBObject" {
+			writeJSONError(w, http.StatusBadRequest, "Unknown class "+className)
+			return
+		}
+	}
+	page, err := parsePageRequest(r)
+	if err != nil {
+		writeJSONError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	repo := db.NewDBRepository(r.Context())
+	results, err := repo.FullTextSearch(q, className, page)
+	if err != nil {
+		writeJSONError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
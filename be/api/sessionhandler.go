@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"rprj/be/db"
+
+	"github.com/gorilla/mux"
+)
+
+// GET /users/{id}/sessions
+// Only the user and the administrators: see AdminOrSelf in main.go
+func GetUserSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	sessions, err := db.GetUserSessions(r.Context(), id)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	current := db.SessionID(bearerToken(r))
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == current
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessions)
+}
+
+// DELETE /users/{id}/sessions
+func DeleteUserSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if err := db.DeleteUserSessions(r.Context(), id); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to revoke sessions: "+err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /users/{id}/sessions/{token_id}
+func DeleteUserSessionHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	found, err := db.DeleteUserSession(r.Context(), id, vars["token_id"])
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to revoke session: "+err.Error())
+		return
+	}
+	if !found {
+		writeJSONError(w, http.StatusNotFound, "Session not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -11,17 +11,26 @@ import (
 	"github.com/gorilla/mux"
 )
 
-// GET /users
+// GET /users?search=&order_by=&page=&page_size=&after=
 func GetAllUsersHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := parsePageRequest(r)
+	if err != nil {
+		writeJSONError(w, errorStatus(err), err.Error())
+		return
+	}
 	searchBy := r.URL.Query().Get("search")
 	orderBy := r.URL.Query().Get("order_by")
-	users, err := db.GetAllUsers(searchBy, orderBy)
+	users, total, nextCursor, err := db.GetAllUsers(r.Context(), searchBy, orderBy, page)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, errorStatus(err), err.Error())
 		return
 	}
+	for i := range users {
+		users[i].Pwd = ""
+		users[i].PwdSalt = ""
+	}
 
-	json.NewEncoder(w).Encode(users)
+	json.NewEncoder(w).Encode(newPageResponse(users, total, nextCursor))
 }
 
 // GET /users/{id}
@@ -33,7 +42,7 @@ func GetUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := db.GetUserByID(id)
+	user, err := db.GetUserByID(r.Context(), id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -44,7 +53,7 @@ func GetUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get user groups
-	userGroups, err := db.GetUserGroupsByUserID(id)
+	userGroups, err := db.GetUserGroupsByUserID(r.Context(), id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -104,7 +113,7 @@ func CreateUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create user with transaction (creates group, user, and associations atomically)
-	createdUser, _, err := db.CreateUser(u, req.Login, req.GroupIDs)
+	createdUser, _, err := db.CreateUser(r.Context(), u, req.Login, req.GroupIDs)
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		// Check if it's a duplicate login error
@@ -118,6 +127,10 @@ func CreateUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Never send back the password hash
+	createdUser.Pwd = ""
+	createdUser.PwdSalt = ""
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(createdUser)
@@ -164,15 +177,37 @@ func UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
 		GroupID:  req.GroupID,
 	}
 
+	// Only the administrators can change the groups of a user
+	if !IsAdmin(r) {
+		current, err := db.GetUserByID(r.Context(), id)
+		if err != nil || current == nil {
+			writeJSONError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		userGroups, err := db.GetUserGroupsByUserID(r.Context(), id)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Failed to update user: "+err.Error())
+			return
+		}
+		u.GroupID = current.GroupID
+		req.GroupIDs = make([]string, len(userGroups))
+		for i, ug := range userGroups {
+			req.GroupIDs[i] = ug.GroupID
+		}
+	}
+
 	// Update user with transaction (updates user and group associations atomically)
 	updatePwd := req.Pwd != ""
-	if err := db.UpdateUser(u, updatePwd, req.GroupIDs); err != nil {
+	if err := db.UpdateUser(r.Context(), u, updatePwd, req.GroupIDs); err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update user: " + err.Error()})
 		return
 	}
 
+	u.Pwd = ""
+	u.PwdSalt = ""
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(u)
 }
@@ -188,7 +223,7 @@ func DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := db.DeleteUser(id); err != nil {
+	if err := db.DeleteUser(r.Context(), id); err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to delete user: " + err.Error()})
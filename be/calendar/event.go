@@ -0,0 +1,58 @@
+package calendar
+
+import (
+	"strconv"
+
+	"rprj/be/dblayer"
+)
+
+// The one char codes of the DBEvent columns: '0'...'9'
+func code(dbe dblayer.DBEntityInterface, columnName string) int {
+	n, err := strconv.Atoi(dbe.GetValue(columnName))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+/*
+Returns the Event of a DBEvent: the recurrence is read only if the flag recurrence is set
+*/
+func EventFromDB(dbe dblayer.DBEntityInterface) Event {
+	ev := Event{
+		Start:  dbe.GetTime("start_date"),
+		End:    dbe.GetTime("end_date"),
+		AllDay: dbe.GetBool("all_day"),
+	}
+	if !dbe.GetBool("recurrence") {
+		return ev
+	}
+
+	rule := Rule{
+		Frequency: Frequency(code(dbe, "recurrence_type")),
+		Count:     int(dbe.GetInt("recurrence_times")),
+		Until:     dbe.GetTime("recurrence_end_date"),
+	}
+	switch rule.Frequency {
+	case Daily:
+		rule.Interval = int(dbe.GetInt("daily_every_x"))
+	case Weekly:
+		rule.Interval = int(dbe.GetInt("weekly_every_x"))
+		rule.Weekday = code(dbe, "weekly_day_of_the_week")
+	case Monthly:
+		rule.Interval = int(dbe.GetInt("monthly_every_x"))
+		rule.MonthDay = int(dbe.GetInt("monthly_day_of_the_month"))
+		rule.WeekNumber = int(dbe.GetInt("monthly_week_number"))
+		rule.Weekday = code(dbe, "monthly_week_day")
+	case Yearly:
+		rule.Month = int(dbe.GetInt("yearly_month_number"))
+		rule.MonthDay = int(dbe.GetInt("yearly_month_day"))
+		rule.WeekNumber = int(dbe.GetInt("yearly_week_number"))
+		rule.Weekday = code(dbe, "yearly_week_day")
+		rule.YearDay = int(dbe.GetInt("yearly_day_of_the_year"))
+	default:
+		rule.Frequency = None
+	}
+	ev.Rule = rule
+	return ev
+}
@@ -0,0 +1,390 @@
+package calendar
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	// The TZID of the imported events: the docker image has no zoneinfo
+	_ "time/tzdata"
+)
+
+/*
+Reading and writing of the iCalendar (RFC 5545) format: a tree of components
+(VCALENDAR, VEVENT, VALARM...) with their properties.
+*/
+
+var ErrInvalidICS = errors.New("invalid iCalendar data")
+
+// Maximum size of an imported calendar
+var MaxICSSize int64 = 10 << 20
+
+const icsDateFormat = "20060102"
+const icsDateTimeFormat = "20060102T150405"
+
+type Property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+type Component struct {
+	Name       string
+	Properties []Property
+	Components []*Component
+}
+
+func NewComponent(name string) *Component {
+	return &Component{Name: name}
+}
+
+func (c *Component) Add(name string, value string) {
+	c.Properties = append(c.Properties, Property{Name: name, Value: value})
+}
+
+func (c *Component) AddWithParams(name string, params map[string]string, value string) {
+	c.Properties = append(c.Properties, Property{Name: name, Params: params, Value: value})
+}
+
+// Adds a TEXT property, escaping the value
+func (c *Component) AddText(name string, value string) {
+	c.Add(name, EscapeText(value))
+}
+
+// Returns the first property with the name, nil if none
+func (c *Component) Get(name string) *Property {
+	for i := range c.Properties {
+		if c.Properties[i].Name == name {
+			return &c.Properties[i]
+		}
+	}
+	return nil
+}
+
+// Returns the unescaped value of the first TEXT property with the name, "" if none
+func (c *Component) GetText(name string) string {
+	if p := c.Get(name); p != nil {
+		return UnescapeText(p.Value)
+	}
+	return ""
+}
+
+// Returns the sub components with the name, ie. the VEVENTs of a VCALENDAR
+func (c *Component) Children(name string) []*Component {
+	ret := make([]*Component, 0)
+	for _, child := range c.Components {
+		if child.Name == name {
+			ret = append(ret, child)
+		}
+	}
+	return ret
+}
+
+func EscapeText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`).Replace(s)
+}
+
+func UnescapeText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+/*
+Writes a content line folded at 75 octets, without breaking the UTF-8 characters
+*/
+func writeFolded(w *bufio.Writer, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut])
+		w.WriteString("\r\n ")
+		line = line[cut:]
+		// The leading space counts
+		limit = 74
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
+
+func quoteParam(value string) string {
+	if strings.ContainsAny(value, ";:,") {
+		return `"` + strings.ReplaceAll(value, `"`, "") + `"`
+	}
+	return value
+}
+
+func (p Property) line() string {
+	var b strings.Builder
+	b.WriteString(p.Name)
+	names := make([]string, 0, len(p.Params))
+	for name := range p.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b.WriteString(";" + name + "=" + quoteParam(p.Params[name]))
+	}
+	b.WriteString(":" + p.Value)
+	return b.String()
+}
+
+func (c *Component) write(w *bufio.Writer) {
+	writeFolded(w, "BEGIN:"+c.Name)
+	for _, p := range c.Properties {
+		writeFolded(w, p.line())
+	}
+	for _, child := range c.Components {
+		child.write(w)
+	}
+	writeFolded(w, "END:"+c.Name)
+}
+
+// Writes the component in the iCalendar format
+func (c *Component) WriteTo(out io.Writer) (int64, error) {
+	counter := &countingWriter{w: out}
+	w := bufio.NewWriter(counter)
+	c.write(w)
+	err := w.Flush()
+	return counter.n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+/*
+Parses a content line: NAME;PARAM=value;PARAM="quoted":value
+*/
+func parseLine(line string) (Property, error) {
+	p := Property{Params: make(map[string]string)}
+	i := strings.IndexAny(line, ";:")
+	if i <= 0 {
+		return p, fmt.Errorf("%w: '%s'", ErrInvalidICS, line)
+	}
+	p.Name = strings.ToUpper(line[:i])
+	rest := line[i:]
+	for strings.HasPrefix(rest, ";") {
+		rest = rest[1:]
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return p, fmt.Errorf("%w: '%s'", ErrInvalidICS, line)
+		}
+		name := strings.ToUpper(rest[:eq])
+		rest = rest[eq+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return p, fmt.Errorf("%w: '%s'", ErrInvalidICS, line)
+			}
+			value = rest[1 : end+1]
+			rest = rest[end+2:]
+		} else {
+			end := strings.IndexAny(rest, ";:")
+			if end < 0 {
+				return p, fmt.Errorf("%w: '%s'", ErrInvalidICS, line)
+			}
+			value = rest[:end]
+			rest = rest[end:]
+		}
+		p.Params[name] = value
+	}
+	if !strings.HasPrefix(rest, ":") {
+		return p, fmt.Errorf("%w: '%s'", ErrInvalidICS, line)
+	}
+	p.Value = rest[1:]
+	return p, nil
+}
+
+/*
+Parses an iCalendar stream: returns the first component (usually the VCALENDAR)
+*/
+func ParseICS(r io.Reader) (*Component, error) {
+	scanner := bufio.NewScanner(io.LimitReader(r, MaxICSSize))
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+
+	// Unfolding: the lines starting with a space or a tab continue the previous one
+	lines := make([]string, 0)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var root *Component
+	stack := make([]*Component, 0)
+	for _, line := range lines {
+		p, err := parseLine(line)
+		if err != nil {
+			return nil, err
+		}
+		switch p.Name {
+		case "BEGIN":
+			c := NewComponent(strings.ToUpper(p.Value))
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Components = append(parent.Components, c)
+			} else if root == nil {
+				root = c
+			} else {
+				return nil, fmt.Errorf("%w: more than a root component", ErrInvalidICS)
+			}
+			stack = append(stack, c)
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(p.Value) {
+				return nil, fmt.Errorf("%w: unexpected END:%s", ErrInvalidICS, p.Value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("%w: property %s outside of a component", ErrInvalidICS, p.Name)
+			}
+			c := stack[len(stack)-1]
+			c.Properties = append(c.Properties, p)
+		}
+	}
+	if root == nil || len(stack) > 0 {
+		return nil, fmt.Errorf("%w: incomplete calendar", ErrInvalidICS)
+	}
+	return root, nil
+}
+
+/*
+Parses a DATE or DATE-TIME property: UTC (Z), with TZID, or floating (local time).
+The result is in the local time; allDay is true for the dates.
+*/
+func ParseICSTime(p *Property) (t time.Time, allDay bool, err error) {
+	value := strings.TrimSpace(p.Value)
+	if p.Params["VALUE"] == "DATE" || len(value) == len(icsDateFormat) {
+		t, err = time.ParseInLocation(icsDateFormat, value, time.Local)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err = time.ParseInLocation(icsDateTimeFormat, strings.TrimSuffix(value, "Z"), time.UTC)
+		return t.In(time.Local), false, err
+	}
+	loc := time.Local
+	if tzid := p.Params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	t, err = time.ParseInLocation(icsDateTimeFormat, value, loc)
+	return t.In(time.Local), false, err
+}
+
+/*
+Parses a DURATION value, ie. -PT15M, P1D, P1W
+*/
+func ParseDuration(value string) (time.Duration, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	sign := time.Duration(1)
+	if strings.HasPrefix(s, "-") {
+		sign = -1
+		s = s[1:]
+	} else {
+		s = strings.TrimPrefix(s, "+")
+	}
+	if !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return 0, fmt.Errorf("%w: duration '%s'", ErrInvalidICS, value)
+	}
+	var d time.Duration
+	n := 0
+	digits := false
+	inTime := false
+	for _, ch := range s[1:] {
+		switch {
+		case ch >= '0' && ch <= '9':
+			n = n*10 + int(ch-'0')
+			digits = true
+			continue
+		case ch == 'T':
+			inTime = true
+			continue
+		}
+		if !digits {
+			return 0, fmt.Errorf("%w: duration '%s'", ErrInvalidICS, value)
+		}
+		var unit time.Duration
+		switch {
+		case !inTime && ch == 'W':
+			unit = 7 * 24 * time.Hour
+		case !inTime && ch == 'D':
+			unit = 24 * time.Hour
+		case inTime && ch == 'H':
+			unit = time.Hour
+		case inTime && ch == 'M':
+			unit = time.Minute
+		case inTime && ch == 'S':
+			unit = time.Second
+		default:
+			return 0, fmt.Errorf("%w: duration '%s'", ErrInvalidICS, value)
+		}
+		d += time.Duration(n) * unit
+		n = 0
+		digits = false
+	}
+	if digits {
+		return 0, fmt.Errorf("%w: duration '%s'", ErrInvalidICS, value)
+	}
+	return sign * d, nil
+}
+
+/*
+Formats a duration as a DURATION value, in weeks, days, hours or minutes
+*/
+func FormatDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+	minutes := int64(d / time.Minute)
+	switch {
+	case minutes == 0:
+		return "PT0M"
+	case minutes%(7*24*60) == 0:
+		return fmt.Sprintf("%sP%dW", sign, minutes/(7*24*60))
+	case minutes%(24*60) == 0:
+		return fmt.Sprintf("%sP%dD", sign, minutes/(24*60))
+	case minutes%60 == 0:
+		return fmt.Sprintf("%sPT%dH", sign, minutes/60)
+	}
+	return fmt.Sprintf("%sPT%dM", sign, minutes)
+}
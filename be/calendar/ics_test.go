@@ -0,0 +1,306 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"rprj/be/dblayer"
+)
+
+func TestParseICS(t *testing.T) {
+	data := "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:1@example.com\r\n" +
+		"SUMMARY:Riunione\\, settimanale\\; con\r\n" +
+		" tinuata\r\n" +
+		"DTSTART;TZID=\"Europe/Rome\":20250305T180000\r\n" +
+		"BEGIN:VALARM\r\n" +
+		"TRIGGER:-PT15M\r\n" +
+		"END:VALARM\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+	cal, err := ParseICS(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := cal.Children("VEVENT")
+	if cal.Name != "VCALENDAR" || len(events) != 1 || len(events[0].Children("VALARM")) != 1 {
+		t.Fatalf("got %+v", cal)
+	}
+	if got := events[0].GetText("SUMMARY"); got != "Riunione, settimanale; continuata" {
+		t.Errorf("SUMMARY: got %q", got)
+	}
+	dtstart := events[0].Get("DTSTART")
+	if dtstart.Params["TZID"] != "Europe/Rome" || dtstart.Value != "20250305T180000" {
+		t.Errorf("DTSTART: got %+v", dtstart)
+	}
+
+	for _, bad := range []string{
+		"BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nEND:VCALENDAR\r\n",
+		"BEGIN:VCALENDAR\r\n",
+		"VERSION:2.0\r\n",
+		"BEGIN:VCALENDAR\r\nNOCOLON\r\nEND:VCALENDAR\r\n",
+	} {
+		if _, err := ParseICS(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestWriteICSFolding(t *testing.T) {
+	cal := NewCalendar("Test")
+	vevent := NewComponent("VEVENT")
+	long := strings.Repeat("àbc ", 40)
+	vevent.AddText("DESCRIPTION", long+"\nfine")
+	cal.Components = append(cal.Components, vevent)
+
+	var b strings.Builder
+	if _, err := cal.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(b.String(), "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line longer than 75 octets: %q", line)
+		}
+		if !strings.HasPrefix(line, " ") && strings.Contains(line, "\n") {
+			t.Errorf("bare newline in %q", line)
+		}
+	}
+
+	// The round trip restores the text
+	parsed, err := ParseICS(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parsed.Children("VEVENT")[0].GetText("DESCRIPTION"); got != long+"\nfine" {
+		t.Errorf("got %q", got)
+	}
+	if parsed.GetText("X-WR-CALNAME") != "Test" {
+		t.Errorf("got %+v", parsed.Properties)
+	}
+}
+
+func TestParseICSTime(t *testing.T) {
+	tests := []struct {
+		property Property
+		want     time.Time
+		allDay   bool
+	}{
+		{Property{Value: "20250305"}, date(2025, 3, 5), true},
+		{Property{Params: map[string]string{"VALUE": "DATE"}, Value: "20250305"}, date(2025, 3, 5), true},
+		{Property{Value: "20250305T180000"}, date(2025, 3, 5, 18, 0), false},
+		{Property{Value: "20250305T170000Z"}, time.Date(2025, 3, 5, 17, 0, 0, 0, time.UTC), false},
+		{Property{Params: map[string]string{"TZID": "America/New_York"}, Value: "20250305T120000"},
+			time.Date(2025, 3, 5, 17, 0, 0, 0, time.UTC), false},
+	}
+	for _, tt := range tests {
+		got, allDay, err := ParseICSTime(&tt.property)
+		if err != nil || !got.Equal(tt.want) || allDay != tt.allDay {
+			t.Errorf("%+v: got %v %v %v", tt.property, got, allDay, err)
+		}
+	}
+}
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"-PT15M", -15 * time.Minute},
+		{"PT1H30M", 90 * time.Minute},
+		{"P1D", 24 * time.Hour},
+		{"-P1W", -7 * 24 * time.Hour},
+		{"P1DT2H", 26 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got, err := ParseDuration(tt.value); err != nil || got != tt.want {
+			t.Errorf("%s: got %v %v", tt.value, got, err)
+		}
+	}
+	for _, bad := range []string{"", "15M", "PT", "P1H", "PT15"} {
+		if _, err := ParseDuration(bad); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+	for _, d := range []time.Duration{-15 * time.Minute, 2 * time.Hour, -24 * time.Hour, 14 * 24 * time.Hour, 0} {
+		if got, err := ParseDuration(FormatDuration(d)); err != nil || got != d {
+			t.Errorf("%v: round trip got %v %v", d, got, err)
+		}
+	}
+}
+
+func TestRRULE(t *testing.T) {
+	start := date(2025, 3, 5, 18, 0)
+	tests := []struct {
+		rule Rule
+		want string
+	}{
+		{Rule{Frequency: Daily, Interval: 2, Count: 3}, "FREQ=DAILY;INTERVAL=2;COUNT=3"},
+		{Rule{Frequency: Weekly, Weekday: 5}, "FREQ=WEEKLY;BYDAY=FR"},
+		{Rule{Frequency: Weekly, Until: date(2025, 6, 30)}, "FREQ=WEEKLY;BYDAY=WE;UNTIL=20250630T235959"},
+		{Rule{Frequency: Monthly, MonthDay: 31}, "FREQ=MONTHLY;BYMONTHDAY=28,29,30,31;BYSETPOS=-1"},
+		{Rule{Frequency: Monthly, WeekNumber: LastWeek, Weekday: 5}, "FREQ=MONTHLY;BYDAY=-1FR"},
+		{Rule{Frequency: Yearly, Month: 11, WeekNumber: 4, Weekday: 4}, "FREQ=YEARLY;BYMONTH=11;BYDAY=4TH"},
+		{Rule{Frequency: Yearly}, "FREQ=YEARLY;BYMONTH=3;BYMONTHDAY=5"},
+		{Rule{Frequency: Yearly, YearDay: 100}, "FREQ=YEARLY;BYYEARDAY=100"},
+		{Rule{}, ""},
+	}
+	for _, tt := range tests {
+		got := tt.rule.RRULE(start, false)
+		if got != tt.want {
+			t.Errorf("%+v: got %q, want %q", tt.rule, got, tt.want)
+			continue
+		}
+		if got == "" {
+			continue
+		}
+		// The parsed rule has the same occurrences
+		parsed, warnings, err := ParseRRULE(got)
+		if err != nil || len(warnings) > 0 {
+			t.Errorf("%s: %v %v", got, warnings, err)
+			continue
+		}
+		ev := Event{Start: start, Rule: tt.rule}
+		want := starts(Expand(ev, date(2025, 1, 1), date(2028, 1, 1)))
+		ev.Rule = parsed
+		if got := starts(Expand(ev, date(2025, 1, 1), date(2028, 1, 1))); strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("%s: got %v, want %v", tt.want, got, want)
+		}
+	}
+
+	if got := (Rule{Frequency: Daily, Until: date(2025, 6, 30)}).RRULE(date(2025, 3, 5), true); got != "FREQ=DAILY;UNTIL=20250630" {
+		t.Errorf("all day: got %q", got)
+	}
+}
+
+func TestParseRRULEWarnings(t *testing.T) {
+	rule, warnings, err := ParseRRULE("FREQ=WEEKLY;BYDAY=MO,WE,FR;WKST=MO")
+	if err != nil || rule.Weekday != 1 || len(warnings) != 1 {
+		t.Errorf("got %+v %v %v", rule, warnings, err)
+	}
+	rule, warnings, err = ParseRRULE("FREQ=YEARLY;INTERVAL=4;BYHOUR=9")
+	if err != nil || rule.Frequency != Yearly || rule.Interval != 0 || len(warnings) != 2 {
+		t.Errorf("got %+v %v %v", rule, warnings, err)
+	}
+	if _, _, err := ParseRRULE("FREQ=HOURLY"); err == nil {
+		t.Error("expected an error for HOURLY")
+	}
+	if _, _, err := ParseRRULE("FREQ=DAILY;COUNT=x"); err == nil {
+		t.Error("expected an error for COUNT=x")
+	}
+}
+
+func TestVEventFromDB(t *testing.T) {
+	dbe := dblayer.NewDBEvent()
+	dbe.SetValue("id", "abc")
+	dbe.SetValue("name", "Compleanno")
+	dbe.SetValue("description", "<p>Festa &amp; torta</p>")
+	dbe.SetValue("category", "Personale")
+	dbe.SetTime("start_date", date(2024, 2, 29))
+	dbe.SetTime("end_date", date(2024, 2, 29))
+	dbe.SetValue("all_day", "1")
+	dbe.SetValue("recurrence", "1")
+	dbe.SetValue("recurrence_type", "4")
+	dbe.SetValue("alarm", "1")
+	dbe.SetInt("alarm_minute", 1)
+	dbe.SetValue("alarm_unit", "2")
+	dbe.SetValue("before_event", "1")
+
+	vevent := VEventFromDB(dbe, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	want := map[string]string{
+		"UID":         "abc@rprj",
+		"DTSTAMP":     "20250101T000000Z",
+		"DTSTART":     "20240229",
+		"DTEND":       "20240301",
+		"RRULE":       "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=28,29;BYSETPOS=-1",
+		"SUMMARY":     "Compleanno",
+		"DESCRIPTION": "Festa & torta",
+		"CATEGORIES":  "Personale",
+	}
+	for name, value := range want {
+		if p := vevent.Get(name); p == nil || UnescapeText(p.Value) != value {
+			t.Errorf("%s: got %+v, want %q", name, p, value)
+		}
+	}
+	if vevent.Get("DTSTART").Params["VALUE"] != "DATE" {
+		t.Errorf("DTSTART: got %+v", vevent.Get("DTSTART"))
+	}
+	alarms := vevent.Children("VALARM")
+	if len(alarms) != 1 || alarms[0].Get("TRIGGER").Value != "-P1D" || alarms[0].Get("ACTION").Value != "DISPLAY" {
+		t.Errorf("VALARM: got %+v", alarms)
+	}
+
+	// DTSTART is the first occurrence of the rule
+	dbe.SetValue("all_day", "0")
+	dbe.SetTime("start_date", date(2025, 1, 20, 10, 0))
+	dbe.SetTime("end_date", date(2025, 1, 20, 11, 0))
+	dbe.SetValue("recurrence_type", "3")
+	dbe.SetInt("monthly_day_of_the_month", 5)
+	vevent = VEventFromDB(dbe, time.Now())
+	if vevent.Get("DTSTART").Value != "20250205T100000" || vevent.Get("DTEND").Value != "20250205T110000" {
+		t.Errorf("got %+v %+v", vevent.Get("DTSTART"), vevent.Get("DTEND"))
+	}
+}
+
+func TestVEventToDB(t *testing.T) {
+	data := "BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:1@example.com\r\n" +
+		"SUMMARY:Ferie\r\n" +
+		"CATEGORIES:Lavoro,Vacanze\r\n" +
+		"DTSTART;VALUE=DATE:20250804\r\n" +
+		"DTEND;VALUE=DATE:20250816\r\n" +
+		"RRULE:FREQ=YEARLY;COUNT=3\r\n" +
+		"EXDATE;VALUE=DATE:20260804\r\n" +
+		"BEGIN:VALARM\r\n" +
+		"ACTION:DISPLAY\r\n" +
+		"TRIGGER:-PT2H\r\n" +
+		"END:VALARM\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"SUMMARY:Chiamata\r\n" +
+		"DTSTART:20250305T170000Z\r\n" +
+		"DURATION:PT45M\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+	cal, err := ParseICS(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := cal.Children("VEVENT")
+
+	holidays := dblayer.NewDBEvent()
+	warnings, err := VEventToDB(events[0], holidays)
+	if err != nil || len(warnings) != 1 {
+		t.Fatalf("got %v %v", warnings, err)
+	}
+	if !holidays.GetBool("all_day") || !holidays.GetTime("start_date").Equal(date(2025, 8, 4)) ||
+		!holidays.GetTime("end_date").Equal(date(2025, 8, 15)) || holidays.GetValue("category") != "Lavoro" {
+		t.Errorf("got %v", holidays)
+	}
+	ev := EventFromDB(holidays)
+	if ev.Rule.Frequency != Yearly || ev.Rule.Count != 3 {
+		t.Errorf("rule: got %+v", ev.Rule)
+	}
+	if trigger, ok := AlarmFromDB(holidays); !ok || trigger != -2*time.Hour || holidays.GetValue("alarm_unit") != "1" {
+		t.Errorf("alarm: got %v %v", trigger, ok)
+	}
+
+	call := dblayer.NewDBEvent()
+	if _, err := VEventToDB(events[1], call); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2025, 3, 5, 17, 0, 0, 0, time.UTC)
+	if call.GetBool("all_day") || !call.GetTime("start_date").Equal(start) || !call.GetTime("end_date").Equal(start.Add(45*time.Minute)) {
+		t.Errorf("got %v", call)
+	}
+	if call.GetBool("recurrence") || call.GetBool("alarm") {
+		t.Errorf("got %v", call)
+	}
+
+	if _, err := VEventToDB(NewComponent("VEVENT"), dblayer.NewDBEvent()); err == nil {
+		t.Error("expected an error without DTSTART")
+	}
+}
@@ -0,0 +1,288 @@
+package calendar
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"rprj/be/dblayer"
+)
+
+/*
+Mapping between DBEvent and VEVENT.
+
+The alarm of a DBEvent:
+
+	alarm         '1' if set
+	alarm_minute  the amount, in alarm_unit: 0 minutes, 1 hours, 2 days
+	before_event  '1' before the start, otherwise after it
+
+The times are written floating (the local time of the server), the all day events as dates.
+*/
+
+const ProdID = "-//R-Project//rprj//EN"
+
+// The domain of the UIDs of the exported events
+var UIDDomain = "rprj"
+
+const (
+	AlarmMinutes = 0
+	AlarmHours   = 1
+	AlarmDays    = 2
+)
+
+var alarmUnits = []time.Duration{time.Minute, time.Hour, 24 * time.Hour}
+
+/*
+Returns the trigger of the alarm relative to the start (negative before it), ok false if none
+*/
+func AlarmFromDB(dbe dblayer.DBEntityInterface) (time.Duration, bool) {
+	if !dbe.GetBool("alarm") {
+		return 0, false
+	}
+	unit := code(dbe, "alarm_unit")
+	if unit < 0 || unit >= len(alarmUnits) {
+		unit = AlarmMinutes
+	}
+	d := time.Duration(dbe.GetInt("alarm_minute")) * alarmUnits[unit]
+	if dbe.GetBool("before_event") {
+		d = -d
+	}
+	return d, true
+}
+
+// Sets the alarm columns, in the largest unit dividing the trigger
+func SetAlarmToDB(dbe dblayer.DBEntityInterface, trigger time.Duration) {
+	dbe.SetBool("alarm", true)
+	dbe.SetBool("before_event", trigger < 0)
+	if trigger < 0 {
+		trigger = -trigger
+	}
+	for unit := AlarmDays; unit >= AlarmMinutes; unit-- {
+		if trigger%alarmUnits[unit] == 0 || unit == AlarmMinutes {
+			dbe.SetInt("alarm_minute", int64(trigger/alarmUnits[unit]))
+			dbe.SetValue("alarm_unit", fmt.Sprint(unit))
+			return
+		}
+	}
+}
+
+/*
+Sets the columns of the recurrence, clearing the ones of the other frequencies
+*/
+func SetRuleToDB(dbe dblayer.DBEntityInterface, rule Rule) {
+	for _, name := range []string{"daily_every_x", "weekly_every_x", "monthly_every_x", "monthly_day_of_the_month",
+		"monthly_week_number", "yearly_month_number", "yearly_month_day", "yearly_week_number", "yearly_day_of_the_year"} {
+		dbe.SetInt(name, 0)
+	}
+	for _, name := range []string{"weekly_day_of_the_week", "monthly_week_day", "yearly_week_day"} {
+		dbe.SetValue(name, "0")
+	}
+	dbe.SetInt("recurrence_times", int64(rule.Count))
+	dbe.SetTime("recurrence_end_date", rule.Until)
+	if rule.Frequency == None {
+		dbe.SetBool("recurrence", false)
+		dbe.SetValue("recurrence_type", "0")
+		return
+	}
+	dbe.SetBool("recurrence", true)
+	dbe.SetValue("recurrence_type", fmt.Sprint(int(rule.Frequency)))
+	interval := int64(rule.Interval)
+	if interval < 1 {
+		interval = 1
+	}
+	weekday := fmt.Sprint(rule.Weekday)
+	switch rule.Frequency {
+	case Daily:
+		dbe.SetInt("daily_every_x", interval)
+	case Weekly:
+		dbe.SetInt("weekly_every_x", interval)
+		dbe.SetValue("weekly_day_of_the_week", weekday)
+	case Monthly:
+		dbe.SetInt("monthly_every_x", interval)
+		dbe.SetInt("monthly_day_of_the_month", int64(rule.MonthDay))
+		dbe.SetInt("monthly_week_number", int64(rule.WeekNumber))
+		dbe.SetValue("monthly_week_day", weekday)
+	case Yearly:
+		dbe.SetInt("yearly_month_number", int64(rule.Month))
+		dbe.SetInt("yearly_month_day", int64(rule.MonthDay))
+		dbe.SetInt("yearly_week_number", int64(rule.WeekNumber))
+		dbe.SetValue("yearly_week_day", weekday)
+		dbe.SetInt("yearly_day_of_the_year", int64(rule.YearDay))
+	}
+}
+
+// Returns an empty VCALENDAR
+func NewCalendar(name string) *Component {
+	cal := NewComponent("VCALENDAR")
+	cal.Add("VERSION", "2.0")
+	cal.Add("PRODID", ProdID)
+	cal.Add("CALSCALE", "GREGORIAN")
+	cal.Add("METHOD", "PUBLISH")
+	if name != "" {
+		cal.AddText("X-WR-CALNAME", name)
+	}
+	return cal
+}
+
+func addDate(c *Component, name string, t time.Time, allDay bool) {
+	if allDay {
+		c.AddWithParams(name, map[string]string{"VALUE": "DATE"}, t.Format(icsDateFormat))
+		return
+	}
+	c.Add(name, t.Format(icsDateTimeFormat))
+}
+
+/*
+Returns the VEVENT of a DBEvent, nil if it has no occurrence.
+DTSTART is the first occurrence: iCalendar counts DTSTART as an occurrence even if
+it does not match the RRULE.
+*/
+func VEventFromDB(dbe dblayer.DBEntityInterface, now time.Time) *Component {
+	ev := EventFromDB(dbe)
+	first, ok := First(ev)
+	if !ok {
+		return nil
+	}
+
+	vevent := NewComponent("VEVENT")
+	vevent.Add("UID", dbe.GetValue("id")+"@"+UIDDomain)
+	vevent.Add("DTSTAMP", now.UTC().Format(icsDateTimeFormat)+"Z")
+	if created := dbe.GetTime("creation_date"); !created.IsZero() {
+		vevent.Add("CREATED", created.UTC().Format(icsDateTimeFormat)+"Z")
+	}
+	if modified := dbe.GetTime("last_modify_date"); !modified.IsZero() {
+		vevent.Add("LAST-MODIFIED", modified.UTC().Format(icsDateTimeFormat)+"Z")
+	}
+	addDate(vevent, "DTSTART", first.Start, ev.AllDay)
+	// The all day occurrences end the day after, as DTEND is exclusive
+	addDate(vevent, "DTEND", first.End, ev.AllDay)
+	if rrule := ev.Rule.RRULE(first.Start, ev.AllDay); rrule != "" {
+		vevent.Add("RRULE", rrule)
+	}
+	vevent.AddText("SUMMARY", dbe.GetValue("name"))
+	if description := strings.TrimSpace(dblayer.StripHTML(dbe.GetValue("description"))); description != "" {
+		vevent.AddText("DESCRIPTION", description)
+	}
+	if category := dbe.GetValue("category"); category != "" {
+		vevent.AddText("CATEGORIES", category)
+	}
+	if url := dbe.GetValue("url"); url != "" {
+		vevent.Add("URL", url)
+	}
+
+	if trigger, ok := AlarmFromDB(dbe); ok {
+		alarm := NewComponent("VALARM")
+		alarm.Add("ACTION", "DISPLAY")
+		alarm.AddText("DESCRIPTION", dbe.GetValue("name"))
+		alarm.Add("TRIGGER", FormatDuration(trigger))
+		vevent.Components = append(vevent.Components, alarm)
+	}
+	return vevent
+}
+
+/*
+Sets the columns of a DBEvent from a VEVENT, returning the warnings about the properties
+that cannot be imported
+*/
+func VEventToDB(vevent *Component, dbe dblayer.DBEntityInterface) ([]string, error) {
+	warnings := make([]string, 0)
+	dtstart := vevent.Get("DTSTART")
+	if dtstart == nil {
+		return warnings, fmt.Errorf("%w: VEVENT without DTSTART", ErrInvalidICS)
+	}
+	start, allDay, err := ParseICSTime(dtstart)
+	if err != nil {
+		return warnings, fmt.Errorf("%w: DTSTART '%s'", ErrInvalidICS, dtstart.Value)
+	}
+
+	end := start
+	if allDay {
+		end = start.AddDate(0, 0, 1)
+	}
+	if dtend := vevent.Get("DTEND"); dtend != nil {
+		if end, _, err = ParseICSTime(dtend); err != nil {
+			return warnings, fmt.Errorf("%w: DTEND '%s'", ErrInvalidICS, dtend.Value)
+		}
+	} else if duration := vevent.Get("DURATION"); duration != nil {
+		d, err := ParseDuration(duration.Value)
+		if err != nil {
+			return warnings, err
+		}
+		end = start.Add(d)
+	}
+	if end.Before(start) {
+		end = start
+	}
+	duration := end.Sub(start)
+	if allDay && end.After(start) {
+		// end_date is the last day
+		end = end.AddDate(0, 0, -1)
+	}
+
+	name := vevent.GetText("SUMMARY")
+	if name == "" {
+		name = "(no title)"
+	}
+	dbe.SetValue("name", name)
+	dbe.SetValue("description", vevent.GetText("DESCRIPTION"))
+	dbe.SetTime("start_date", start)
+	dbe.SetTime("end_date", end)
+	dbe.SetBool("all_day", allDay)
+	dbe.SetValue("url", vevent.GetText("URL"))
+	// Only the first category
+	category, _, _ := strings.Cut(vevent.GetText("CATEGORIES"), ",")
+	dbe.SetValue("category", strings.TrimSpace(category))
+
+	rule := Rule{}
+	if rrule := vevent.Get("RRULE"); rrule != nil {
+		var ruleWarnings []string
+		rule, ruleWarnings, err = ParseRRULE(rrule.Value)
+		warnings = append(warnings, ruleWarnings...)
+		if err != nil {
+			warnings = append(warnings, err.Error()+": imported as a single event")
+			rule = Rule{}
+		}
+	}
+	SetRuleToDB(dbe, rule)
+	for _, name := range []string{"EXDATE", "RDATE", "RECURRENCE-ID"} {
+		if vevent.Get(name) != nil {
+			warnings = append(warnings, name+" not supported")
+		}
+	}
+
+	dbe.SetBool("alarm", false)
+	dbe.SetInt("alarm_minute", 0)
+	dbe.SetValue("alarm_unit", "0")
+	dbe.SetBool("before_event", false)
+	alarms := vevent.Children("VALARM")
+	for i, alarm := range alarms {
+		trigger := alarm.Get("TRIGGER")
+		if trigger == nil {
+			continue
+		}
+		if trigger.Params["VALUE"] == "DATE-TIME" {
+			at, _, err := ParseICSTime(trigger)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("TRIGGER '%s' not valid", trigger.Value))
+				continue
+			}
+			SetAlarmToDB(dbe, at.Sub(start).Truncate(time.Minute))
+		} else {
+			d, err := ParseDuration(trigger.Value)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("TRIGGER '%s' not valid", trigger.Value))
+				continue
+			}
+			if trigger.Params["RELATED"] == "END" {
+				d += duration
+			}
+			SetAlarmToDB(dbe, d)
+		}
+		if len(alarms) > i+1 {
+			warnings = append(warnings, "only the first VALARM is imported")
+		}
+		break
+	}
+	return warnings, nil
+}
@@ -0,0 +1,292 @@
+package calendar
+
+import (
+	"time"
+)
+
+/*
+Expansion of the recurring events into their occurrences.
+
+The recurrence model is the one of the old r-prj (the columns of DBEvent):
+
+	recurrence_type  1 daily, 2 weekly, 3 monthly, 4 yearly (0 none)
+	daily_every_x    every x days
+	weekly_every_x   every x weeks, on weekly_day_of_the_week
+	monthly_every_x  every x months, on monthly_day_of_the_month
+	                 or on the monthly_week_number-th (5 = last) monthly_week_day
+	yearly_*         every year: on yearly_day_of_the_year,
+	                 or on the yearly_week_number-th (5 = last) yearly_week_day of yearly_month_number,
+	                 or on yearly_month_day of yearly_month_number
+	recurrence_times number of occurrences, 0 = unlimited
+	recurrence_end_date  last day of the occurrences, zero = unlimited
+
+The days of the week are 1 Monday ... 7 Sunday (ISO 8601); 0 means the day of the start date.
+The same for the other values: 0 takes the day (month) of the start date.
+Days after the end of the month (ie. 31 in April) fall on the last day of the month.
+*/
+
+type Frequency int
+
+const (
+	None Frequency = iota
+	Daily
+	Weekly
+	Monthly
+	Yearly
+)
+
+// The n-th week of the month meaning the last one
+const LastWeek = 5
+
+// Occurrences returned at most by Expand for an event
+var MaxOccurrences = 1000
+
+// Periods examined at most by Expand: protects from wrong data (ie. a yearly Feb 30)
+var maxPeriods = 100_000
+
+type Rule struct {
+	Frequency  Frequency
+	Interval   int // every Interval days, weeks, months (1 if 0)
+	Weekday    int // 1 Monday ... 7 Sunday, 0 the day of the start
+	MonthDay   int // 0 the day of the start
+	WeekNumber int // 1...4, LastWeek; 0 to use MonthDay
+	Month      int // 1...12, 0 the month of the start (yearly)
+	YearDay    int // 1...366, 0 to use Month (yearly)
+	Count      int // 0 unlimited
+	Until      time.Time
+}
+
+type Event struct {
+	Start  time.Time
+	End    time.Time
+	AllDay bool
+	Rule   Rule
+}
+
+type Occurrence struct {
+	Start time.Time
+	End   time.Time
+	Index int // 1 for the first occurrence of the event
+}
+
+func daysIn(year int, month time.Month, loc *time.Location) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, loc).Day()
+}
+
+// The time.Weekday of an ISO day (1 Monday ... 7 Sunday), def if 0 or invalid
+func isoWeekday(day int, def time.Weekday) time.Weekday {
+	if day < 1 || day > 7 {
+		return def
+	}
+	return time.Weekday(day % 7)
+}
+
+/*
+Returns the day of the month of the n-th weekday (n = LastWeek or more for the last one)
+*/
+func nthWeekday(year int, month time.Month, n int, weekday time.Weekday, loc *time.Location) int {
+	days := daysIn(year, month, loc)
+	if n < 1 {
+		n = 1
+	}
+	first := time.Date(year, month, 1, 0, 0, 0, 0, loc).Weekday()
+	day := 1 + (int(weekday)-int(first)+7)%7 + (n-1)*7
+	if n >= LastWeek || day > days {
+		last := time.Date(year, month, days, 0, 0, 0, 0, loc).Weekday()
+		day = days - (int(last)-int(weekday)+7)%7
+	}
+	return day
+}
+
+// The day, clamped to the month
+func clampDay(year int, month time.Month, day int, loc *time.Location) int {
+	if days := daysIn(year, month, loc); day > days {
+		return days
+	}
+	if day < 1 {
+		return 1
+	}
+	return day
+}
+
+/*
+Returns the start of the k-th period of the rule (the candidate occurrence), ok false if none
+*/
+func (ev *Event) candidate(k int) (time.Time, bool) {
+	start := ev.Start
+	loc := start.Location()
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, start.Hour(), start.Minute(), start.Second(), 0, loc)
+	}
+	rule := ev.Rule
+	interval := rule.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	switch rule.Frequency {
+	case Daily:
+		return at(start.Year(), start.Month(), start.Day()+k*interval), true
+	case Weekly:
+		weekday := isoWeekday(rule.Weekday, start.Weekday())
+		first := start.Day() + (int(weekday)-int(start.Weekday())+7)%7
+		return at(start.Year(), start.Month(), first+k*7*interval), true
+	case Monthly:
+		month := time.Date(start.Year(), start.Month()+time.Month(k*interval), 1, 0, 0, 0, 0, loc)
+		year, m := month.Year(), month.Month()
+		if rule.WeekNumber > 0 {
+			weekday := isoWeekday(rule.Weekday, start.Weekday())
+			return at(year, m, nthWeekday(year, m, rule.WeekNumber, weekday, loc)), true
+		}
+		day := rule.MonthDay
+		if day == 0 {
+			day = start.Day()
+		}
+		return at(year, m, clampDay(year, m, day, loc)), true
+	case Yearly:
+		year := start.Year() + k*interval
+		if rule.YearDay > 0 {
+			yearDays := 365
+			if daysIn(year, time.February, loc) == 29 {
+				yearDays = 366
+			}
+			day := rule.YearDay
+			if day > yearDays {
+				day = yearDays
+			}
+			return at(year, time.January, day), true
+		}
+		month := time.Month(rule.Month)
+		if month < time.January || month > time.December {
+			month = start.Month()
+		}
+		if rule.WeekNumber > 0 {
+			weekday := isoWeekday(rule.Weekday, start.Weekday())
+			return at(year, month, nthWeekday(year, month, rule.WeekNumber, weekday, loc)), true
+		}
+		day := rule.MonthDay
+		if day == 0 {
+			day = start.Day()
+		}
+		return at(year, month, clampDay(year, month, day, loc)), true
+	}
+	return time.Time{}, false
+}
+
+/*
+Returns the duration of the occurrences: for the all day events a number of whole days,
+the end date being the last day (inclusive)
+*/
+func (ev *Event) duration() (days int, d time.Duration) {
+	if ev.AllDay {
+		startDay := time.Date(ev.Start.Year(), ev.Start.Month(), ev.Start.Day(), 0, 0, 0, 0, time.UTC)
+		endDay := time.Date(ev.End.Year(), ev.End.Month(), ev.End.Day(), 0, 0, 0, 0, time.UTC)
+		days = int(endDay.Sub(startDay).Hours()/24) + 1
+		if ev.End.IsZero() || days < 1 {
+			days = 1
+		}
+		return days, 0
+	}
+	if ev.End.After(ev.Start) {
+		return 0, ev.End.Sub(ev.Start)
+	}
+	return 0, 0
+}
+
+/*
+Returns the occurrence starting at start
+*/
+func (ev *Event) occurrence(start time.Time, index int) Occurrence {
+	days, d := ev.duration()
+	if ev.AllDay {
+		start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+		return Occurrence{Start: start, End: start.AddDate(0, 0, days), Index: index}
+	}
+	return Occurrence{Start: start, End: start.Add(d), Index: index}
+}
+
+// True if the occurrence overlaps [from, to): the instants (start = end) must be inside
+func (o Occurrence) overlaps(from time.Time, to time.Time) bool {
+	if o.End.Equal(o.Start) {
+		return !o.Start.Before(from) && o.Start.Before(to)
+	}
+	return o.Start.Before(to) && o.End.After(from)
+}
+
+/*
+Returns the last instant an occurrence can start: Until, the whole day if it has no time
+*/
+func (rule Rule) limit() (time.Time, bool) {
+	if rule.Until.IsZero() {
+		return time.Time{}, false
+	}
+	u := rule.Until
+	if u.Hour() == 0 && u.Minute() == 0 && u.Second() == 0 {
+		return time.Date(u.Year(), u.Month(), u.Day(), 23, 59, 59, 0, u.Location()), true
+	}
+	return u, true
+}
+
+/*
+Returns the occurrences of the event overlapping [from, to), at most MaxOccurrences.
+An event without recurrence has a single occurrence.
+*/
+func Expand(ev Event, from time.Time, to time.Time) []Occurrence {
+	ret := make([]Occurrence, 0)
+	if ev.Start.IsZero() || !from.Before(to) {
+		return ret
+	}
+	if ev.Rule.Frequency == None {
+		if o := ev.occurrence(ev.Start, 1); o.overlaps(from, to) {
+			ret = append(ret, o)
+		}
+		return ret
+	}
+
+	limit, limited := ev.Rule.limit()
+	index := 0
+	for k := 0; k < maxPeriods && len(ret) < MaxOccurrences; k++ {
+		start, ok := ev.candidate(k)
+		if !ok || !start.Before(to) {
+			break
+		}
+		if start.Before(ev.Start) {
+			// ie. monthly on the 5th, starting on the 20th
+			continue
+		}
+		if limited && start.After(limit) {
+			break
+		}
+		index++
+		if ev.Rule.Count > 0 && index > ev.Rule.Count {
+			break
+		}
+		if o := ev.occurrence(start, index); o.overlaps(from, to) {
+			ret = append(ret, o)
+		}
+	}
+	return ret
+}
+
+/*
+Returns the first occurrence of the event, ok false if the rule has none
+*/
+func First(ev Event) (Occurrence, bool) {
+	if ev.Start.IsZero() {
+		return Occurrence{}, false
+	}
+	if ev.Rule.Frequency == None {
+		return ev.occurrence(ev.Start, 1), true
+	}
+	limit, limited := ev.Rule.limit()
+	for k := 0; k < maxPeriods; k++ {
+		start, ok := ev.candidate(k)
+		if !ok || (limited && start.After(limit)) {
+			break
+		}
+		if !start.Before(ev.Start) {
+			return ev.occurrence(start, 1), true
+		}
+	}
+	return Occurrence{}, false
+}
@@ -0,0 +1,172 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+
+	"rprj/be/dblayer"
+)
+
+func date(year int, month time.Month, day int, hm ...int) time.Time {
+	hour, minute := 0, 0
+	if len(hm) == 2 {
+		hour, minute = hm[0], hm[1]
+	}
+	return time.Date(year, month, day, hour, minute, 0, 0, time.Local)
+}
+
+func starts(occurrences []Occurrence) []string {
+	ret := make([]string, 0, len(occurrences))
+	for _, o := range occurrences {
+		ret = append(ret, o.Start.Format("2006-01-02 15:04"))
+	}
+	return ret
+}
+
+func assertStarts(t *testing.T, name string, occurrences []Occurrence, expected ...string) {
+	t.Helper()
+	got := starts(occurrences)
+	if len(got) != len(expected) {
+		t.Errorf("%s: got %v, want %v", name, got, expected)
+		return
+	}
+	for i := range got {
+		if got[i] != expected[i] {
+			t.Errorf("%s: got %v, want %v", name, got, expected)
+			return
+		}
+	}
+}
+
+func TestExpandSingle(t *testing.T) {
+	ev := Event{Start: date(2025, 3, 10, 9, 0), End: date(2025, 3, 10, 10, 30)}
+	assertStarts(t, "inside", Expand(ev, date(2025, 3, 1), date(2025, 4, 1)), "2025-03-10 09:00")
+	assertStarts(t, "outside", Expand(ev, date(2025, 4, 1), date(2025, 5, 1)))
+	// Overlapping the start of the window
+	assertStarts(t, "overlap", Expand(ev, date(2025, 3, 10, 10, 0), date(2025, 3, 11)), "2025-03-10 09:00")
+
+	allDay := Event{Start: date(2025, 3, 10), End: date(2025, 3, 12), AllDay: true}
+	o := Expand(allDay, date(2025, 3, 12), date(2025, 3, 13))
+	if len(o) != 1 || !o[0].End.Equal(date(2025, 3, 13)) {
+		t.Errorf("all day: the end date is inclusive, got %+v", o)
+	}
+}
+
+func TestExpandDaily(t *testing.T) {
+	ev := Event{
+		Start: date(2025, 1, 30, 8, 0), End: date(2025, 1, 30, 9, 0),
+		Rule: Rule{Frequency: Daily, Interval: 2},
+	}
+	assertStarts(t, "every 2 days", Expand(ev, date(2025, 2, 1), date(2025, 2, 6)),
+		"2025-02-01 08:00", "2025-02-03 08:00", "2025-02-05 08:00")
+
+	ev.Rule.Count = 3
+	assertStarts(t, "3 times", Expand(ev, date(2025, 1, 1), date(2025, 3, 1)),
+		"2025-01-30 08:00", "2025-02-01 08:00", "2025-02-03 08:00")
+
+	// The end date includes its whole day
+	ev.Rule.Count = 0
+	ev.Rule.Until = date(2025, 2, 3)
+	assertStarts(t, "until", Expand(ev, date(2025, 1, 1), date(2025, 3, 1)),
+		"2025-01-30 08:00", "2025-02-01 08:00", "2025-02-03 08:00")
+}
+
+func TestExpandWeekly(t *testing.T) {
+	// 2025-03-05 is a Wednesday, the event is on Fridays every 2 weeks
+	ev := Event{
+		Start: date(2025, 3, 5, 18, 0), End: date(2025, 3, 5, 19, 0),
+		Rule: Rule{Frequency: Weekly, Interval: 2, Weekday: 5},
+	}
+	assertStarts(t, "fridays", Expand(ev, date(2025, 3, 1), date(2025, 4, 1)),
+		"2025-03-07 18:00", "2025-03-21 18:00")
+
+	// 0: the day of the start
+	ev.Rule = Rule{Frequency: Weekly, Weekday: 0, Count: 2}
+	assertStarts(t, "same day", Expand(ev, date(2025, 3, 1), date(2025, 4, 1)),
+		"2025-03-05 18:00", "2025-03-12 18:00")
+
+	// 7 is Sunday
+	ev.Rule = Rule{Frequency: Weekly, Weekday: 7, Count: 1}
+	assertStarts(t, "sunday", Expand(ev, date(2025, 3, 1), date(2025, 4, 1)), "2025-03-09 18:00")
+}
+
+func TestExpandMonthly(t *testing.T) {
+	ev := Event{
+		Start: date(2025, 1, 31, 10, 0),
+		Rule:  Rule{Frequency: Monthly, Count: 4},
+	}
+	// The 31st falls on the last day of the shorter months
+	assertStarts(t, "day 31", Expand(ev, date(2025, 1, 1), date(2026, 1, 1)),
+		"2025-01-31 10:00", "2025-02-28 10:00", "2025-03-31 10:00", "2025-04-30 10:00")
+
+	// The 5th, from the 20th: the first month has no occurrence
+	ev = Event{
+		Start: date(2025, 1, 20, 10, 0),
+		Rule:  Rule{Frequency: Monthly, Interval: 2, MonthDay: 5, Count: 2},
+	}
+	assertStarts(t, "day 5", Expand(ev, date(2025, 1, 1), date(2026, 1, 1)),
+		"2025-03-05 10:00", "2025-05-05 10:00")
+
+	// Second Tuesday and last Friday
+	ev = Event{
+		Start: date(2025, 1, 1, 21, 0),
+		Rule:  Rule{Frequency: Monthly, WeekNumber: 2, Weekday: 2},
+	}
+	assertStarts(t, "second tuesday", Expand(ev, date(2025, 1, 1), date(2025, 4, 1)),
+		"2025-01-14 21:00", "2025-02-11 21:00", "2025-03-11 21:00")
+	ev.Rule = Rule{Frequency: Monthly, WeekNumber: LastWeek, Weekday: 5}
+	assertStarts(t, "last friday", Expand(ev, date(2025, 1, 1), date(2025, 4, 1)),
+		"2025-01-31 21:00", "2025-02-28 21:00", "2025-03-28 21:00")
+}
+
+func TestExpandYearly(t *testing.T) {
+	birthday := Event{Start: date(2024, 2, 29), AllDay: true, Rule: Rule{Frequency: Yearly}}
+	assertStarts(t, "29 february", Expand(birthday, date(2024, 1, 1), date(2027, 1, 1)),
+		"2024-02-29 00:00", "2025-02-28 00:00", "2026-02-28 00:00")
+
+	// Fourth Thursday of November
+	thanksgiving := Event{Start: date(2025, 1, 1), AllDay: true, Rule: Rule{Frequency: Yearly, Month: 11, WeekNumber: 4, Weekday: 4}}
+	assertStarts(t, "thanksgiving", Expand(thanksgiving, date(2025, 1, 1), date(2027, 1, 1)),
+		"2025-11-27 00:00", "2026-11-26 00:00")
+
+	dayOfYear := Event{Start: date(2025, 1, 1, 12, 0), Rule: Rule{Frequency: Yearly, YearDay: 100, Until: date(2026, 12, 31)}}
+	assertStarts(t, "100th day", Expand(dayOfYear, date(2020, 1, 1), date(2030, 1, 1)),
+		"2025-04-10 12:00", "2026-04-10 12:00")
+
+	explicit := Event{Start: date(2025, 1, 1), AllDay: true, Rule: Rule{Frequency: Yearly, Month: 12, MonthDay: 25, Count: 2}}
+	assertStarts(t, "christmas", Expand(explicit, date(2020, 1, 1), date(2030, 1, 1)),
+		"2025-12-25 00:00", "2026-12-25 00:00")
+}
+
+func TestExpandLimits(t *testing.T) {
+	defer func(max int) { MaxOccurrences = max }(MaxOccurrences)
+	MaxOccurrences = 10
+	ev := Event{Start: date(2025, 1, 1, 8, 0), Rule: Rule{Frequency: Daily}}
+	if o := Expand(ev, date(2025, 1, 1), date(2026, 1, 1)); len(o) != 10 || o[9].Index != 10 {
+		t.Errorf("got %d occurrences", len(o))
+	}
+	if o := Expand(ev, date(2025, 2, 1), date(2025, 1, 1)); len(o) != 0 {
+		t.Errorf("empty window: got %v", o)
+	}
+}
+
+func TestEventFromDB(t *testing.T) {
+	dbe := dblayer.NewDBEvent()
+	dbe.SetTime("start_date", date(2025, 3, 5, 18, 0))
+	dbe.SetTime("end_date", date(2025, 3, 5, 19, 0))
+	dbe.SetValue("all_day", "0")
+	dbe.SetValue("recurrence_type", "2")
+	dbe.SetInt("weekly_every_x", 2)
+	dbe.SetValue("weekly_day_of_the_week", "5")
+	dbe.SetInt("recurrence_times", 3)
+	dbe.SetTime("recurrence_end_date", time.Time{})
+
+	if ev := EventFromDB(dbe); ev.Rule.Frequency != None {
+		t.Errorf("without the recurrence flag: got %+v", ev.Rule)
+	}
+	dbe.SetValue("recurrence", "1")
+	ev := EventFromDB(dbe)
+	if ev.AllDay || ev.Rule.Frequency != Weekly || ev.Rule.Interval != 2 || ev.Rule.Weekday != 5 || ev.Rule.Count != 3 || !ev.Rule.Until.IsZero() {
+		t.Errorf("got %+v", ev)
+	}
+}
@@ -0,0 +1,303 @@
+package calendar
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Conversion between the Rule and the RRULE of iCalendar.
+
+The RRULEs that the r-prj model cannot represent (ie. BYDAY=MO,WE,FR or BYHOUR)
+are imported approximated, with a warning.
+*/
+
+var icsWeekdays = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+func icsWeekday(day time.Weekday) string {
+	return icsWeekdays[day]
+}
+
+// The ISO day (1 Monday ... 7 Sunday) of a two letters day, 0 if invalid
+func parseICSWeekday(s string) int {
+	for i, name := range icsWeekdays {
+		if name == s {
+			if i == 0 {
+				return 7
+			}
+			return i
+		}
+	}
+	return 0
+}
+
+// BYDAY=2TU, -1FR for the last week
+func nthICSWeekday(n int, day time.Weekday) string {
+	if n >= LastWeek {
+		return "-1" + icsWeekday(day)
+	}
+	return strconv.Itoa(n) + icsWeekday(day)
+}
+
+/*
+The day of the month: the days after the 28th fall on the last day of the shorter months,
+as the RRULE skips them the last of the days from the 28th is taken
+*/
+func byMonthDay(day int) string {
+	if day <= 28 {
+		return "BYMONTHDAY=" + strconv.Itoa(day)
+	}
+	days := make([]string, 0)
+	for d := 28; d <= day; d++ {
+		days = append(days, strconv.Itoa(d))
+	}
+	return "BYMONTHDAY=" + strings.Join(days, ",") + ";BYSETPOS=-1"
+}
+
+/*
+Returns the RRULE of the rule of an event starting at start, "" if the rule is None
+*/
+func (rule Rule) RRULE(start time.Time, allDay bool) string {
+	parts := make([]string, 0)
+	switch rule.Frequency {
+	case Daily:
+		parts = append(parts, "FREQ=DAILY")
+	case Weekly:
+		parts = append(parts, "FREQ=WEEKLY")
+	case Monthly:
+		parts = append(parts, "FREQ=MONTHLY")
+	case Yearly:
+		parts = append(parts, "FREQ=YEARLY")
+	default:
+		return ""
+	}
+	if rule.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(rule.Interval))
+	}
+
+	weekday := isoWeekday(rule.Weekday, start.Weekday())
+	day := rule.MonthDay
+	if day == 0 {
+		day = start.Day()
+	}
+	switch rule.Frequency {
+	case Weekly:
+		parts = append(parts, "BYDAY="+icsWeekday(weekday))
+	case Monthly:
+		if rule.WeekNumber > 0 {
+			parts = append(parts, "BYDAY="+nthICSWeekday(rule.WeekNumber, weekday))
+		} else {
+			parts = append(parts, byMonthDay(day))
+		}
+	case Yearly:
+		if rule.YearDay > 0 {
+			parts = append(parts, "BYYEARDAY="+strconv.Itoa(rule.YearDay))
+			break
+		}
+		month := rule.Month
+		if month < 1 || month > 12 {
+			month = int(start.Month())
+		}
+		parts = append(parts, "BYMONTH="+strconv.Itoa(month))
+		if rule.WeekNumber > 0 {
+			parts = append(parts, "BYDAY="+nthICSWeekday(rule.WeekNumber, weekday))
+		} else {
+			parts = append(parts, byMonthDay(day))
+		}
+	}
+
+	if rule.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(rule.Count))
+	} else if limit, ok := rule.limit(); ok {
+		// In the same form of DTSTART: a date, or a floating local time
+		if allDay {
+			parts = append(parts, "UNTIL="+limit.Format(icsDateFormat))
+		} else {
+			parts = append(parts, "UNTIL="+limit.Format(icsDateTimeFormat))
+		}
+	}
+	return strings.Join(parts, ";")
+}
+
+// Parses a list of integers, ie. 28,29,30
+func parseInts(value string) ([]int, error) {
+	ret := make([]int, 0)
+	for _, s := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimPrefix(s, "+"))
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, n)
+	}
+	return ret, nil
+}
+
+/*
+Parses a BYDAY item: 2TU, -1FR, MO. Returns the week number (0 if none, LastWeek for -1)
+and the ISO day
+*/
+func parseByDay(item string) (week int, weekday int, err error) {
+	if len(item) < 2 {
+		return 0, 0, fmt.Errorf("%w: BYDAY '%s'", ErrInvalidICS, item)
+	}
+	weekday = parseICSWeekday(item[len(item)-2:])
+	if weekday == 0 {
+		return 0, 0, fmt.Errorf("%w: BYDAY '%s'", ErrInvalidICS, item)
+	}
+	if prefix := item[:len(item)-2]; prefix != "" {
+		week, err = strconv.Atoi(strings.TrimPrefix(prefix, "+"))
+		if err != nil {
+			return 0, 0, fmt.Errorf("%w: BYDAY '%s'", ErrInvalidICS, item)
+		}
+		if week < 0 || week > LastWeek {
+			week = LastWeek
+		}
+	}
+	return week, weekday, nil
+}
+
+/*
+Parses a RRULE. The parts that the Rule cannot represent
+are approximated or ignored, returning the warnings; an unsupported frequency is an error.
+*/
+func ParseRRULE(value string) (Rule, []string, error) {
+	rule := Rule{}
+	warnings := make([]string, 0)
+	parts := make(map[string]string)
+	for _, part := range strings.Split(strings.ToUpper(strings.TrimSpace(value)), ";") {
+		name, v, found := strings.Cut(part, "=")
+		if !found {
+			return rule, warnings, fmt.Errorf("%w: RRULE '%s'", ErrInvalidICS, value)
+		}
+		parts[name] = v
+	}
+
+	switch parts["FREQ"] {
+	case "DAILY":
+		rule.Frequency = Daily
+	case "WEEKLY":
+		rule.Frequency = Weekly
+	case "MONTHLY":
+		rule.Frequency = Monthly
+	case "YEARLY":
+		rule.Frequency = Yearly
+	default:
+		return rule, warnings, fmt.Errorf("%w: unsupported RRULE frequency '%s'", ErrInvalidICS, parts["FREQ"])
+	}
+
+	// The r-prj has no interval for the yearly events
+	if v, ok := parts["INTERVAL"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return rule, warnings, fmt.Errorf("%w: RRULE INTERVAL '%s'", ErrInvalidICS, v)
+		}
+		if rule.Frequency == Yearly && n > 1 {
+			warnings = append(warnings, fmt.Sprintf("INTERVAL=%d not supported for the yearly events: every year", n))
+		} else if n > 1 {
+			rule.Interval = n
+		}
+	}
+	if v, ok := parts["COUNT"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return rule, warnings, fmt.Errorf("%w: RRULE COUNT '%s'", ErrInvalidICS, v)
+		}
+		rule.Count = n
+	}
+	if v, ok := parts["UNTIL"]; ok {
+		until, _, err := ParseICSTime(&Property{Name: "UNTIL", Value: v})
+		if err != nil {
+			return rule, warnings, fmt.Errorf("%w: RRULE UNTIL '%s'", ErrInvalidICS, v)
+		}
+		rule.Until = until
+	}
+
+	if v, ok := parts["BYDAY"]; ok {
+		items := strings.Split(v, ",")
+		if len(items) > 1 {
+			warnings = append(warnings, fmt.Sprintf("BYDAY=%s: only one day is supported, using %s", v, items[0]))
+		}
+		week, weekday, err := parseByDay(items[0])
+		if err != nil {
+			return rule, warnings, err
+		}
+		switch {
+		case rule.Frequency == Weekly:
+			rule.Weekday = weekday
+		case rule.Frequency == Daily:
+			warnings = append(warnings, "BYDAY ignored for the daily events")
+		case week == 0:
+			warnings = append(warnings, fmt.Sprintf("BYDAY=%s: every week of the month not supported, using the first one", v))
+			rule.WeekNumber = 1
+			rule.Weekday = weekday
+		default:
+			rule.WeekNumber = week
+			rule.Weekday = weekday
+		}
+	}
+	if v, ok := parts["BYMONTHDAY"]; ok && rule.WeekNumber == 0 {
+		days, err := parseInts(v)
+		if err != nil {
+			return rule, warnings, fmt.Errorf("%w: RRULE BYMONTHDAY '%s'", ErrInvalidICS, v)
+		}
+		sort.Ints(days)
+		day := days[0]
+		if parts["BYSETPOS"] == "-1" {
+			// The last of the days present in the month, ie. 28,29,30,31
+			day = days[len(days)-1]
+		} else if len(days) > 1 {
+			warnings = append(warnings, fmt.Sprintf("BYMONTHDAY=%s: only one day is supported, using %d", v, day))
+		}
+		switch {
+		case day == -1:
+			// The last day of the month
+			day = 31
+		case day < 1 || day > 31:
+			warnings = append(warnings, fmt.Sprintf("BYMONTHDAY=%s not supported, using the day of the start", v))
+			day = 0
+		}
+		if rule.Frequency == Monthly || rule.Frequency == Yearly {
+			rule.MonthDay = day
+		} else {
+			warnings = append(warnings, "BYMONTHDAY ignored for the daily and weekly events")
+		}
+	}
+	if v, ok := parts["BYMONTH"]; ok {
+		months, err := parseInts(v)
+		if err != nil || months[0] < 1 || months[0] > 12 {
+			return rule, warnings, fmt.Errorf("%w: RRULE BYMONTH '%s'", ErrInvalidICS, v)
+		}
+		if len(months) > 1 {
+			warnings = append(warnings, fmt.Sprintf("BYMONTH=%s: only one month is supported, using %d", v, months[0]))
+		}
+		if rule.Frequency == Yearly {
+			rule.Month = months[0]
+		} else {
+			warnings = append(warnings, "BYMONTH ignored for the daily, weekly and monthly events")
+		}
+	}
+	if v, ok := parts["BYYEARDAY"]; ok {
+		days, err := parseInts(v)
+		if err != nil {
+			return rule, warnings, fmt.Errorf("%w: RRULE BYYEARDAY '%s'", ErrInvalidICS, v)
+		}
+		if rule.Frequency == Yearly && days[0] >= 1 && days[0] <= 366 && len(days) == 1 {
+			rule.YearDay = days[0]
+		} else {
+			warnings = append(warnings, fmt.Sprintf("BYYEARDAY=%s not supported", v))
+		}
+	}
+
+	for _, name := range []string{"BYSECOND", "BYMINUTE", "BYHOUR", "BYWEEKNO"} {
+		if v, ok := parts[name]; ok {
+			warnings = append(warnings, fmt.Sprintf("%s=%s not supported", name, v))
+		}
+	}
+	if v, ok := parts["BYSETPOS"]; ok && (v != "-1" || parts["BYMONTHDAY"] == "") {
+		warnings = append(warnings, fmt.Sprintf("BYSETPOS=%s not supported", v))
+	}
+	return rule, warnings, nil
+}
@@ -1,12 +1,25 @@
 package db
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
 	"log"
 	"time"
+
+	"rprj/be/dblayer"
+	"rprj/be/models"
 )
 
-func SaveToken(userID string, tokenString string, expiry int64) error {
-	_, err := DB.Exec(
+var ErrRefreshTokenInvalid = errors.New("invalid refresh token")
+var ErrRefreshTokenExpired = errors.New("refresh token expired")
+var ErrRefreshTokenReused = errors.New("refresh token reused: session revoked")
+
+func SaveToken(ctx context.Context, userID string, tokenString string, expiry int64) error {
+	_, err := DB.ExecContext(ctx,
 		"INSERT INTO "+tablePrefix+"oauth_tokens (user_id, token_id, expires_at, access_token) VALUES (?, ?, ?, ?)",
 		userID, tokenString, time.Unix(expiry, 0).UTC(), tokenString,
 	)
@@ -16,10 +29,126 @@ func SaveToken(userID string, tokenString string, expiry int64) error {
 	return err
 }
 
-func IsTokenValid(tokenString string, userID string) bool {
+// NewRefreshToken generates a random opaque refresh token
+func NewRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// NewTokenFamily generates the id shared by the token pairs of a login
+func NewTokenFamily() (string, error) {
+	return dblayer.UUID16Hex()
+}
+
+// Only the hash of the refresh token is stored
+func hashRefreshToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
+
+// The times of the tokens are written in UTC
+func parseTokenTime(value sql.NullString) (time.Time, bool) {
+	if !value.Valid {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(dblayer.DateTimeFormat, value.String, time.UTC)
+	return t, err == nil
+}
+
+// SaveTokenPair stores an access token with its refresh token, in the given family
+func SaveTokenPair(ctx context.Context, userID string, tokenString string, expiry int64, refreshToken string, refreshExpiry int64, familyID string) error {
+	_, err := DB.ExecContext(ctx,
+		"INSERT INTO "+tablePrefix+"oauth_tokens (user_id, token_id, expires_at, access_token, refresh_token, refresh_expires_at, family_id, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		userID, tokenString, time.Unix(expiry, 0).UTC(), tokenString,
+		hashRefreshToken(refreshToken), time.Unix(refreshExpiry, 0).UTC(), familyID, time.Now().UTC(),
+	)
+	if err != nil {
+		log.Println("Errore salvataggio token:", err)
+	}
+	return err
+}
+
+/*
+UseRefreshToken consumes a refresh token and returns its user and family,
+to issue the next pair with SaveTokenPair.
+The old pair is marked as rotated, so its access token is no longer valid.
+A refresh token used twice revokes the whole family: it has been stolen, or the client is broken.
+*/
+func UseRefreshToken(ctx context.Context, refreshToken string) (userID string, familyID string, err error) {
+	hash := hashRefreshToken(refreshToken)
+
+	var tokenID string
+	var family sql.NullString
+	var refreshExpiry sql.NullString
+	var rotated sql.NullString
+	err = DB.QueryRowContext(ctx,
+		"SELECT token_id, user_id, family_id, refresh_expires_at, rotated_at FROM "+tablePrefix+"oauth_tokens WHERE refresh_token = ?",
+		hash,
+	).Scan(&tokenID, &userID, &family, &refreshExpiry, &rotated)
+	if err == sql.ErrNoRows {
+		return "", "", ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	if rotated.Valid {
+		log.Printf("Refresh token reused for user %s: revoking family %s", userID, family.String)
+		if err := RevokeTokenFamily(ctx, family.String, tokenID); err != nil {
+			return "", "", err
+		}
+		return "", "", ErrRefreshTokenReused
+	}
+	if expiry, ok := parseTokenTime(refreshExpiry); !ok || expiry.Before(time.Now()) {
+		return "", "", ErrRefreshTokenExpired
+	}
+
+	// Only one concurrent refresh can rotate the pair
+	res, err := DB.ExecContext(ctx,
+		"UPDATE "+tablePrefix+"oauth_tokens SET rotated_at = ? WHERE token_id = ? AND rotated_at IS NULL",
+		time.Now().UTC(), tokenID,
+	)
+	if err != nil {
+		return "", "", err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return "", "", err
+	} else if n == 0 {
+		if err := RevokeTokenFamily(ctx, family.String, tokenID); err != nil {
+			return "", "", err
+		}
+		return "", "", ErrRefreshTokenReused
+	}
+
+	if !family.Valid || family.String == "" {
+		// Pair saved before the rotation: starts a new family
+		if familyID, err = NewTokenFamily(); err != nil {
+			return "", "", err
+		}
+		return userID, familyID, nil
+	}
+	return userID, family.String, nil
+}
+
+// RevokeTokenFamily deletes all the token pairs of the family (and the token itself, if without family)
+func RevokeTokenFamily(ctx context.Context, familyID string, tokenID string) error {
+	_, err := DB.ExecContext(ctx,
+		"DELETE FROM "+tablePrefix+"oauth_tokens WHERE token_id = ? OR (family_id = ? AND family_id <> '')",
+		tokenID, familyID,
+	)
+	if err != nil {
+		log.Println("Errore revoca token:", err)
+	}
+	return err
+}
+
+func IsTokenValid(ctx context.Context, tokenString string, userID string) bool {
 	var count int
-	err := DB.QueryRow(
-		"SELECT COUNT(*) FROM "+tablePrefix+"oauth_tokens WHERE token_id = ? AND user_id = ?",
+	err := DB.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM "+tablePrefix+"oauth_tokens WHERE token_id = ? AND user_id = ? AND rotated_at IS NULL",
 		tokenString, userID,
 	).Scan(&count)
 	if err != nil {
@@ -29,8 +158,8 @@ func IsTokenValid(tokenString string, userID string) bool {
 	return count > 0
 }
 
-func DeleteToken(tokenString string) error {
-	_, err := DB.Exec(
+func DeleteToken(ctx context.Context, tokenString string) error {
+	_, err := DB.ExecContext(ctx,
 		"DELETE FROM "+tablePrefix+"oauth_tokens WHERE token_id = ?",
 		tokenString,
 	)
@@ -39,3 +168,140 @@ func DeleteToken(tokenString string) error {
 	}
 	return err
 }
+
+// Resolution of last_seen: avoids a write for every request
+const lastSeenResolution = time.Minute
+
+// TouchToken records the use of the token
+func TouchToken(ctx context.Context, tokenString string) {
+	now := time.Now().UTC()
+	_, err := DB.ExecContext(ctx,
+		"UPDATE "+tablePrefix+"oauth_tokens SET last_seen = ? WHERE token_id = ? AND (last_seen IS NULL OR last_seen < ?)",
+		now, tokenString, now.Add(-lastSeenResolution),
+	)
+	if err != nil {
+		log.Println("Errore aggiornamento token:", err)
+	}
+}
+
+/*
+PurgeExpiredTokens deletes the tokens that can no longer be used:
+access token expired and refresh token (if any) expired.
+The rotated pairs are kept until the refresh expiry, for the reuse detection.
+*/
+func PurgeExpiredTokens(ctx context.Context) (int64, error) {
+	now := time.Now().UTC()
+	res, err := DB.ExecContext(ctx,
+		"DELETE FROM "+tablePrefix+"oauth_tokens WHERE expires_at < ? AND (refresh_expires_at IS NULL OR refresh_expires_at < ?)",
+		now, now,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// StartTokenSweeper purges the expired tokens every interval, until stop is called
+func StartTokenSweeper(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				n, err := PurgeExpiredTokens(context.Background())
+				if err != nil {
+					log.Println("Errore pulizia token scaduti:", err)
+				} else if n > 0 {
+					log.Printf("Token scaduti eliminati: %d", n)
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// SessionID is the public id of a token: the token itself must never be exposed
+func SessionID(tokenString string) string {
+	sum := sha256.Sum256([]byte(tokenString))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// GetUserSessions returns the sessions of the user, ie. the pairs not rotated yet
+func GetUserSessions(ctx context.Context, userID string) ([]models.Session, error) {
+	rows, err := DB.QueryContext(ctx,
+		"SELECT token_id, created_at, expires_at, refresh_expires_at, last_seen FROM "+tablePrefix+"oauth_tokens WHERE user_id = ? AND rotated_at IS NULL ORDER BY created_at DESC",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var tokenID string
+		var createdAt, expiresAt, refreshExpiresAt, lastSeen sql.NullString
+		if err := rows.Scan(&tokenID, &createdAt, &expiresAt, &refreshExpiresAt, &lastSeen); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, models.Session{
+			ID:               SessionID(tokenID),
+			CreatedAt:        tokenTimePtr(createdAt),
+			ExpiresAt:        tokenTimePtr(expiresAt),
+			RefreshExpiresAt: tokenTimePtr(refreshExpiresAt),
+			LastSeen:         tokenTimePtr(lastSeen),
+		})
+	}
+	return sessions, rows.Err()
+}
+
+func tokenTimePtr(value sql.NullString) *time.Time {
+	if t, ok := parseTokenTime(value); ok {
+		return &t
+	}
+	return nil
+}
+
+// DeleteUserSessions revokes all the sessions of the user
+func DeleteUserSessions(ctx context.Context, userID string) error {
+	_, err := DB.ExecContext(ctx, "DELETE FROM "+tablePrefix+"oauth_tokens WHERE user_id = ?", userID)
+	if err != nil {
+		log.Println("Errore revoca sessioni:", err)
+	}
+	return err
+}
+
+// DeleteUserSession revokes a session (with its token family); returns false if not found
+func DeleteUserSession(ctx context.Context, userID string, sessionID string) (bool, error) {
+	rows, err := DB.QueryContext(ctx,
+		"SELECT token_id, family_id FROM "+tablePrefix+"oauth_tokens WHERE user_id = ? AND rotated_at IS NULL",
+		userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	var tokenID string
+	var familyID sql.NullString
+	found := false
+	for rows.Next() {
+		var id string
+		var family sql.NullString
+		if err := rows.Scan(&id, &family); err != nil {
+			rows.Close()
+			return false, err
+		}
+		if SessionID(id) == sessionID {
+			tokenID, familyID, found = id, family, true
+			break
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || !found {
+		return false, err
+	}
+	return true, RevokeTokenFamily(ctx, familyID.String, tokenID)
+}
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"rprj/be/dblayer"
+	"rprj/be/models"
+)
+
+var ErrCalendarFeedInvalid = errors.New("invalid calendar feed token")
+
+/*
+CreateCalendarFeed creates a feed token of the user: the token is returned only here,
+the db keeps its hash like for the refresh tokens
+*/
+func CreateCalendarFeed(ctx context.Context, userID string, name string) (*models.CalendarFeed, error) {
+	id, err := dblayer.UUID16Hex()
+	if err != nil {
+		return nil, err
+	}
+	token, err := NewRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	_, err = DB.ExecContext(ctx,
+		"INSERT INTO "+tablePrefix+"calendar_feeds (id, user_id, token_hash, name, created_at) VALUES (?, ?, ?, ?, ?)",
+		id, userID, hashRefreshToken(token), name, now,
+	)
+	if err != nil {
+		log.Println("Errore creazione feed calendario:", err)
+		return nil, err
+	}
+	return &models.CalendarFeed{ID: id, Name: name, CreatedAt: &now, Token: token}, nil
+}
+
+// GetCalendarFeeds returns the feeds of the user, without the tokens
+func GetCalendarFeeds(ctx context.Context, userID string) ([]models.CalendarFeed, error) {
+	rows, err := DB.QueryContext(ctx,
+		"SELECT id, name, created_at, last_used FROM "+tablePrefix+"calendar_feeds WHERE user_id = ? ORDER BY created_at DESC",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feeds := []models.CalendarFeed{}
+	for rows.Next() {
+		var feed models.CalendarFeed
+		var createdAt, lastUsed sql.NullString
+		if err := rows.Scan(&feed.ID, &feed.Name, &createdAt, &lastUsed); err != nil {
+			return nil, err
+		}
+		feed.CreatedAt = tokenTimePtr(createdAt)
+		feed.LastUsed = tokenTimePtr(lastUsed)
+		feeds = append(feeds, feed)
+	}
+	return feeds, rows.Err()
+}
+
+// DeleteCalendarFeed revokes a feed of the user; returns false if not found
+func DeleteCalendarFeed(ctx context.Context, userID string, feedID string) (bool, error) {
+	res, err := DB.ExecContext(ctx, "DELETE FROM "+tablePrefix+"calendar_feeds WHERE id = ? AND user_id = ?", feedID, userID)
+	if err != nil {
+		log.Println("Errore revoca feed calendario:", err)
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// CalendarFeedUser returns the user of a feed token, and records its use
+func CalendarFeedUser(ctx context.Context, token string) (string, error) {
+	hash := hashRefreshToken(token)
+	var userID string
+	err := DB.QueryRowContext(ctx,
+		"SELECT user_id FROM "+tablePrefix+"calendar_feeds WHERE token_hash = ?",
+		hash,
+	).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return "", ErrCalendarFeedInvalid
+	}
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	_, err = DB.ExecContext(ctx,
+		"UPDATE "+tablePrefix+"calendar_feeds SET last_used = ? WHERE token_hash = ? AND (last_used IS NULL OR last_used < ?)",
+		now, hash, now.Add(-lastSeenResolution),
+	)
+	if err != nil {
+		log.Println("Errore aggiornamento feed calendario:", err)
+	}
+	return userID, nil
+}
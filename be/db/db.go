@@ -1,11 +1,13 @@
 package db
 
 import (
-	"crypto/rand"
+	"context"
 	"database/sql"
-	"encoding/hex"
 	"fmt"
 	"log"
+	"strings"
+
+	"rprj/be/dblayer"
 
 	_ "github.com/go-sql-driver/mysql"
 )
@@ -15,6 +17,9 @@ var tablePrefix string
 
 var DB *sql.DB
 
+// All the entities of the project
+var Factory = dblayer.NewDefaultDBEFactory(false)
+
 // TestConnection apre la connessione, esegue la query e stampa il risultato
 func TestConnection(dbURL string) {
 	// URL di connessione
@@ -62,11 +67,29 @@ func Init(url string, prefix string) {
 	log.Println("Connessione a MariaDB riuscita!")
 }
 
-/* Generate a random UUID-like string of 16 hex characters */
-func uuid16HexGo() (string, error) {
-	b := make([]byte, 8) // 8 bytes = 16 hex chars
-	if _, err := rand.Read(b); err != nil {
-		return "", err
+/*
+Returns the DBContext of a user, with the schema taken from the table prefix
+*/
+func NewDBContext(userID string, groupIDs []string) *dblayer.DBContext {
+	return &dblayer.DBContext{
+		UserID:   userID,
+		GroupIDs: groupIDs,
+		Schema:   strings.TrimSuffix(tablePrefix, "_"),
+	}
+}
+
+/*
+Returns a DBRepository on the shared connection for the user carried by ctx
+(see dblayer.NewContext), anonymous if none.
+*/
+func NewDBRepository(ctx context.Context) *dblayer.DBRepository {
+	dbContext := dblayer.FromContext(ctx)
+	if dbContext == nil {
+		dbContext = NewDBContext("", []string{})
+	} else if dbContext.Schema == "" {
+		withSchema := *dbContext
+		withSchema.Schema = strings.TrimSuffix(tablePrefix, "_")
+		dbContext = &withSchema
 	}
-	return hex.EncodeToString(b), nil
+	return dblayer.NewDBRepository(dbContext, Factory, DB).WithContext(ctx)
 }
@@ -0,0 +1,33 @@
+package db
+
+import (
+	"database/sql"
+
+	"rprj/be/storage"
+)
+
+/*
+FileReferences returns the contents referenced by the DBFiles, the deleted ones too:
+the blob of a file in the trash must survive until the file is purged.
+*/
+func FileReferences() ([]storage.Reference, error) {
+	rows, err := DB.Query(
+		"SELECT id, path, checksum FROM " + tablePrefix + "files WHERE path IS NOT NULL AND path <> '' ORDER BY path, id",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	refs := make([]storage.Reference, 0)
+	for rows.Next() {
+		var ref storage.Reference
+		var checksum sql.NullString
+		if err := rows.Scan(&ref.FileID, &ref.Key, &checksum); err != nil {
+			return nil, err
+		}
+		ref.Checksum = checksum.String
+		refs = append(refs, ref)
+	}
+	return refs, rows.Err()
+}
@@ -1,16 +1,18 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 
+	"rprj/be/dblayer"
 	"rprj/be/models"
 )
 
 // CREATE (deprecated - use CreateGroupWithTransaction)
 // func CreateGroup(g models.DBGroup) (string, error) {
 // 	if g.ID == "" {
-// 		newID, _ := uuid16HexGo()
+// 		newID, _ := dblayer.UUID16Hex()
 // 		log.Print("newID=", newID)
 // 		g.ID = newID
 // 	}
@@ -22,8 +24,8 @@ import (
 // }
 
 // READ
-func GetGroupByID(id string) (*models.DBGroup, error) {
-	row := DB.QueryRow(
+func GetGroupByID(ctx context.Context, id string) (*models.DBGroup, error) {
+	row := DB.QueryRowContext(ctx,
 		"SELECT id, name, description FROM "+tablePrefix+"groups WHERE id = ?",
 		id,
 	)
@@ -49,21 +51,21 @@ func GetGroupByID(id string) (*models.DBGroup, error) {
 // }
 
 // DELETE
-func DeleteGroup(id string) error {
-	tx, err := DB.Begin()
+func DeleteGroup(ctx context.Context, id string) error {
+	tx, err := DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
 	// Delete group-user associations
-	_, err = tx.Exec("DELETE FROM "+tablePrefix+"users_groups WHERE group_id=?", id)
+	_, err = tx.ExecContext(ctx, "DELETE FROM "+tablePrefix+"users_groups WHERE group_id=?", id)
 	if err != nil {
 		return err
 	}
 
 	// Delete group
-	_, err = tx.Exec("DELETE FROM "+tablePrefix+"groups WHERE id=?", id)
+	_, err = tx.ExecContext(ctx, "DELETE FROM "+tablePrefix+"groups WHERE id=?", id)
 	if err != nil {
 		return err
 	}
@@ -71,45 +73,42 @@ func DeleteGroup(id string) error {
 	return tx.Commit()
 }
 
-// SEARCH
-func SearchGroupsBy(search string, orderBy string) ([]models.DBGroup, error) {
-	query := "SELECT id, name, description FROM " + tablePrefix + "groups"
-	if search != "" {
-		query += " WHERE name LIKE ? OR description LIKE ?"
-	}
-	if orderBy != "" {
-		query += " ORDER BY " + orderBy
-	}
+// Columns of the groups accepted by order_by
+var groupOrderColumns = []string{"id", "name", "description"}
 
-	likePattern := "%" + search + "%"
+// SEARCH: returns a page of the groups, their total count and the cursor of the next page
+func SearchGroupsBy(ctx context.Context, search string, orderBy string, page dblayer.PageRequest) ([]models.DBGroup, int, string, error) {
+	order, err := parseOrder(orderBy, "name", groupOrderColumns)
+	if err != nil {
+		return nil, 0, "", err
+	}
 
-	var rows *sql.Rows
-	var err error
+	query := "SELECT id, name, description FROM " + tablePrefix + "groups"
+	args := []interface{}{}
 	if search != "" {
-		rows, err = DB.Query(query, likePattern, likePattern)
-	} else {
-		rows, err = DB.Query(query)
-	}
-	if err != nil {
-		return nil, err
+		query += " WHERE name LIKE ? OR description LIKE ?"
+		likePattern := "%" + search + "%"
+		args = append(args, likePattern, likePattern)
 	}
-	defer rows.Close()
 
-	var groups []models.DBGroup
-	for rows.Next() {
+	groups := []models.DBGroup{}
+	total, nextCursor, err := queryPage(ctx, query, args, order, page, func(rows *sql.Rows) (map[string]string, error) {
 		var g models.DBGroup
-		err := rows.Scan(&g.ID, &g.Name, &g.Description)
-		if err != nil {
+		if err := rows.Scan(&g.ID, &g.Name, &g.Description); err != nil {
 			return nil, err
 		}
 		groups = append(groups, g)
+		return map[string]string{"id": g.ID, "name": g.Name, "description": g.Description}, nil
+	})
+	if err != nil {
+		return nil, 0, "", err
 	}
-	return groups, nil
+	return groups, total, nextCursor, nil
 }
 
 // CreateGroup creates a group and user associations in a single transaction
-func CreateGroup(g models.DBGroup, userIDs []string) (*models.DBGroup, error) {
-	tx, err := DB.Begin()
+func CreateGroup(ctx context.Context, g models.DBGroup, userIDs []string) (*models.DBGroup, error) {
+	tx, err := DB.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -117,7 +116,7 @@ func CreateGroup(g models.DBGroup, userIDs []string) (*models.DBGroup, error) {
 
 	// Check that group with same name does not already exist
 	var existingGroupID string
-	err = tx.QueryRow("SELECT id FROM "+tablePrefix+"groups WHERE name = ?", g.Name).Scan(&existingGroupID)
+	err = tx.QueryRowContext(ctx, "SELECT id FROM "+tablePrefix+"groups WHERE name = ?", g.Name).Scan(&existingGroupID)
 	if err != sql.ErrNoRows {
 		if err == nil {
 			return nil, fmt.Errorf("group with name '%s' already exists", g.Name)
@@ -126,11 +125,11 @@ func CreateGroup(g models.DBGroup, userIDs []string) (*models.DBGroup, error) {
 	}
 
 	// Generate ID
-	groupID, _ := uuid16HexGo()
+	groupID, _ := dblayer.UUID16Hex()
 	g.ID = groupID
 
 	// Create group
-	_, err = tx.Exec(
+	_, err = tx.ExecContext(ctx,
 		"INSERT INTO "+tablePrefix+"groups (id, name, description) VALUES (?, ?, ?)",
 		g.ID, g.Name, g.Description,
 	)
@@ -140,7 +139,7 @@ func CreateGroup(g models.DBGroup, userIDs []string) (*models.DBGroup, error) {
 
 	// Add users to group
 	for _, userID := range userIDs {
-		_, err = tx.Exec(
+		_, err = tx.ExecContext(ctx,
 			"INSERT INTO "+tablePrefix+"users_groups (user_id, group_id) VALUES (?, ?)",
 			userID, groupID,
 		)
@@ -158,15 +157,15 @@ func CreateGroup(g models.DBGroup, userIDs []string) (*models.DBGroup, error) {
 }
 
 // UpdateGroup updates group and user associations in a single transaction
-func UpdateGroup(g models.DBGroup, userIDs []string) error {
-	tx, err := DB.Begin()
+func UpdateGroup(ctx context.Context, g models.DBGroup, userIDs []string) error {
+	tx, err := DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
 	// Update group
-	_, err = tx.Exec(
+	_, err = tx.ExecContext(ctx,
 		"UPDATE "+tablePrefix+"groups SET name = ?, description = ? WHERE id = ?",
 		g.Name, g.Description, g.ID,
 	)
@@ -175,14 +174,14 @@ func UpdateGroup(g models.DBGroup, userIDs []string) error {
 	}
 
 	// Delete all existing user associations
-	_, err = tx.Exec("DELETE FROM "+tablePrefix+"users_groups WHERE group_id=?", g.ID)
+	_, err = tx.ExecContext(ctx, "DELETE FROM "+tablePrefix+"users_groups WHERE group_id=?", g.ID)
 	if err != nil {
 		return err
 	}
 
 	// Recreate user associations
 	for _, userID := range userIDs {
-		_, err = tx.Exec(
+		_, err = tx.ExecContext(ctx,
 			"INSERT INTO "+tablePrefix+"users_groups (user_id, group_id) VALUES (?, ?)",
 			userID, g.ID,
 		)
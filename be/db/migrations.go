@@ -0,0 +1,140 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"strings"
+
+	"rprj/be/dblayer"
+)
+
+/*
+The migrations of the project, per model.
+
+Version 2 of "rprj" is the schema of db/00_initial.sql: on a fresh db the first two
+migrations create the tables and the system users and groups.
+The statements of the migrations are frozen: a change of the entities needs a new migration,
+added at the end with the next version, ie.
+
+	{ModelName: "rprj", Version: <last version + 1>, Description: "users language",
+		Statements: []string{"ALTER TABLE {prefix}users ADD COLUMN language varchar(5) DEFAULT 'en_us'"}},
+*/
+// The DDL of migration 1: the tables of db/00_initial.sql
+//
+//go:embed migrations_v1.sql
+var migrationV1SQL string
+
+/*
+Splits a SQL script in its statements, separated by ";": the lines starting with "--" are comments
+*/
+func sqlStatements(script string) []string {
+	lines := make([]string, 0)
+	for _, line := range strings.Split(script, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			lines = append(lines, line)
+		}
+	}
+	statements := make([]string, 0)
+	for _, statement := range strings.Split(strings.Join(lines, "\n"), ";") {
+		if statement = strings.TrimSpace(statement); statement != "" {
+			statements = append(statements, statement)
+		}
+	}
+	return statements
+}
+
+var Migrations = []dblayer.Migration{
+	{
+		ModelName:   "rprj",
+		Version:     1,
+		Description: "create the tables",
+		Statements:  sqlStatements(migrationV1SQL),
+	},
+	{
+		ModelName:   "rprj",
+		Version:     2,
+		Description: "system groups and administrator",
+		Statements: []string{
+			"INSERT IGNORE INTO {prefix}groups (id, name, description) VALUES " +
+				"('-2','Admin','System admins'), " +
+				"('-3','Users','System users'), " +
+				"('-4','Guests','System guests (read only)'), " +
+				"('-5','Project','R-Project user'), " +
+				"('-6','Webmaster','Web content creators')",
+			"INSERT IGNORE INTO {prefix}users (id, login, pwd, pwd_salt, fullname, group_id) VALUES " +
+				"('-1','adm','mysecretpass','','Administrator','-2')",
+			"INSERT IGNORE INTO {prefix}users_groups (user_id, group_id) VALUES " +
+				"('-1','-2'), ('-1','-5'), ('-1','-6')",
+		},
+	},
+	{
+		ModelName:   "rprj",
+		Version:     3,
+		Description: "refresh token rotation",
+		Statements: []string{
+			"ALTER TABLE {prefix}oauth_tokens " +
+				"ADD COLUMN IF NOT EXISTS family_id varchar(16) DEFAULT NULL, " +
+				"ADD COLUMN IF NOT EXISTS refresh_expires_at datetime DEFAULT NULL, " +
+				"ADD COLUMN IF NOT EXISTS rotated_at datetime DEFAULT NULL",
+		},
+	},
+	{
+		ModelName:   "rprj",
+		Version:     4,
+		Description: "last use of the sessions",
+		Statements: []string{
+			"ALTER TABLE {prefix}oauth_tokens ADD COLUMN IF NOT EXISTS last_seen datetime DEFAULT NULL",
+		},
+	},
+	{
+		ModelName:   "rprj",
+		Version:     5,
+		Description: "full text search index",
+		Statements: []string{
+			"CREATE TABLE IF NOT EXISTS `{prefix}search_index` (\n" +
+				"  `id` varchar(16) NOT NULL,\n" +
+				"  `classname` varchar(255) NOT NULL,\n" +
+				"  `owner` varchar(16) NOT NULL,\n" +
+				"  `group_id` varchar(16) NOT NULL,\n" +
+				"  `permissions` char(9) NOT NULL DEFAULT 'rwx------',\n" +
+				"  `deleted_date` datetime NOT NULL DEFAULT '0000-00-00 00:00:00',\n" +
+				"  `last_modify_date` datetime DEFAULT NULL,\n" +
+				"  `name` varchar(255) NOT NULL,\n" +
+				"  `body` mediumtext DEFAULT NULL,\n" +
+				"  PRIMARY KEY (`id`),\n" +
+				"  KEY `{prefix}search_index_0` (`id`)\n" +
+				") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci",
+			"ALTER TABLE {prefix}search_index ADD FULLTEXT INDEX IF NOT EXISTS {prefix}search_index_ft_name (name)",
+			"ALTER TABLE {prefix}search_index ADD FULLTEXT INDEX IF NOT EXISTS {prefix}search_index_ft (name, body)",
+		},
+		// The index of the objects already in the db
+		Apply: func(dbr *dblayer.DBRepository, tx *sql.Tx) error {
+			_, err := dbr.RebuildSearchIndex(tx)
+			return err
+		},
+	},
+	{
+		ModelName:   "rprj",
+		Version:     6,
+		Description: "calendar feeds",
+		Statements: []string{
+			"CREATE TABLE IF NOT EXISTS `{prefix}calendar_feeds` (\n" +
+				"  `id` varchar(16) NOT NULL,\n" +
+				"  `user_id` varchar(16) NOT NULL,\n" +
+				"  `token_hash` char(64) NOT NULL,\n" +
+				"  `name` varchar(255) NOT NULL DEFAULT '',\n" +
+				"  `created_at` datetime DEFAULT NULL,\n" +
+				"  `last_used` datetime DEFAULT NULL,\n" +
+				"  PRIMARY KEY (`id`),\n" +
+				"  KEY `{prefix}calendar_feeds_0` (`id`),\n" +
+				"  KEY `{prefix}calendar_feeds_1` (`user_id`)\n" +
+				") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci",
+		},
+	},
+}
+
+// Returns the migration runner on the shared connection
+func NewMigrationRunner() *dblayer.MigrationRunner {
+	return dblayer.NewMigrationRunner(NewDBRepository(context.Background()), Migrations)
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSQLStatements(t *testing.T) {
+	statements := sqlStatements("-- a comment; with a semicolon\nCREATE TABLE a (id int);\n\n  -- another\nCREATE TABLE b (id int);\n")
+	if len(statements) != 2 || statements[0] != "CREATE TABLE a (id int)" || statements[1] != "CREATE TABLE b (id int)" {
+		t.Fatalf("unexpected statements: %q", statements)
+	}
+}
+
+func TestMigrationV1IsFrozen(t *testing.T) {
+	statements := Migrations[0].Statements
+	if Migrations[0].Version != 1 || Migrations[0].Apply != nil {
+		t.Fatal("migration 1 must be made of literal statements")
+	}
+	if len(statements) != 16 {
+		t.Errorf("expected the 16 tables of the first version, got %d statements", len(statements))
+	}
+	for _, statement := range statements {
+		if !strings.HasPrefix(statement, "CREATE TABLE IF NOT EXISTS `{prefix}") {
+			t.Errorf("unexpected statement: %.60s", statement)
+		}
+		if !strings.Contains(statement, "PRIMARY KEY") {
+			t.Errorf("missing PRIMARY KEY: %.60s", statement)
+		}
+		if !strings.HasSuffix(statement, ") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci") {
+			t.Errorf("missing the ENGINE clause: %.60s", statement)
+		}
+		if strings.Count(statement, "'")%2 != 0 || strings.Count(statement, "`")%2 != 0 {
+			t.Errorf("unbalanced quotes: %.60s", statement)
+		}
+		// Created by the later migrations
+		for _, later := range []string{"search_index", "calendar_feeds", "family_id", "last_seen"} {
+			if strings.Contains(statement, later) {
+				t.Errorf("'%s' belongs to a later migration: %.60s", later, statement)
+			}
+		}
+	}
+}
+
+func TestMigrationsCreateTablesAreLiteral(t *testing.T) {
+	for _, m := range Migrations {
+		for _, statement := range m.Statements {
+			if !strings.HasPrefix(statement, "CREATE TABLE") {
+				continue
+			}
+			if !strings.Contains(statement, "PRIMARY KEY") ||
+				!strings.HasSuffix(statement, ") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci") ||
+				strings.Count(statement, "'")%2 != 0 || strings.Count(statement, "`")%2 != 0 {
+				t.Errorf("version %d: incomplete statement: %.60s", m.Version, statement)
+			}
+		}
+	}
+	// The full text search index
+	if m := Migrations[4]; m.Version != 5 || len(m.Statements) != 3 || !strings.Contains(m.Statements[0], "`{prefix}search_index`") {
+		t.Errorf("version 5 must create search_index with literal statements: %q", m.Statements)
+	}
+	// The calendar feeds
+	if m := Migrations[5]; m.Version != 6 || m.Apply != nil || len(m.Statements) != 1 || !strings.Contains(m.Statements[0], "`{prefix}calendar_feeds`") {
+		t.Errorf("version 6 must create calendar_feeds with a literal statement: %q", m.Statements)
+	}
+}
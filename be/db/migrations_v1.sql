@@ -0,0 +1,418 @@
+-- Migration 1 of "rprj": the tables of the first version of the schema, as in db/00_initial.sql.
+-- Frozen: the later changes of the entities go in new migrations.
+-- "{prefix}" is replaced with the table prefix, the statements are separated by ";"
+
+CREATE TABLE IF NOT EXISTS `{prefix}companies` (
+  `id` varchar(16) NOT NULL,
+  `owner` varchar(16) NOT NULL,
+  `group_id` varchar(16) NOT NULL,
+  `permissions` char(9) NOT NULL DEFAULT 'rwx------',
+  `creator` varchar(16) NOT NULL,
+  `creation_date` datetime DEFAULT NULL,
+  `last_modify` varchar(16) NOT NULL,
+  `last_modify_date` datetime DEFAULT NULL,
+  `deleted_by` varchar(16) DEFAULT NULL,
+  `deleted_date` datetime NOT NULL DEFAULT '0000-00-00 00:00:00',
+  `father_id` varchar(16) DEFAULT NULL,
+  `name` varchar(255) NOT NULL,
+  `description` text DEFAULT NULL,
+  `street` varchar(255) DEFAULT NULL,
+  `zip` varchar(255) DEFAULT NULL,
+  `city` varchar(255) DEFAULT NULL,
+  `state` varchar(255) DEFAULT NULL,
+  `fk_countrylist_id` varchar(16) DEFAULT NULL,
+  `phone` varchar(255) DEFAULT NULL,
+  `fax` varchar(255) DEFAULT NULL,
+  `email` varchar(255) DEFAULT NULL,
+  `url` varchar(255) DEFAULT NULL,
+  `p_iva` varchar(16) DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `{prefix}companies_0` (`id`),
+  KEY `{prefix}companies_1` (`owner`),
+  KEY `{prefix}companies_2` (`group_id`),
+  KEY `{prefix}companies_3` (`creator`),
+  KEY `{prefix}companies_4` (`last_modify`),
+  KEY `{prefix}companies_5` (`deleted_by`),
+  KEY `{prefix}companies_6` (`father_id`),
+  KEY `{prefix}companies_7` (`fk_countrylist_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+CREATE TABLE IF NOT EXISTS `{prefix}countrylist` (
+  `id` varchar(16) NOT NULL,
+  `Common_Name` varchar(255) DEFAULT NULL,
+  `Formal_Name` varchar(255) DEFAULT NULL,
+  `Type` varchar(255) DEFAULT NULL,
+  `Sub_Type` varchar(255) DEFAULT NULL,
+  `Sovereignty` varchar(255) DEFAULT NULL,
+  `Capital` varchar(255) DEFAULT NULL,
+  `ISO_4217_Currency_Code` varchar(255) DEFAULT NULL,
+  `ISO_4217_Currency_Name` varchar(255) DEFAULT NULL,
+  `ITU_T_Telephone_Code` varchar(255) DEFAULT NULL,
+  `ISO_3166_1_2_Letter_Code` varchar(255) DEFAULT NULL,
+  `ISO_3166_1_3_Letter_Code` varchar(255) DEFAULT NULL,
+  `ISO_3166_1_Number` varchar(255) DEFAULT NULL,
+  `IANA_Country_Code_TLD` varchar(255) DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `{prefix}countrylist_0` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+CREATE TABLE IF NOT EXISTS `{prefix}dbversion` (
+  `model_name` varchar(255) NOT NULL,
+  `version` int(11) NOT NULL,
+  PRIMARY KEY (`model_name`),
+  KEY `{prefix}dbversion_0` (`model_name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+CREATE TABLE IF NOT EXISTS `{prefix}events` (
+  `id` varchar(16) NOT NULL,
+  `owner` varchar(16) NOT NULL,
+  `group_id` varchar(16) NOT NULL,
+  `permissions` char(9) NOT NULL DEFAULT 'rwx------',
+  `creator` varchar(16) NOT NULL,
+  `creation_date` datetime DEFAULT NULL,
+  `last_modify` varchar(16) NOT NULL,
+  `last_modify_date` datetime DEFAULT NULL,
+  `deleted_by` varchar(16) DEFAULT NULL,
+  `deleted_date` datetime NOT NULL DEFAULT '0000-00-00 00:00:00',
+  `father_id` varchar(16) DEFAULT NULL,
+  `name` varchar(255) NOT NULL,
+  `description` text DEFAULT NULL,
+  `fk_obj_id` varchar(16) DEFAULT NULL,
+  `start_date` datetime NOT NULL DEFAULT '0000-00-00 00:00:00',
+  `end_date` datetime NOT NULL DEFAULT '0000-00-00 00:00:00',
+  `all_day` char(1) NOT NULL DEFAULT '1',
+  `url` varchar(255) DEFAULT NULL,
+  `alarm` char(1) DEFAULT '0',
+  `alarm_minute` int(11) DEFAULT 0,
+  `alarm_unit` char(1) DEFAULT '0',
+  `before_event` char(1) DEFAULT '0',
+  `category` varchar(255) DEFAULT '',
+  `recurrence` char(1) DEFAULT '0',
+  `recurrence_type` char(1) DEFAULT '0',
+  `daily_every_x` int(11) DEFAULT 0,
+  `weekly_every_x` int(11) DEFAULT 0,
+  `weekly_day_of_the_week` char(1) DEFAULT '0',
+  `monthly_every_x` int(11) DEFAULT 0,
+  `monthly_day_of_the_month` int(11) DEFAULT 0,
+  `monthly_week_number` int(11) DEFAULT 0,
+  `monthly_week_day` char(1) DEFAULT '0',
+  `yearly_month_number` int(11) DEFAULT 0,
+  `yearly_month_day` int(11) DEFAULT 0,
+  `yearly_week_number` int(11) DEFAULT 0,
+  `yearly_week_day` char(1) DEFAULT '0',
+  `yearly_day_of_the_year` int(11) DEFAULT 0,
+  `recurrence_times` int(11) DEFAULT 0,
+  `recurrence_end_date` datetime NOT NULL DEFAULT '0000-00-00 00:00:00',
+  PRIMARY KEY (`id`),
+  KEY `{prefix}events_0` (`id`),
+  KEY `{prefix}events_1` (`owner`),
+  KEY `{prefix}events_2` (`group_id`),
+  KEY `{prefix}events_3` (`creator`),
+  KEY `{prefix}events_4` (`last_modify`),
+  KEY `{prefix}events_5` (`deleted_by`),
+  KEY `{prefix}events_6` (`father_id`),
+  KEY `{prefix}events_7` (`fk_obj_id`),
+  KEY `{prefix}events_8` (`fk_obj_id`),
+  KEY `{prefix}events_9` (`fk_obj_id`),
+  KEY `{prefix}events_10` (`fk_obj_id`),
+  KEY `{prefix}events_idx2` (`start_date`),
+  KEY `{prefix}events_idx3` (`end_date`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+CREATE TABLE IF NOT EXISTS `{prefix}files` (
+  `id` varchar(16) NOT NULL,
+  `owner` varchar(16) NOT NULL,
+  `group_id` varchar(16) NOT NULL,
+  `permissions` char(9) NOT NULL DEFAULT 'rwx------',
+  `creator` varchar(16) NOT NULL,
+  `creation_date` datetime DEFAULT NULL,
+  `last_modify` varchar(16) NOT NULL,
+  `last_modify_date` datetime DEFAULT NULL,
+  `deleted_by` varchar(16) DEFAULT NULL,
+  `deleted_date` datetime NOT NULL DEFAULT '0000-00-00 00:00:00',
+  `father_id` varchar(16) DEFAULT NULL,
+  `name` varchar(255) NOT NULL,
+  `description` text DEFAULT NULL,
+  `fk_obj_id` varchar(16) DEFAULT NULL,
+  `path` text DEFAULT NULL,
+  `filename` text NOT NULL,
+  `checksum` char(40) DEFAULT NULL,
+  `mime` varchar(255) DEFAULT NULL,
+  `alt_link` varchar(255) NOT NULL DEFAULT '',
+  PRIMARY KEY (`id`),
+  KEY `{prefix}files_0` (`id`),
+  KEY `{prefix}files_1` (`owner`),
+  KEY `{prefix}files_2` (`group_id`),
+  KEY `{prefix}files_3` (`creator`),
+  KEY `{prefix}files_4` (`last_modify`),
+  KEY `{prefix}files_5` (`deleted_by`),
+  KEY `{prefix}files_6` (`father_id`),
+  KEY `{prefix}files_7` (`father_id`),
+  KEY `{prefix}files_8` (`fk_obj_id`),
+  KEY `{prefix}files_9` (`father_id`),
+  KEY `{prefix}files_10` (`fk_obj_id`),
+  KEY `{prefix}files_11` (`father_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+CREATE TABLE IF NOT EXISTS `{prefix}folders` (
+  `id` varchar(16) NOT NULL,
+  `owner` varchar(16) NOT NULL,
+  `group_id` varchar(16) NOT NULL,
+  `permissions` char(9) NOT NULL DEFAULT 'rwx------',
+  `creator` varchar(16) NOT NULL,
+  `creation_date` datetime DEFAULT NULL,
+  `last_modify` varchar(16) NOT NULL,
+  `last_modify_date` datetime DEFAULT NULL,
+  `deleted_by` varchar(16) DEFAULT NULL,
+  `deleted_date` datetime NOT NULL DEFAULT '0000-00-00 00:00:00',
+  `father_id` varchar(16) DEFAULT NULL,
+  `name` varchar(255) NOT NULL,
+  `description` text DEFAULT NULL,
+  `fk_obj_id` varchar(16) DEFAULT NULL,
+  `childs_sort_order` text DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `{prefix}folders_0` (`id`),
+  KEY `{prefix}folders_1` (`owner`),
+  KEY `{prefix}folders_2` (`group_id`),
+  KEY `{prefix}folders_3` (`creator`),
+  KEY `{prefix}folders_4` (`last_modify`),
+  KEY `{prefix}folders_5` (`deleted_by`),
+  KEY `{prefix}folders_6` (`father_id`),
+  KEY `{prefix}folders_7` (`fk_obj_id`),
+  KEY `{prefix}folders_8` (`fk_obj_id`),
+  KEY `{prefix}folders_9` (`fk_obj_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+CREATE TABLE IF NOT EXISTS `{prefix}groups` (
+  `id` varchar(16) NOT NULL,
+  `name` varchar(255) NOT NULL,
+  `description` text DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `{prefix}groups_0` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+CREATE TABLE IF NOT EXISTS `{prefix}links` (
+  `id` varchar(16) NOT NULL,
+  `owner` varchar(16) NOT NULL,
+  `group_id` varchar(16) NOT NULL,
+  `permissions` char(9) NOT NULL DEFAULT 'rwx------',
+  `creator` varchar(16) NOT NULL,
+  `creation_date` datetime DEFAULT NULL,
+  `last_modify` varchar(16) NOT NULL,
+  `last_modify_date` datetime DEFAULT NULL,
+  `deleted_by` varchar(16) DEFAULT NULL,
+  `deleted_date` datetime NOT NULL DEFAULT '0000-00-00 00:00:00',
+  `father_id` varchar(16) DEFAULT NULL,
+  `name` varchar(255) NOT NULL,
+  `description` text DEFAULT NULL,
+  `href` varchar(255) NOT NULL,
+  `target` varchar(255) DEFAULT '_blank',
+  `fk_obj_id` varchar(16) DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `{prefix}links_0` (`id`),
+  KEY `{prefix}links_1` (`owner`),
+  KEY `{prefix}links_2` (`group_id`),
+  KEY `{prefix}links_3` (`creator`),
+  KEY `{prefix}links_4` (`last_modify`),
+  KEY `{prefix}links_5` (`deleted_by`),
+  KEY `{prefix}links_6` (`father_id`),
+  KEY `{prefix}links_7` (`fk_obj_id`),
+  KEY `{prefix}links_8` (`fk_obj_id`),
+  KEY `{prefix}links_9` (`fk_obj_id`),
+  KEY `{prefix}links_10` (`fk_obj_id`),
+  KEY `{prefix}links_11` (`fk_obj_id`),
+  KEY `{prefix}links_12` (`father_id`),
+  KEY `{prefix}links_13` (`fk_obj_id`),
+  KEY `{prefix}links_14` (`father_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+CREATE TABLE IF NOT EXISTS `{prefix}news` (
+  `id` varchar(16) NOT NULL,
+  `owner` varchar(16) NOT NULL,
+  `group_id` varchar(16) NOT NULL,
+  `permissions` char(9) NOT NULL DEFAULT 'rwx------',
+  `creator` varchar(16) NOT NULL,
+  `creation_date` datetime DEFAULT NULL,
+  `last_modify` varchar(16) NOT NULL,
+  `last_modify_date` datetime DEFAULT NULL,
+  `deleted_by` varchar(16) DEFAULT NULL,
+  `deleted_date` datetime NOT NULL DEFAULT '0000-00-00 00:00:00',
+  `father_id` varchar(16) DEFAULT NULL,
+  `name` varchar(255) NOT NULL,
+  `description` text DEFAULT NULL,
+  `html` text DEFAULT NULL,
+  `fk_obj_id` varchar(16) DEFAULT NULL,
+  `language` varchar(5) DEFAULT 'en_us',
+  PRIMARY KEY (`id`),
+  KEY `{prefix}news_0` (`id`),
+  KEY `{prefix}news_1` (`owner`),
+  KEY `{prefix}news_2` (`group_id`),
+  KEY `{prefix}news_3` (`creator`),
+  KEY `{prefix}news_4` (`last_modify`),
+  KEY `{prefix}news_5` (`deleted_by`),
+  KEY `{prefix}news_6` (`father_id`),
+  KEY `{prefix}news_7` (`fk_obj_id`),
+  KEY `{prefix}news_8` (`fk_obj_id`),
+  KEY `{prefix}news_9` (`fk_obj_id`),
+  KEY `{prefix}news_10` (`fk_obj_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+CREATE TABLE IF NOT EXISTS `{prefix}notes` (
+  `id` varchar(16) NOT NULL,
+  `owner` varchar(16) NOT NULL,
+  `group_id` varchar(16) NOT NULL,
+  `permissions` char(9) NOT NULL DEFAULT 'rwx------',
+  `creator` varchar(16) NOT NULL,
+  `creation_date` datetime DEFAULT NULL,
+  `last_modify` varchar(16) NOT NULL,
+  `last_modify_date` datetime DEFAULT NULL,
+  `deleted_by` varchar(16) DEFAULT NULL,
+  `deleted_date` datetime NOT NULL DEFAULT '0000-00-00 00:00:00',
+  `father_id` varchar(16) DEFAULT NULL,
+  `name` varchar(255) NOT NULL,
+  `description` text DEFAULT NULL,
+  `fk_obj_id` varchar(16) DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `{prefix}notes_0` (`id`),
+  KEY `{prefix}notes_1` (`owner`),
+  KEY `{prefix}notes_2` (`group_id`),
+  KEY `{prefix}notes_3` (`creator`),
+  KEY `{prefix}notes_4` (`last_modify`),
+  KEY `{prefix}notes_5` (`deleted_by`),
+  KEY `{prefix}notes_6` (`father_id`),
+  KEY `{prefix}notes_7` (`fk_obj_id`),
+  KEY `{prefix}notes_8` (`fk_obj_id`),
+  KEY `{prefix}notes_9` (`fk_obj_id`),
+  KEY `{prefix}notes_10` (`fk_obj_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+CREATE TABLE IF NOT EXISTS `{prefix}oauth_tokens` (
+  `token_id` varchar(512) NOT NULL,
+  `user_id` varchar(16) NOT NULL,
+  `access_token` text NOT NULL,
+  `refresh_token` text DEFAULT NULL,
+  `expires_at` datetime NOT NULL,
+  `created_at` datetime DEFAULT current_timestamp(),
+  PRIMARY KEY (`token_id`),
+  KEY `{prefix}oauth_tokens_0` (`token_id`),
+  KEY `{prefix}oauth_tokens_1` (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+CREATE TABLE IF NOT EXISTS `{prefix}objects` (
+  `id` varchar(16) NOT NULL,
+  `owner` varchar(16) NOT NULL,
+  `group_id` varchar(16) NOT NULL,
+  `permissions` char(9) NOT NULL DEFAULT 'rwx------',
+  `creator` varchar(16) NOT NULL,
+  `creation_date` datetime DEFAULT NULL,
+  `last_modify` varchar(16) NOT NULL,
+  `last_modify_date` datetime DEFAULT NULL,
+  `deleted_by` varchar(16) DEFAULT NULL,
+  `deleted_date` datetime NOT NULL DEFAULT '0000-00-00 00:00:00',
+  `father_id` varchar(16) DEFAULT NULL,
+  `name` varchar(255) NOT NULL,
+  `description` text DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `{prefix}objects_0` (`id`),
+  KEY `{prefix}objects_1` (`owner`),
+  KEY `{prefix}objects_2` (`group_id`),
+  KEY `{prefix}objects_3` (`creator`),
+  KEY `{prefix}objects_4` (`last_modify`),
+  KEY `{prefix}objects_5` (`deleted_by`),
+  KEY `{prefix}objects_6` (`father_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+CREATE TABLE IF NOT EXISTS `{prefix}pages` (
+  `id` varchar(16) NOT NULL,
+  `owner` varchar(16) NOT NULL,
+  `group_id` varchar(16) NOT NULL,
+  `permissions` char(9) NOT NULL DEFAULT 'rwx------',
+  `creator` varchar(16) NOT NULL,
+  `creation_date` datetime DEFAULT NULL,
+  `last_modify` varchar(16) NOT NULL,
+  `last_modify_date` datetime DEFAULT NULL,
+  `deleted_by` varchar(16) DEFAULT NULL,
+  `deleted_date` datetime NOT NULL DEFAULT '0000-00-00 00:00:00',
+  `father_id` varchar(16) DEFAULT NULL,
+  `name` varchar(255) NOT NULL,
+  `description` text DEFAULT NULL,
+  `html` text DEFAULT NULL,
+  `fk_obj_id` varchar(16) DEFAULT NULL,
+  `language` varchar(5) DEFAULT 'en_us',
+  PRIMARY KEY (`id`),
+  KEY `{prefix}pages_0` (`id`),
+  KEY `{prefix}pages_1` (`owner`),
+  KEY `{prefix}pages_2` (`group_id`),
+  KEY `{prefix}pages_3` (`creator`),
+  KEY `{prefix}pages_4` (`last_modify`),
+  KEY `{prefix}pages_5` (`deleted_by`),
+  KEY `{prefix}pages_6` (`father_id`),
+  KEY `{prefix}pages_7` (`fk_obj_id`),
+  KEY `{prefix}pages_8` (`fk_obj_id`),
+  KEY `{prefix}pages_9` (`fk_obj_id`),
+  KEY `{prefix}pages_10` (`fk_obj_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+CREATE TABLE IF NOT EXISTS `{prefix}people` (
+  `id` varchar(16) NOT NULL,
+  `owner` varchar(16) NOT NULL,
+  `group_id` varchar(16) NOT NULL,
+  `permissions` char(9) NOT NULL DEFAULT 'rwx------',
+  `creator` varchar(16) NOT NULL,
+  `creation_date` datetime DEFAULT NULL,
+  `last_modify` varchar(16) NOT NULL,
+  `last_modify_date` datetime DEFAULT NULL,
+  `deleted_by` varchar(16) DEFAULT NULL,
+  `deleted_date` datetime NOT NULL DEFAULT '0000-00-00 00:00:00',
+  `father_id` varchar(16) DEFAULT NULL,
+  `name` varchar(255) NOT NULL,
+  `description` text DEFAULT NULL,
+  `street` varchar(255) DEFAULT NULL,
+  `zip` varchar(255) DEFAULT NULL,
+  `city` varchar(255) DEFAULT NULL,
+  `state` varchar(255) DEFAULT NULL,
+  `fk_countrylist_id` varchar(16) DEFAULT NULL,
+  `fk_companies_id` varchar(16) DEFAULT NULL,
+  `fk_users_id` varchar(16) DEFAULT NULL,
+  `phone` varchar(255) DEFAULT NULL,
+  `office_phone` varchar(255) DEFAULT NULL,
+  `mobile` varchar(255) DEFAULT NULL,
+  `fax` varchar(255) DEFAULT NULL,
+  `email` varchar(255) DEFAULT NULL,
+  `url` varchar(255) DEFAULT NULL,
+  `codice_fiscale` varchar(20) DEFAULT NULL,
+  `p_iva` varchar(16) DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `{prefix}people_0` (`id`),
+  KEY `{prefix}people_1` (`owner`),
+  KEY `{prefix}people_2` (`group_id`),
+  KEY `{prefix}people_3` (`creator`),
+  KEY `{prefix}people_4` (`last_modify`),
+  KEY `{prefix}people_5` (`deleted_by`),
+  KEY `{prefix}people_6` (`father_id`),
+  KEY `{prefix}people_7` (`fk_countrylist_id`),
+  KEY `{prefix}people_8` (`fk_companies_id`),
+  KEY `{prefix}people_9` (`fk_users_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+CREATE TABLE IF NOT EXISTS `{prefix}users` (
+  `id` varchar(16) NOT NULL,
+  `login` varchar(255) NOT NULL,
+  `pwd` varchar(255) NOT NULL,
+  `pwd_salt` varchar(4) DEFAULT '',
+  `fullname` text DEFAULT NULL,
+  `group_id` varchar(16) NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `{prefix}users_0` (`id`),
+  KEY `{prefix}users_1` (`group_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+CREATE TABLE IF NOT EXISTS `{prefix}users_groups` (
+  `user_id` varchar(16) NOT NULL,
+  `group_id` varchar(16) NOT NULL,
+  PRIMARY KEY (`user_id`,`group_id`),
+  KEY `{prefix}users_groups_0` (`user_id`),
+  KEY `{prefix}users_groups_1` (`group_id`),
+  KEY `{prefix}users_groups_2` (`user_id`),
+  KEY `{prefix}users_groups_3` (`group_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"rprj/be/dblayer"
+)
+
+/*
+Parses the sort spec orderBy (ie. "login,-fullname") accepting only the given columns;
+id is always added to make the order stable.
+*/
+func parseOrder(orderBy string, defaultOrder string, columns []string) ([]dblayer.OrderTerm, error) {
+	if orderBy == "" {
+		orderBy = defaultOrder
+	}
+	terms, err := dblayer.ParseOrderBy(orderBy)
+	if err != nil {
+		return nil, err
+	}
+	for _, term := range terms {
+		allowed := false
+		for _, col := range columns {
+			if term.Column == col {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return nil, fmt.Errorf("%w: unknown column '%s'", dblayer.ErrInvalidOrderBy, term.Column)
+		}
+	}
+	return dblayer.StableOrder(terms, []string{"id"}), nil
+}
+
+/*
+Reads a page of the rows of baseQuery: scanRow reads the current row
+and returns the values of its columns, for the cursor.
+Returns the total count of the rows and the cursor of the next page ("" on the last one).
+*/
+func queryPage(ctx context.Context, baseQuery string, args []interface{}, order []dblayer.OrderTerm, page dblayer.PageRequest, scanRow func(rows *sql.Rows) (map[string]string, error)) (int, string, error) {
+	paged, err := dblayer.BuildPagedQuery(baseQuery, args, order, page)
+	if err != nil {
+		return 0, "", err
+	}
+
+	var total int
+	if err := DB.QueryRowContext(ctx, paged.CountQuery, paged.CountArgs...).Scan(&total); err != nil {
+		log.Print("queryPage: Count error:", err)
+		return 0, "", err
+	}
+
+	rows, err := DB.QueryContext(ctx, paged.Query, paged.Args...)
+	if err != nil {
+		log.Print("queryPage: Query error:", err)
+		return 0, "", err
+	}
+	defer rows.Close()
+
+	var last map[string]string
+	n := 0
+	for rows.Next() {
+		if n == paged.Limit {
+			// The extra row: there is a next page
+			nextCursor := dblayer.EncodeCursor(order, func(column string) (string, bool) { return last[column], true })
+			return total, nextCursor, nil
+		}
+		if last, err = scanRow(rows); err != nil {
+			return 0, "", err
+		}
+		n++
+	}
+	return total, "", rows.Err()
+}
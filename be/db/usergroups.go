@@ -1,6 +1,8 @@
 package db
 
 import (
+	"context"
+
 	"rprj/be/models"
 )
 
@@ -17,8 +19,8 @@ CREATE TABLE IF NOT EXISTS `rra_users_groups` (
 ) ENGINE=MyISAM DEFAULT CHARSET=latin1;
 */
 // CREATE
-func CreateUserGroup(g models.DBUserGroup) error {
-	_, err := DB.Exec(
+func CreateUserGroup(ctx context.Context, g models.DBUserGroup) error {
+	_, err := DB.ExecContext(ctx,
 		"INSERT INTO "+tablePrefix+"users_groups (user_id, group_id) VALUES (?, ?)",
 		g.UserID, g.GroupID,
 	)
@@ -26,8 +28,8 @@ func CreateUserGroup(g models.DBUserGroup) error {
 }
 
 // READ
-func GetUserGroupsByUserID(userID string) ([]models.DBUserGroup, error) {
-	rows, err := DB.Query(
+func GetUserGroupsByUserID(ctx context.Context, userID string) ([]models.DBUserGroup, error) {
+	rows, err := DB.QueryContext(ctx,
 		"SELECT user_id, group_id FROM "+tablePrefix+"users_groups WHERE user_id = ?",
 		userID,
 	)
@@ -46,8 +48,8 @@ func GetUserGroupsByUserID(userID string) ([]models.DBUserGroup, error) {
 	}
 	return groups, nil
 }
-func GetUserGroupsByGroupID(groupID string) ([]models.DBUserGroup, error) {
-	rows, err := DB.Query(
+func GetUserGroupsByGroupID(ctx context.Context, groupID string) ([]models.DBUserGroup, error) {
+	rows, err := DB.QueryContext(ctx,
 		"SELECT user_id, group_id FROM "+tablePrefix+"users_groups WHERE group_id = ?",
 		groupID,
 	)
@@ -68,8 +70,8 @@ func GetUserGroupsByGroupID(groupID string) ([]models.DBUserGroup, error) {
 }
 
 // DELETE
-func DeleteUserGroup(userID string, groupID string) error {
-	_, err := DB.Exec(
+func DeleteUserGroup(ctx context.Context, userID string, groupID string) error {
+	_, err := DB.ExecContext(ctx,
 		"DELETE FROM "+tablePrefix+"users_groups WHERE user_id = ? AND group_id = ?",
 		userID, groupID,
 	)
@@ -77,8 +79,8 @@ func DeleteUserGroup(userID string, groupID string) error {
 }
 
 // DELETE all groups for a user
-func DeleteUserGroupsByUserID(userID string) error {
-	_, err := DB.Exec(
+func DeleteUserGroupsByUserID(ctx context.Context, userID string) error {
+	_, err := DB.ExecContext(ctx,
 		"DELETE FROM "+tablePrefix+"users_groups WHERE user_id = ?",
 		userID,
 	)
@@ -86,8 +88,8 @@ func DeleteUserGroupsByUserID(userID string) error {
 }
 
 // DELETE all users for a group
-func DeleteUserGroupsByGroupID(groupID string) error {
-	_, err := DB.Exec(
+func DeleteUserGroupsByGroupID(ctx context.Context, groupID string) error {
+	_, err := DB.ExecContext(ctx,
 		"DELETE FROM "+tablePrefix+"users_groups WHERE group_id = ?",
 		groupID,
 	)
@@ -1,16 +1,18 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 
+	"rprj/be/dblayer"
 	"rprj/be/models"
 )
 
 // CREATE
 // func CreateUser(u models.DBUser) (string, error) {
 // 	if u.ID == "" {
-// 		newID, _ := uuid16HexGo()
+// 		newID, _ := dblayer.UUID16Hex()
 // 		log.Print("newID=", newID)
 // 		u.ID = newID
 // 	}
@@ -22,8 +24,8 @@ import (
 // }
 
 // READ (by Login)
-func GetUserByLogin(login string) (*models.DBUser, error) {
-	row := DB.QueryRow("SELECT id, login, pwd, pwd_salt, fullname, group_id FROM "+tablePrefix+"users WHERE login = ?", login)
+func GetUserByLogin(ctx context.Context, login string) (*models.DBUser, error) {
+	row := DB.QueryRowContext(ctx, "SELECT id, login, pwd, pwd_salt, fullname, group_id FROM "+tablePrefix+"users WHERE login = ?", login)
 	var u models.DBUser
 	err := row.Scan(&u.ID, &u.Login, &u.Pwd, &u.PwdSalt, &u.Fullname, &u.GroupID)
 	if err == sql.ErrNoRows {
@@ -33,8 +35,8 @@ func GetUserByLogin(login string) (*models.DBUser, error) {
 }
 
 // READ (by ID)
-func GetUserByID(id string) (*models.DBUser, error) {
-	row := DB.QueryRow("SELECT id, login, pwd, pwd_salt, fullname, group_id FROM "+tablePrefix+"users WHERE id = ?", id)
+func GetUserByID(ctx context.Context, id string) (*models.DBUser, error) {
+	row := DB.QueryRowContext(ctx, "SELECT id, login, pwd, pwd_salt, fullname, group_id FROM "+tablePrefix+"users WHERE id = ?", id)
 	var u models.DBUser
 	err := row.Scan(&u.ID, &u.Login, &u.Pwd, &u.PwdSalt, &u.Fullname, &u.GroupID)
 	if err == sql.ErrNoRows {
@@ -60,9 +62,19 @@ func GetUserByID(id string) (*models.DBUser, error) {
 // 	return err
 // }
 
+// UpdateUserPassword stores the password hashed, ie. to upgrade a legacy plain text password
+func UpdateUserPassword(ctx context.Context, id string, pwd string) error {
+	hash, salt, err := dblayer.HashPassword(pwd)
+	if err != nil {
+		return err
+	}
+	_, err = DB.ExecContext(ctx, "UPDATE "+tablePrefix+"users SET pwd=?, pwd_salt=? WHERE id=?", hash, salt, id)
+	return err
+}
+
 // DELETE
-func DeleteUser(id string) error {
-	tx, err := DB.Begin()
+func DeleteUser(ctx context.Context, id string) error {
+	tx, err := DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -70,26 +82,32 @@ func DeleteUser(id string) error {
 
 	// Get user to find personal group_id
 	var groupID string
-	err = tx.QueryRow("SELECT group_id FROM "+tablePrefix+"users WHERE id=?", id).Scan(&groupID)
+	err = tx.QueryRowContext(ctx, "SELECT group_id FROM "+tablePrefix+"users WHERE id=?", id).Scan(&groupID)
 	if err != nil {
 		return err
 	}
 
 	// Delete user-group associations
-	_, err = tx.Exec("DELETE FROM "+tablePrefix+"users_groups WHERE user_id=?", id)
+	_, err = tx.ExecContext(ctx, "DELETE FROM "+tablePrefix+"users_groups WHERE user_id=?", id)
+	if err != nil {
+		return err
+	}
+
+	// Revoke user sessions
+	_, err = tx.ExecContext(ctx, "DELETE FROM "+tablePrefix+"oauth_tokens WHERE user_id=?", id)
 	if err != nil {
 		return err
 	}
 
 	// Delete user
-	_, err = tx.Exec("DELETE FROM "+tablePrefix+"users WHERE id=?", id)
+	_, err = tx.ExecContext(ctx, "DELETE FROM "+tablePrefix+"users WHERE id=?", id)
 	if err != nil {
 		return err
 	}
 
 	// Delete personal group (if it exists)
 	if groupID != "" {
-		_, err = tx.Exec("DELETE FROM "+tablePrefix+"groups WHERE id=?", groupID)
+		_, err = tx.ExecContext(ctx, "DELETE FROM "+tablePrefix+"groups WHERE id=?", groupID)
 		if err != nil {
 			return err
 		}
@@ -98,55 +116,43 @@ func DeleteUser(id string) error {
 	return tx.Commit()
 }
 
-// Get all users
-func GetAllUsers(searchBy string, orderBy string) ([]models.DBUser, error) {
-	if orderBy == "" {
-		orderBy = "id"
+// Columns of the users accepted by order_by
+var userOrderColumns = []string{"id", "login", "fullname", "group_id"}
+
+// Get a page of the users; returns the users, their total count and the cursor of the next page
+func GetAllUsers(ctx context.Context, searchBy string, orderBy string, page dblayer.PageRequest) ([]models.DBUser, int, string, error) {
+	order, err := parseOrder(orderBy, "id", userOrderColumns)
+	if err != nil {
+		return nil, 0, "", err
 	}
+
 	query := "SELECT id, login, pwd, pwd_salt, fullname, group_id FROM " + tablePrefix + "users"
+	args := []interface{}{}
 	if searchBy != "" {
 		query += " WHERE login LIKE ? OR fullname LIKE ?"
 		searchPattern := "%" + searchBy + "%"
-		query += fmt.Sprintf(" ORDER BY %s", orderBy)
-		rows, err := DB.Query(query, searchPattern, searchPattern)
-		if err != nil {
-			return nil, err
-		}
-		defer rows.Close()
-
-		var users []models.DBUser
-		for rows.Next() {
-			var u models.DBUser
-			if err := rows.Scan(&u.ID, &u.Login, &u.Pwd, &u.PwdSalt, &u.Fullname, &u.GroupID); err != nil {
-				return nil, err
-			}
-			users = append(users, u)
-		}
-		return users, nil
+		args = append(args, searchPattern, searchPattern)
 	}
 
-	// Senza filtro di ricerca
-	rows, err := DB.Query("SELECT id, login, pwd, pwd_salt, fullname, group_id FROM " + tablePrefix + "users ORDER BY " + orderBy)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var users []models.DBUser
-	for rows.Next() {
+	users := []models.DBUser{}
+	total, nextCursor, err := queryPage(ctx, query, args, order, page, func(rows *sql.Rows) (map[string]string, error) {
 		var u models.DBUser
 		if err := rows.Scan(&u.ID, &u.Login, &u.Pwd, &u.PwdSalt, &u.Fullname, &u.GroupID); err != nil {
 			return nil, err
 		}
 		users = append(users, u)
+		return map[string]string{"id": u.ID, "login": u.Login, "fullname": u.Fullname, "group_id": u.GroupID}, nil
+	})
+	if err != nil {
+		return nil, 0, "", err
 	}
-	return users, nil
+	return users, total, nextCursor, nil
 }
 
 // EXTRA: Count
-func CountUsers() (int, error) {
+func CountUsers(ctx context.Context) (int, error) {
 	var count int
-	err := DB.QueryRow("SELECT COUNT(*) FROM " + tablePrefix + "users").Scan(&count)
+	err := DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+tablePrefix+"users").Scan(&count)
 	if err != nil {
 		return 0, err
 	}
@@ -155,8 +161,8 @@ func CountUsers() (int, error) {
 }
 
 // CreateUser creates a user, personal group, and associations in a single transaction
-func CreateUser(u models.DBUser, login string, additionalGroupIDs []string) (*models.DBUser, string, error) {
-	tx, err := DB.Begin()
+func CreateUser(ctx context.Context, u models.DBUser, login string, additionalGroupIDs []string) (*models.DBUser, string, error) {
+	tx, err := DB.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, "", err
 	}
@@ -164,7 +170,7 @@ func CreateUser(u models.DBUser, login string, additionalGroupIDs []string) (*mo
 
 	// Check that user with same login does not already exist
 	var existingUserID string
-	err = tx.QueryRow("SELECT id FROM "+tablePrefix+"users WHERE login = ?", login).Scan(&existingUserID)
+	err = tx.QueryRowContext(ctx, "SELECT id FROM "+tablePrefix+"users WHERE login = ?", login).Scan(&existingUserID)
 	if err != sql.ErrNoRows {
 		if err == nil {
 			return nil, "", fmt.Errorf("user with login '%s' already exists", login)
@@ -173,11 +179,11 @@ func CreateUser(u models.DBUser, login string, additionalGroupIDs []string) (*mo
 	}
 
 	// Generate IDs
-	userID, _ := uuid16HexGo()
-	groupID, _ := uuid16HexGo()
+	userID, _ := dblayer.UUID16Hex()
+	groupID, _ := dblayer.UUID16Hex()
 
 	// Create personal group
-	_, err = tx.Exec(
+	_, err = tx.ExecContext(ctx,
 		"INSERT INTO "+tablePrefix+"groups (id, name, description) VALUES (?, ?, ?)",
 		groupID, login+"'s group", "Personal group for "+login,
 	)
@@ -188,7 +194,11 @@ func CreateUser(u models.DBUser, login string, additionalGroupIDs []string) (*mo
 	// Create user with personal group as primary
 	u.ID = userID
 	u.GroupID = groupID
-	_, err = tx.Exec(
+	u.Pwd, u.PwdSalt, err = dblayer.HashPassword(u.Pwd)
+	if err != nil {
+		return nil, "", err
+	}
+	_, err = tx.ExecContext(ctx,
 		"INSERT INTO "+tablePrefix+"users (id, login, pwd, pwd_salt, fullname, group_id) VALUES (?, ?, ?, ?, ?, ?)",
 		u.ID, u.Login, u.Pwd, u.PwdSalt, u.Fullname, u.GroupID,
 	)
@@ -197,7 +207,7 @@ func CreateUser(u models.DBUser, login string, additionalGroupIDs []string) (*mo
 	}
 
 	// Add user to personal group
-	_, err = tx.Exec(
+	_, err = tx.ExecContext(ctx,
 		"INSERT INTO "+tablePrefix+"users_groups (user_id, group_id) VALUES (?, ?)",
 		userID, groupID,
 	)
@@ -210,7 +220,7 @@ func CreateUser(u models.DBUser, login string, additionalGroupIDs []string) (*mo
 		if gID == groupID {
 			continue // Skip personal group (already added)
 		}
-		_, err = tx.Exec(
+		_, err = tx.ExecContext(ctx,
 			"INSERT INTO "+tablePrefix+"users_groups (user_id, group_id) VALUES (?, ?)",
 			userID, gID,
 		)
@@ -228,8 +238,8 @@ func CreateUser(u models.DBUser, login string, additionalGroupIDs []string) (*mo
 }
 
 // UpdateUser updates user and group associations in a single transaction
-func UpdateUser(u models.DBUser, updatePwd bool, groupIDs []string) error {
-	tx, err := DB.Begin()
+func UpdateUser(ctx context.Context, u models.DBUser, updatePwd bool, groupIDs []string) error {
+	tx, err := DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -237,12 +247,16 @@ func UpdateUser(u models.DBUser, updatePwd bool, groupIDs []string) error {
 
 	// Update user
 	if updatePwd {
-		_, err = tx.Exec(
+		u.Pwd, u.PwdSalt, err = dblayer.HashPassword(u.Pwd)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
 			"UPDATE "+tablePrefix+"users SET login=?, pwd=?, pwd_salt=?, fullname=?, group_id=? WHERE id=?",
 			u.Login, u.Pwd, u.PwdSalt, u.Fullname, u.GroupID, u.ID,
 		)
 	} else {
-		_, err = tx.Exec(
+		_, err = tx.ExecContext(ctx,
 			"UPDATE "+tablePrefix+"users SET login=?, fullname=?, group_id=? WHERE id=?",
 			u.Login, u.Fullname, u.GroupID, u.ID,
 		)
@@ -252,14 +266,14 @@ func UpdateUser(u models.DBUser, updatePwd bool, groupIDs []string) error {
 	}
 
 	// Delete all existing group associations
-	_, err = tx.Exec("DELETE FROM "+tablePrefix+"users_groups WHERE user_id=?", u.ID)
+	_, err = tx.ExecContext(ctx, "DELETE FROM "+tablePrefix+"users_groups WHERE user_id=?", u.ID)
 	if err != nil {
 		return err
 	}
 
 	// Recreate group associations
 	for _, groupID := range groupIDs {
-		_, err = tx.Exec(
+		_, err = tx.ExecContext(ctx,
 			"INSERT INTO "+tablePrefix+"users_groups (user_id, group_id) VALUES (?, ?)",
 			u.ID, groupID,
 		)
@@ -0,0 +1,219 @@
+package dblayer
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const tableOptions = "ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci"
+
+/*
+Returns the columns to index: the primary keys, the foreign keys and father_id,
+in the order of the column definitions.
+*/
+func indexedColumns(dbe DBEntityInterface) []string {
+	ret := make([]string, 0)
+	for _, col := range dbe.GetColumns() {
+		if dbe.IsPrimaryKey(col.Name) || dbe.IsForeignKey(col.Name) || col.Name == "father_id" {
+			ret = append(ret, col.Name)
+		}
+	}
+	return ret
+}
+
+/*
+Returns the CREATE TABLE statement of the entity, ie.
+
+	CREATE TABLE IF NOT EXISTS `rprj_users` (
+	  `id` varchar(16) NOT NULL,
+	  ...
+	  PRIMARY KEY (`id`),
+	  KEY `rprj_users_0` (`id`),
+	  KEY `rprj_users_1` (`group_id`)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci
+*/
+func GetCreateTableSQL(dbContext *DBContext, dbe DBEntityInterface) string {
+	tablename := BuildTableName(dbContext, dbe.GetTableName())
+	lines := make([]string, 0)
+	for _, col := range dbe.GetColumns() {
+		line := "  `" + col.Name + "` " + col.Type
+		if len(col.Constraints) > 0 {
+			line += " " + strings.Join(col.Constraints, " ")
+		}
+		lines = append(lines, line)
+	}
+	if len(dbe.GetKeys()) > 0 {
+		lines = append(lines, "  PRIMARY KEY (`"+strings.Join(dbe.GetKeys(), "`,`")+"`)")
+	}
+	for i, col := range indexedColumns(dbe) {
+		lines = append(lines, fmt.Sprintf("  KEY `%s_%d` (`%s`)", tablename, i, col))
+	}
+	return "CREATE TABLE IF NOT EXISTS `" + tablename + "` (\n" + strings.Join(lines, ",\n") + "\n) " + tableOptions
+}
+
+/*
+Returns the CREATE TABLE statements of all the registered entities, sorted by table name
+*/
+func (dbef *DBEFactory) GetCreateTablesSQL(dbContext *DBContext) []string {
+	tablenames := make([]string, 0, len(dbef.tablename2type))
+	for tablename := range dbef.tablename2type {
+		tablenames = append(tablenames, tablename)
+	}
+	sort.Strings(tablenames)
+
+	ret := make([]string, 0, len(tablenames))
+	for _, tablename := range tablenames {
+		ret = append(ret, GetCreateTableSQL(dbContext, dbef.tablename2type[tablename]))
+	}
+	return ret
+}
+
+/*
+Creates the tables of all the registered entities that do not exist yet
+*/
+func (dbr *DBRepository) CreateTables() error {
+	for _, statement := range dbr.factory.GetCreateTablesSQL(dbr.DbContext) {
+		if dbr.Verbose {
+			log.Print("DBRepository::CreateTables: ", statement)
+		}
+		if _, err := dbr.DbConnection.ExecContext(dbr.Context(), statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+A difference between the Go definition of a table and the live db
+*/
+type SchemaDrift struct {
+	Table    string
+	Column   string
+	Problem  string // missing_table, missing_column, extra_column, type, nullable
+	Expected string
+	Actual   string
+}
+
+func (drift SchemaDrift) String() string {
+	if drift.Column == "" {
+		return fmt.Sprintf("%s: %s", drift.Table, drift.Problem)
+	}
+	return fmt.Sprintf("%s.%s: %s (expected '%s', found '%s')", drift.Table, drift.Column, drift.Problem, drift.Expected, drift.Actual)
+}
+
+var intDisplayWidth = regexp.MustCompile(`^(tinyint|smallint|mediumint|int|bigint)\(\d+\)`)
+
+/*
+Normalizes a column type for the comparison: lower case and without the display width of the integers,
+which is not reported by recent MySQL versions.
+*/
+func normalizeColumnType(columnType string) string {
+	t := strings.ToLower(strings.TrimSpace(columnType))
+	return intDisplayWidth.ReplaceAllString(t, "$1")
+}
+
+func isNotNull(col Column) bool {
+	for _, constraint := range col.Constraints {
+		if strings.EqualFold(constraint, "NOT NULL") {
+			return true
+		}
+	}
+	return false
+}
+
+type liveColumn struct {
+	columnType string
+	nullable   bool
+}
+
+/*
+Reads the columns of the table from INFORMATION_SCHEMA.
+Returns nil if the table does not exist.
+*/
+func (dbr *DBRepository) readLiveColumns(tablename string) (map[string]liveColumn, error) {
+	rows, err := dbr.DbConnection.QueryContext(dbr.Context(),
+		"SELECT COLUMN_NAME, COLUMN_TYPE, IS_NULLABLE FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?",
+		tablename,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ret map[string]liveColumn
+	for rows.Next() {
+		var name, columnType, nullable string
+		if err := rows.Scan(&name, &columnType, &nullable); err != nil {
+			return nil, err
+		}
+		if ret == nil {
+			ret = make(map[string]liveColumn)
+		}
+		ret[name] = liveColumn{columnType: columnType, nullable: nullable == "YES"}
+	}
+	return ret, rows.Err()
+}
+
+/*
+Compares the registered entities with the tables in the db and returns the differences
+*/
+func (dbr *DBRepository) CheckSchema() ([]SchemaDrift, error) {
+	drifts := make([]SchemaDrift, 0)
+
+	tablenames := make([]string, 0, len(dbr.factory.tablename2type))
+	for tablename := range dbr.factory.tablename2type {
+		tablenames = append(tablenames, tablename)
+	}
+	sort.Strings(tablenames)
+
+	for _, name := range tablenames {
+		dbe := dbr.factory.tablename2type[name]
+		tablename := dbr.buildTableName(dbe)
+		live, err := dbr.readLiveColumns(tablename)
+		if err != nil {
+			return nil, err
+		}
+		if live == nil {
+			drifts = append(drifts, SchemaDrift{Table: tablename, Problem: "missing_table"})
+			continue
+		}
+
+		for _, col := range dbe.GetColumns() {
+			liveCol, exists := live[col.Name]
+			if !exists {
+				drifts = append(drifts, SchemaDrift{Table: tablename, Column: col.Name, Problem: "missing_column", Expected: col.Type})
+				continue
+			}
+			if normalizeColumnType(col.Type) != normalizeColumnType(liveCol.columnType) {
+				drifts = append(drifts, SchemaDrift{Table: tablename, Column: col.Name, Problem: "type", Expected: col.Type, Actual: liveCol.columnType})
+			}
+			if isNotNull(col) == liveCol.nullable {
+				drifts = append(drifts, SchemaDrift{
+					Table: tablename, Column: col.Name, Problem: "nullable",
+					Expected: fmt.Sprint(!isNotNull(col)), Actual: fmt.Sprint(liveCol.nullable),
+				})
+			}
+		}
+
+		liveNames := make([]string, 0, len(live))
+		for colName := range live {
+			liveNames = append(liveNames, colName)
+		}
+		sort.Strings(liveNames)
+		for _, colName := range liveNames {
+			if !dbe.HasColumn(colName) {
+				drifts = append(drifts, SchemaDrift{Table: tablename, Column: colName, Problem: "extra_column", Actual: live[colName].columnType})
+			}
+		}
+	}
+
+	if dbr.Verbose {
+		for _, drift := range drifts {
+			log.Print("DBRepository::CheckSchema: ", drift)
+		}
+	}
+	return drifts, nil
+}
@@ -0,0 +1,32 @@
+package dblayer
+
+import (
+	"testing"
+)
+
+func TestGetCreateTableSQL(t *testing.T) {
+	dbContext := &DBContext{Schema: "rprj"}
+	expected := "CREATE TABLE IF NOT EXISTS `rprj_users` (\n" +
+		"  `id` varchar(16) NOT NULL,\n" +
+		"  `login` varchar(255) NOT NULL,\n" +
+		"  `pwd` varchar(255) NOT NULL,\n" +
+		"  `pwd_salt` varchar(4) DEFAULT '',\n" +
+		"  `fullname` text DEFAULT NULL,\n" +
+		"  `group_id` varchar(16) NOT NULL,\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  KEY `rprj_users_0` (`id`),\n" +
+		"  KEY `rprj_users_1` (`group_id`)\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci"
+	if ddl := GetCreateTableSQL(dbContext, NewDBUser()); ddl != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", ddl, expected)
+	}
+}
+
+func TestNormalizeColumnType(t *testing.T) {
+	if normalizeColumnType("int(11)") != normalizeColumnType("INT") {
+		t.Error("int(11) and INT must match")
+	}
+	if normalizeColumnType("varchar(16)") == normalizeColumnType("varchar(255)") {
+		t.Error("varchar lengths must not match")
+	}
+}
@@ -1,28 +1,59 @@
 package dblayer
 
-import "log"
+import (
+	"log"
+	"sort"
+)
 
 type DBEFactory struct {
 	verbose        bool
-	classname2type map[string]*DBEntity
-	tablename2type map[string]*DBEntity
+	classname2type map[string]DBEntityInterface
+	tablename2type map[string]DBEntityInterface
 }
 
 func NewDBEFactory(verbose bool) *DBEFactory {
 	ret := &DBEFactory{
 		verbose: verbose,
 	}
-	ret.classname2type = make(map[string]*DBEntity)
-	ret.tablename2type = make(map[string]*DBEntity)
+	ret.classname2type = make(map[string]DBEntityInterface)
+	ret.tablename2type = make(map[string]DBEntityInterface)
 
 	return ret
 }
 
-func (dbef *DBEFactory) Register(dbe *DBEntity) {
+/*
+Returns a factory with all the entities of the project registered
+*/
+func NewDefaultDBEFactory(verbose bool) *DBEFactory {
+	ret := NewDBEFactory(verbose)
+	// Framework
+	ret.Register(NewDBVersion())
+	ret.Register(NewDBUser())
+	ret.Register(NewDBGroup())
+	ret.Register(NewDBUserGroup())
+	ret.Register(NewDBOAuthToken())
+	ret.Register(NewDBCalendarFeed())
+	ret.Register(NewDBSearchIndex())
+	ret.Register(NewDBObject())
+	// Contacts
+	ret.Register(NewDBCountry())
+	ret.Register(NewDBCompany())
+	ret.Register(NewDBPerson())
+	// CMS
+	ret.Register(NewDBEvent())
+	ret.Register(NewDBFile())
+	ret.Register(NewDBFolder())
+	ret.Register(NewDBLink())
+	ret.Register(NewDBNote())
+	ret.Register(NewDBPage())
+	ret.Register(NewDBNews())
+	return ret
+}
+
+func (dbef *DBEFactory) Register(dbe DBEntityInterface) {
 	if dbef.verbose {
 		log.Print("DBEFactory: Registering DBEntity:", dbe.GetTypeName(), "->", dbe.GetTableName())
 	}
-	log.Print("dbef registering: dbe = ", dbe)
 	dbef.classname2type[dbe.GetTypeName()] = dbe
 	dbef.tablename2type[dbe.GetTableName()] = dbe
 }
@@ -32,17 +63,35 @@ func (dbef *DBEFactory) GetAllClassNames() []string {
 	for className := range dbef.classname2type {
 		ret = append(ret, className)
 	}
+	sort.Strings(ret)
+	return ret
+}
+
+/*
+Returns the class names of the registered DBObject subclasses.
+DBObject itself is abstract and is not included.
+*/
+func (dbef *DBEFactory) GetAllDBObjectClassNames() []string {
+	ret := make([]string, 0)
+	for _, className := range dbef.GetAllClassNames() {
+		if className == "DBObject" {
+			continue
+		}
+		if _, isObject := dbef.classname2type[className].(DBObjectInterface); isObject {
+			ret = append(ret, className)
+		}
+	}
 	return ret
 }
 
-func (dbef *DBEFactory) GetInstanceByClassName(className string) *DBEntity {
+func (dbef *DBEFactory) GetInstanceByClassName(className string) DBEntityInterface {
 	if dbeType, exists := dbef.classname2type[className]; exists {
 		return dbeType.NewInstance()
 	}
 	return nil
 }
 
-func (dbef *DBEFactory) GetInstanceByTableName(tableName string) *DBEntity {
+func (dbef *DBEFactory) GetInstanceByTableName(tableName string) DBEntityInterface {
 	if dbeType, exists := dbef.tablename2type[tableName]; exists {
 		return dbeType.NewInstance()
 	}
@@ -0,0 +1,37 @@
+package dblayer
+
+import (
+	"strings"
+	"testing"
+)
+
+/*
+The factory must return instances of the registered concrete type,
+so that overridden methods (ie. the lifecycle hooks) are called.
+*/
+func TestFactoryKeepsConcreteType(t *testing.T) {
+	factory := NewDBEFactory(false)
+	factory.Register(NewDBUser())
+	factory.Register(NewDBGroup())
+
+	instance := factory.GetInstanceByClassName("DBUser")
+	if _, ok := instance.(*DBUser); !ok {
+		t.Fatalf("expected *DBUser, got %T", instance)
+	}
+	instance = factory.GetInstanceByTableName("groups")
+	if _, ok := instance.(*DBGroup); !ok {
+		t.Fatalf("expected *DBGroup, got %T", instance)
+	}
+	if factory.GetInstanceByClassName("DBNothing") != nil {
+		t.Fatal("expected nil for an unknown class name")
+	}
+}
+
+func TestFactoryDBObjectClassNames(t *testing.T) {
+	factory := NewDefaultDBEFactory(false)
+	classNames := factory.GetAllDBObjectClassNames()
+	expected := []string{"DBCompany", "DBEvent", "DBFile", "DBFolder", "DBLink", "DBNews", "DBNote", "DBPage", "DBPerson"}
+	if strings.Join(classNames, ",") != strings.Join(expected, ",") {
+		t.Fatalf("got %v, want %v", classNames, expected)
+	}
+}
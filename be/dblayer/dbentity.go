@@ -1,8 +1,13 @@
 package dblayer
 
 import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
 	"sort"
 	"strings"
+	"time"
 )
 
 type ForeignKey struct {
@@ -17,10 +22,66 @@ type Column struct {
 	Constraints []string
 }
 
+/*
+DBEntityInterface is what DBEFactory and DBRepository operate on.
+
+DBEntity implements it; a "subclass" embeds DBEntity and overrides
+the methods it needs (ie. NewInstance and the lifecycle hooks).
+*/
+type DBEntityInterface interface {
+	GetTypeName() string
+	GetTableName() string
+	GetColumnType(columnName string) string
+	HasColumn(columnName string) bool
+	GetColumns() []Column
+	GetKeys() []string
+	GetForeignKeys() []ForeignKey
+	GetForeignKeysForTable(tableName string) []ForeignKey
+	GetOrderBy() []string
+
+	NewInstance() DBEntityInterface
+
+	SetValue(columnName string, value string)
+	GetValue(columnName string) string
+	HasValue(columnName string) bool
+	IsNull(columnName string) bool
+	SetNull(columnName string)
+	SetInt(columnName string, value int64)
+	GetInt(columnName string) int64
+	GetNullInt(columnName string) sql.NullInt64
+	SetFloat(columnName string, value float64)
+	GetFloat(columnName string) float64
+	GetNullFloat(columnName string) sql.NullFloat64
+	SetTime(columnName string, value time.Time)
+	GetTime(columnName string) time.Time
+	GetNullTime(columnName string) sql.NullTime
+	SetBool(columnName string, value bool)
+	GetBool(columnName string) bool
+	GetNullBool(columnName string) sql.NullBool
+	GetNullString(columnName string) sql.NullString
+	GetColumnKind(columnName string) ColumnKind
+	GetDictionaryKeys() []string
+	IsPrimaryKey(columnName string) bool
+	IsForeignKey(columnName string) bool
+	IsNew() bool
+
+	BeforeInsert(dbr *DBRepository, tx *sql.Tx) error
+	AfterInsert(dbr *DBRepository, tx *sql.Tx) error
+	BeforeUpdate(dbr *DBRepository, tx *sql.Tx) error
+	AfterUpdate(dbr *DBRepository, tx *sql.Tx) error
+	BeforeDelete(dbr *DBRepository, tx *sql.Tx) error
+	AfterDelete(dbr *DBRepository, tx *sql.Tx) error
+
+	getDictionary() map[string]any
+	setValueFromDB(columnName string, raw any)
+	getValueToDB(columnName string) any
+}
+
 type DBEntity struct {
 	typename    string
 	tablename   string
 	columns     map[string]Column
+	columnNames []string // the columns in the order of definition
 	keys        []string
 	foreignKeys []ForeignKey
 	dictionary  map[string]any
@@ -28,13 +89,16 @@ type DBEntity struct {
 
 func NewDBEntity(typename string, tablename string, columns []Column, keys []string, foreignKeys []ForeignKey, dictionary map[string]any) *DBEntity {
 	columnsMap := make(map[string]Column)
+	columnNames := make([]string, 0, len(columns))
 	for _, col := range columns {
 		columnsMap[col.Name] = col
+		columnNames = append(columnNames, col.Name)
 	}
 	return &DBEntity{
 		typename:    typename,
 		tablename:   tablename,
 		columns:     columnsMap,
+		columnNames: columnNames,
 		keys:        keys,
 		foreignKeys: foreignKeys,
 		dictionary:  dictionary,
@@ -42,12 +106,19 @@ func NewDBEntity(typename string, tablename string, columns []Column, keys []str
 }
 
 /* Override */
-func (dbEntity *DBEntity) NewInstance() *DBEntity {
-	columns := make([]Column, 0, len(dbEntity.columns))
-	for _, col := range dbEntity.columns {
-		columns = append(columns, col)
+func (dbEntity *DBEntity) NewInstance() DBEntityInterface {
+	return NewDBEntity(dbEntity.typename, dbEntity.tablename, dbEntity.GetColumns(), dbEntity.keys, dbEntity.foreignKeys, make(map[string]any))
+}
+
+/*
+Returns the column definitions in the order they were declared
+*/
+func (dbEntity *DBEntity) GetColumns() []Column {
+	columns := make([]Column, 0, len(dbEntity.columnNames))
+	for _, name := range dbEntity.columnNames {
+		columns = append(columns, dbEntity.columns[name])
 	}
-	return NewDBEntity(dbEntity.typename, dbEntity.tablename, columns, dbEntity.keys, dbEntity.foreignKeys, make(map[string]any))
+	return columns
 }
 
 func (dbEntity *DBEntity) GetColumnType(columnName string) string {
@@ -56,6 +127,10 @@ func (dbEntity *DBEntity) GetColumnType(columnName string) string {
 	}
 	return ""
 }
+func (dbEntity *DBEntity) HasColumn(columnName string) bool {
+	_, exists := dbEntity.columns[columnName]
+	return exists
+}
 func (dbEntity *DBEntity) GetTypeName() string {
 	return dbEntity.typename
 }
@@ -92,15 +167,22 @@ func (dbEntity *DBEntity) GetForeignKeyDefinition(columnName string) *ForeignKey
 	return nil
 }
 
-// TODO? Manage different types of values (int, date, etc.)
+/*
+Sets the value as a string: it is converted to the column type when written.
+See dbtypes.go for the typed setters and getters.
+*/
 func (dbEntity *DBEntity) SetValue(columnName string, value string) {
 	// if _, exists := dbEntity.dictionary[columnName]; exists {
 	dbEntity.dictionary[columnName] = value
 	// }
 }
+
+/*
+Returns the value as a string, whatever its type
+*/
 func (dbEntity *DBEntity) GetValue(columnName string) string {
 	if val, exists := dbEntity.dictionary[columnName]; exists {
-		return val.(string)
+		return formatValue(dbEntity.GetColumnKind(columnName), val)
 	}
 	return ""
 }
@@ -108,14 +190,17 @@ func (dbEntity *DBEntity) HasValue(columnName string) bool {
 	_, exists := dbEntity.dictionary[columnName]
 	return exists
 }
-func (dbEntity *DBEntity) ReadFKFrom(dbe *DBEntity) {
+func (dbEntity *DBEntity) getDictionary() map[string]any {
+	return dbEntity.dictionary
+}
+func (dbEntity *DBEntity) ReadFKFrom(dbe DBEntityInterface) {
 	fks := dbEntity.GetForeignKeysForTable(dbe.GetTableName())
 	for _, fk := range fks {
 		value := dbe.GetValue(fk.RefColumn)
 		dbEntity.SetValue(fk.Column, value)
 	}
 }
-func (dbEntity *DBEntity) WriteToFK(dbe *DBEntity) {
+func (dbEntity *DBEntity) WriteToFK(dbe DBEntityInterface) {
 	fks := dbEntity.GetForeignKeysForTable(dbe.GetTableName())
 	for _, fk := range fks {
 		value := dbEntity.GetValue(fk.Column)
@@ -139,6 +224,22 @@ func (dbEntity *DBEntity) IsForeignKey(columnName string) bool {
 	return false
 }
 
+/*
+The JSON representation of an entity is its dictionary.
+Dates are written in the db format, NULL as null.
+*/
+func (dbEntity *DBEntity) MarshalJSON() ([]byte, error) {
+	values := make(map[string]any, len(dbEntity.dictionary))
+	for key, val := range dbEntity.dictionary {
+		if t, ok := val.(time.Time); ok {
+			values[key] = formatTime(dbEntity.GetColumnKind(key), t)
+		} else {
+			values[key] = val
+		}
+	}
+	return json.Marshal(values)
+}
+
 /*
 Returns the dictionary keys which means all values set in the entity
 */
@@ -155,7 +256,7 @@ func (dbEntity *DBEntity) GetDictionaryValues() []string {
 	keys := dbEntity.GetDictionaryKeys() // If I use this, the sorting of the keys may be unnecessary
 	values := make([]string, 0, len(keys))
 	for _, key := range keys {
-		values = append(values, dbEntity.dictionary[key].(string))
+		values = append(values, dbEntity.GetValue(key))
 	}
 	return values
 }
@@ -166,8 +267,8 @@ Returns a dictionary of the keys set in the entity
 func (dbEntity *DBEntity) GetKeySetDictionary() map[string]string {
 	result := make(map[string]string)
 	for _, key := range dbEntity.keys {
-		if val, exists := dbEntity.dictionary[key]; exists {
-			result[key] = val.(string)
+		if _, exists := dbEntity.dictionary[key]; exists {
+			result[key] = dbEntity.GetValue(key)
 		}
 	}
 	return result
@@ -194,32 +295,46 @@ func (dbEntity *DBEntity) IsNew() bool {
 	return true
 }
 
-func (dbEntity *DBEntity) beforeInsert(dbRepository *DBRepository) error {
+/*
+Lifecycle hooks (to be overridden): they are executed by DBRepository inside the same
+transaction used for the INSERT, UPDATE or DELETE statement.
+Returning an error aborts the operation and rolls back the transaction.
+*/
+func (dbEntity *DBEntity) BeforeInsert(dbr *DBRepository, tx *sql.Tx) error {
 	// Implement any logic needed before inserting the entity into the database
 	return nil
 }
 
-func (dbEntity *DBEntity) afterInsert(dbRepository *DBRepository) error {
+func (dbEntity *DBEntity) AfterInsert(dbr *DBRepository, tx *sql.Tx) error {
 	// Implement any logic needed after inserting the entity into the database
 	return nil
 }
 
-func (dbEntity *DBEntity) beforeUpdate(dbRepository *DBRepository) error {
+func (dbEntity *DBEntity) BeforeUpdate(dbr *DBRepository, tx *sql.Tx) error {
 	// Implement any logic needed before updating the entity in the database
 	return nil
 }
 
-func (dbEntity *DBEntity) afterUpdate(dbRepository *DBRepository) error {
+func (dbEntity *DBEntity) AfterUpdate(dbr *DBRepository, tx *sql.Tx) error {
 	// Implement any logic needed after updating the entity in the database
 	return nil
 }
 
-func (dbEntity *DBEntity) beforeDelete(dbRepository *DBRepository) error {
+func (dbEntity *DBEntity) BeforeDelete(dbr *DBRepository, tx *sql.Tx) error {
 	// Implement any logic needed before deleting the entity from the database
 	return nil
 }
 
-func (dbEntity *DBEntity) afterDelete(dbRepository *DBRepository) error {
+func (dbEntity *DBEntity) AfterDelete(dbr *DBRepository, tx *sql.Tx) error {
 	// Implement any logic needed after deleting the entity from the database
 	return nil
 }
+
+/* Generate a random UUID-like string of 16 hex characters */
+func UUID16Hex() (string, error) {
+	b := make([]byte, 8) // 8 bytes = 16 hex chars
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,223 @@
+package dblayer
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+Operators of the filters: see Filter
+*/
+type FilterOp string
+
+const (
+	OpEq         FilterOp = "eq"
+	OpNe         FilterOp = "ne"
+	OpLt         FilterOp = "lt"
+	OpLte        FilterOp = "lte"
+	OpGt         FilterOp = "gt"
+	OpGte        FilterOp = "gte"
+	OpBetween    FilterOp = "between"
+	OpIn         FilterOp = "in"
+	OpIsNull     FilterOp = "null"
+	OpNotNull    FilterOp = "notnull"
+	OpStartsWith FilterOp = "starts"
+	OpOr         FilterOp = "or"
+	OpAnd        FilterOp = "and"
+)
+
+var comparisonOperators = map[FilterOp]string{
+	OpEq:  " = ?",
+	OpLt:  " < ?",
+	OpLte: " <= ?",
+	OpGt:  " > ?",
+	OpGte: " >= ?",
+}
+
+/*
+A condition on a column, or a group (OpOr, OpAnd) of conditions.
+The filters passed to Search are in AND with each other and with the values set in the entity, ie.
+
+	repo.Search(NewDBEvent(), false, false, "start_date",
+		Between("start_date", from, to),
+		Or(Eq("fk_obj_id", projectID), IsNull("fk_obj_id")))
+
+The values are converted like the values of the entity: strings are parsed
+according to the type of the column (ie. "2025-01-01" for a datetime).
+*/
+type Filter struct {
+	Column  string
+	Op      FilterOp
+	Values  []any
+	Filters []Filter
+}
+
+func Eq(column string, value any) Filter {
+	return Filter{Column: column, Op: OpEq, Values: []any{value}}
+}
+
+func Ne(column string, value any) Filter {
+	return Filter{Column: column, Op: OpNe, Values: []any{value}}
+}
+
+func Lt(column string, value any) Filter {
+	return Filter{Column: column, Op: OpLt, Values: []any{value}}
+}
+
+func Lte(column string, value any) Filter {
+	return Filter{Column: column, Op: OpLte, Values: []any{value}}
+}
+
+func Gt(column string, value any) Filter {
+	return Filter{Column: column, Op: OpGt, Values: []any{value}}
+}
+
+func Gte(column string, value any) Filter {
+	return Filter{Column: column, Op: OpGte, Values: []any{value}}
+}
+
+// from <= column <= to
+func Between(column string, from any, to any) Filter {
+	return Filter{Column: column, Op: OpBetween, Values: []any{from, to}}
+}
+
+func In(column string, values ...any) Filter {
+	return Filter{Column: column, Op: OpIn, Values: values}
+}
+
+func IsNull(column string) Filter    { return Filter{Column: column, Op: OpIsNull} }
+func IsNotNull(column string) Filter { return Filter{Column: column, Op: OpNotNull} }
+
+// column LIKE 'prefix%', with the wildcards of prefix escaped
+func StartsWith(column string, prefix string) Filter {
+	return Filter{Column: column, Op: OpStartsWith, Values: []any{prefix}}
+}
+
+func Or(filters ...Filter) Filter  { return Filter{Op: OpOr, Filters: filters} }
+func And(filters ...Filter) Filter { return Filter{Op: OpAnd, Filters: filters} }
+
+/*
+Returns the operator with the given name (ie. "gte"), false if unknown
+*/
+func ParseFilterOp(name string) (FilterOp, bool) {
+	op := FilterOp(strings.ToLower(name))
+	switch op {
+	case OpEq, OpNe, OpLt, OpLte, OpGt, OpGte, OpBetween, OpIn, OpIsNull, OpNotNull, OpStartsWith:
+		return op, true
+	}
+	return "", false
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+/*
+Converts a value of a filter to the value to write in the query,
+validating the strings against the column definition (see SetValuesFromMap)
+*/
+func filterValue(dbe DBEntityInterface, column Column, value any) (any, error) {
+	s, isString := value.(string)
+	if !isString {
+		return convertToDB(GetColumnKind(column.Type), value), nil
+	}
+	probe := dbe.NewInstance()
+	if err := setValueFromJSON(probe, column, s); err != nil {
+		return nil, err
+	}
+	return probe.getValueToDB(column.Name), nil
+}
+
+func findColumn(dbe DBEntityInterface, name string) (Column, bool) {
+	for _, col := range dbe.GetColumns() {
+		if col.Name == name {
+			return col, true
+		}
+	}
+	return Column{}, false
+}
+
+/*
+Builds the SQL condition of the filter on the columns of dbe
+*/
+func buildFilter(dbe DBEntityInterface, filter Filter) (string, []interface{}, error) {
+	if filter.Op == OpOr || filter.Op == OpAnd {
+		if len(filter.Filters) == 0 {
+			return "", nil, &ValidationError{Message: "empty " + string(filter.Op) + " group"}
+		}
+		clauses, args, err := buildFilters(dbe, filter.Filters)
+		if err != nil {
+			return "", nil, err
+		}
+		return "(" + strings.Join(clauses, " "+strings.ToUpper(string(filter.Op))+" ") + ")", args, nil
+	}
+
+	col, exists := findColumn(dbe, filter.Column)
+	if !exists {
+		return "", nil, &ValidationError{Column: filter.Column, Message: "unknown column"}
+	}
+	column := QuoteIdentifier(col.Name)
+
+	expected := 1
+	switch filter.Op {
+	case OpIsNull:
+		return column + " IS NULL", nil, nil
+	case OpNotNull:
+		return column + " IS NOT NULL", nil, nil
+	case OpBetween:
+		expected = 2
+	case OpIn:
+		expected = len(filter.Values)
+		if expected == 0 {
+			return "", nil, &ValidationError{Column: col.Name, Message: "in: expected at least a value"}
+		}
+	}
+	if len(filter.Values) != expected {
+		return "", nil, &ValidationError{Column: col.Name, Message: fmt.Sprintf("%s: expected %d values", filter.Op, expected)}
+	}
+
+	if filter.Op == OpStartsWith {
+		// The backslash is the default escape character of LIKE
+		return column + " LIKE ?", []interface{}{escapeLike(fmt.Sprint(filter.Values[0])) + "%"}, nil
+	}
+
+	args := make([]interface{}, 0, len(filter.Values))
+	for _, value := range filter.Values {
+		v, err := filterValue(dbe, col, value)
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, v)
+	}
+
+	switch filter.Op {
+	case OpNe:
+		// NULL is different from any value
+		return "NOT (" + column + " <=> ?)", args, nil
+	case OpBetween:
+		return column + " BETWEEN ? AND ?", args, nil
+	case OpIn:
+		return column + " IN (?" + strings.Repeat(", ?", len(args)-1) + ")", args, nil
+	}
+	if sqlOp, ok := comparisonOperators[filter.Op]; ok {
+		return column + sqlOp, args, nil
+	}
+	return "", nil, &ValidationError{Column: col.Name, Message: "unknown operator '" + string(filter.Op) + "'"}
+}
+
+/*
+Builds the conditions of the filters, to join with AND (or OR for the groups)
+*/
+func buildFilters(dbe DBEntityInterface, filters []Filter) ([]string, []interface{}, error) {
+	clauses := make([]string, 0, len(filters))
+	args := make([]interface{}, 0)
+	for _, filter := range filters {
+		clause, filterArgs, err := buildFilter(dbe, filter)
+		if err != nil {
+			return nil, nil, err
+		}
+		clauses = append(clauses, clause)
+		args = append(args, filterArgs...)
+	}
+	return clauses, args, nil
+}
@@ -0,0 +1,68 @@
+package dblayer
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildFilters(t *testing.T) {
+	event := NewDBEvent()
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local)
+	clauses, args, err := buildFilters(event, []Filter{
+		Between("start_date", from, "2025-01-31 23:59:59"),
+		Gte("alarm_minute", "15"),
+		In("alarm_unit", "1", "2"),
+		Ne("name", "x"),
+		StartsWith("name", "50%_"),
+		Or(IsNull("fk_obj_id"), Eq("fk_obj_id", "abc")),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"`start_date` BETWEEN ? AND ?",
+		"`alarm_minute` >= ?",
+		"`alarm_unit` IN (?, ?)",
+		"NOT (`name` <=> ?)",
+		"`name` LIKE ?",
+		"(`fk_obj_id` IS NULL OR `fk_obj_id` = ?)",
+	}
+	if !reflect.DeepEqual(clauses, expected) {
+		t.Errorf("clauses:\n%s", strings.Join(clauses, "\n"))
+	}
+	expectedArgs := []interface{}{"2025-01-01 00:00:00", "2025-01-31 23:59:59", "15", "1", "2", "x", `50\%\_%`, "abc"}
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Errorf("args: %#v", args)
+	}
+}
+
+func TestBuildFiltersValidation(t *testing.T) {
+	event := NewDBEvent()
+	cases := []Filter{
+		Eq("nonexistent", "x"),
+		Gte("alarm_minute", "abc"),
+		Lt("start_date", "yesterday"),
+		{Column: "name", Op: OpBetween, Values: []any{"a"}},
+		In("name"),
+		Or(),
+		{Column: "name", Op: "like", Values: []any{"a"}},
+	}
+	for _, filter := range cases {
+		var validationError *ValidationError
+		if _, _, err := buildFilter(event, filter); !errors.As(err, &validationError) {
+			t.Errorf("%+v: expected a ValidationError, got %v", filter, err)
+		}
+	}
+}
+
+func TestParseFilterOp(t *testing.T) {
+	if op, ok := ParseFilterOp("GTE"); !ok || op != OpGte {
+		t.Errorf("got %v %v", op, ok)
+	}
+	if _, ok := ParseFilterOp("or"); ok {
+		t.Error("groups are not operators")
+	}
+}
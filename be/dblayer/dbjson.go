@@ -0,0 +1,151 @@
+package dblayer
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+/*
+A value that does not match the definition of its column
+*/
+type ValidationError struct {
+	Column  string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Column == "" {
+		return e.Message
+	}
+	return e.Column + ": " + e.Message
+}
+
+/*
+Columns written by the hooks of DBObject and by Delete/Restore: they cannot be set by the clients
+*/
+var dbObjectAuditColumns = map[string]bool{
+	"creator": true, "creation_date": true,
+	"last_modify": true, "last_modify_date": true,
+	"deleted_by": true, "deleted_date": true,
+}
+
+/*
+Entities with other columns that must not be written by the clients,
+ie. the columns of DBFile pointing to the stored content
+*/
+type managedColumnsInterface interface {
+	IsManagedColumn(columnName string) bool
+}
+
+/*
+Returns true if the column is managed by the dblayer and must not be written by the clients
+*/
+func IsAuditColumn(dbe DBEntityInterface, columnName string) bool {
+	if managed, ok := dbe.(managedColumnsInterface); ok && managed.IsManagedColumn(columnName) {
+		return true
+	}
+	_, isObject := dbe.(DBObjectInterface)
+	return isObject && dbObjectAuditColumns[columnName]
+}
+
+func maxLength(columnType string) int {
+	t := strings.ToLower(columnType)
+	var n int
+	if _, err := fmt.Sscanf(t, "varchar(%d)", &n); err == nil {
+		return n
+	}
+	if _, err := fmt.Sscanf(t, "char(%d)", &n); err == nil {
+		return n
+	}
+	return 0
+}
+
+/*
+Sets the values decoded from a JSON object (see encoding/json) into the entity,
+checking them against the column definitions:
+unknown columns, wrong types, too long strings and NULL in NOT NULL columns are rejected
+with a *ValidationError. Audit columns of the DBObjects are ignored.
+*/
+func SetValuesFromMap(dbe DBEntityInterface, values map[string]any) error {
+	columns := make(map[string]Column)
+	for _, col := range dbe.GetColumns() {
+		columns[col.Name] = col
+	}
+	for name, value := range values {
+		col, exists := columns[name]
+		if !exists {
+			return &ValidationError{Column: name, Message: "unknown column"}
+		}
+		if IsAuditColumn(dbe, name) {
+			continue
+		}
+		if err := setValueFromJSON(dbe, col, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setValueFromJSON(dbe DBEntityInterface, col Column, value any) error {
+	kind := GetColumnKind(col.Type)
+	if value == nil {
+		if isNotNull(col) {
+			return &ValidationError{Column: col.Name, Message: "cannot be null"}
+		}
+		dbe.SetNull(col.Name)
+		return nil
+	}
+
+	switch v := value.(type) {
+	case string:
+		switch kind {
+		case KindInt:
+			if _, ok := convertFromDB(KindInt, v).(int64); !ok {
+				return &ValidationError{Column: col.Name, Message: "expected an integer"}
+			}
+		case KindFloat:
+			if _, ok := convertFromDB(KindFloat, v).(float64); !ok {
+				return &ValidationError{Column: col.Name, Message: "expected a number"}
+			}
+		case KindDateTime, KindDate, KindTime:
+			t, ok := parseTime(kind, v)
+			if !ok {
+				return &ValidationError{Column: col.Name, Message: "invalid date/time '" + v + "'"}
+			}
+			dbe.SetTime(col.Name, t)
+			return nil
+		}
+		if max := maxLength(col.Type); max > 0 && len([]rune(v)) > max {
+			return &ValidationError{Column: col.Name, Message: fmt.Sprintf("longer than %d chars", max)}
+		}
+		dbe.SetValue(col.Name, v)
+	case float64:
+		switch kind {
+		case KindInt:
+			if v != math.Trunc(v) {
+				return &ValidationError{Column: col.Name, Message: "expected an integer"}
+			}
+			dbe.SetInt(col.Name, int64(v))
+		case KindFloat:
+			dbe.SetFloat(col.Name, v)
+		case KindFlag:
+			// Flags, but also one digit codes (ie. recurrence_type)
+			if v != math.Trunc(v) || v < 0 || v > 9 {
+				return &ValidationError{Column: col.Name, Message: "expected a digit"}
+			}
+			dbe.SetValue(col.Name, strconv.Itoa(int(v)))
+		default:
+			return &ValidationError{Column: col.Name, Message: "expected a string"}
+		}
+	case bool:
+		if kind != KindFlag && kind != KindInt {
+			return &ValidationError{Column: col.Name, Message: "unexpected boolean"}
+		}
+		dbe.SetBool(col.Name, v)
+	default:
+		return &ValidationError{Column: col.Name, Message: fmt.Sprintf("unsupported value %v", value)}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package dblayer
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSetValuesFromMap(t *testing.T) {
+	event := NewDBEvent()
+	err := SetValuesFromMap(event, map[string]any{
+		"name":            "Meeting",
+		"start_date":      "2025-03-01 10:00:00",
+		"all_day":         false,
+		"alarm_minute":    float64(15),
+		"recurrence_type": float64(2),
+		"description":     nil,
+		"creator":         "someone else",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.GetTime("start_date").Format(DateTimeFormat) != "2025-03-01 10:00:00" {
+		t.Errorf("start_date: got %v", event.GetValue("start_date"))
+	}
+	if event.GetBool("all_day") || event.GetInt("alarm_minute") != 15 || event.GetValue("recurrence_type") != "2" {
+		t.Errorf("unexpected values: %v", event)
+	}
+	if !event.IsNull("description") {
+		t.Error("description must be NULL")
+	}
+	if event.HasValue("creator") {
+		t.Error("audit columns must be ignored")
+	}
+}
+
+func TestSetValuesFromMapFileContent(t *testing.T) {
+	file := NewDBFile()
+	err := SetValuesFromMap(file, map[string]any{
+		"name":     "Contract",
+		"filename": "contract.pdf",
+		"path":     "2025/10/someone-elses-file",
+		"checksum": "da39a3ee5e6b4b0d3255bfef95601890afd80709",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.HasValue("path") || file.HasValue("checksum") {
+		t.Error("the content columns must be ignored")
+	}
+	if file.GetValue("filename") != "contract.pdf" {
+		t.Errorf("filename: got %q", file.GetValue("filename"))
+	}
+}
+
+func TestSetValuesFromMapValidation(t *testing.T) {
+	cases := []map[string]any{
+		{"nonexistent": "x"},
+		{"alarm_minute": "abc"},
+		{"alarm_minute": 1.5},
+		{"start_date": "yesterday"},
+		{"name": nil},
+		{"name": float64(3)},
+		{"id": "12345678901234567"},
+	}
+	for _, values := range cases {
+		err := SetValuesFromMap(NewDBEvent(), values)
+		var validationError *ValidationError
+		if !errors.As(err, &validationError) {
+			t.Errorf("%v: expected a ValidationError, got %v", values, err)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package dblayer
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ErrSchemaAhead = errors.New("db schema is newer than this binary")
+
+/*
+A migration brings the tables of a model from Version-1 to Version.
+
+Statements are executed in order; "{prefix}" is replaced with the table prefix
+of the context (ie. "rprj_"). Apply, if set, is executed after the statements.
+Beware: in MariaDB the DDL statements (CREATE, ALTER, ...) commit implicitly.
+*/
+type Migration struct {
+	ModelName   string
+	Version     int
+	Description string
+	Statements  []string
+	Apply       func(dbr *DBRepository, tx *sql.Tx) error
+}
+
+/*
+The state of a model: the version in the dbversion table and the migrations still to apply
+*/
+type MigrationStatus struct {
+	ModelName      string
+	CurrentVersion int
+	LatestVersion  int
+	Pending        []Migration
+}
+
+type MigrationRunner struct {
+	Verbose    bool
+	repo       *DBRepository
+	migrations map[string][]Migration
+}
+
+func NewMigrationRunner(repo *DBRepository, migrations []Migration) *MigrationRunner {
+	ret := &MigrationRunner{
+		repo:       repo,
+		migrations: make(map[string][]Migration),
+	}
+	for _, m := range migrations {
+		ret.migrations[m.ModelName] = append(ret.migrations[m.ModelName], m)
+	}
+	for modelName := range ret.migrations {
+		sort.Slice(ret.migrations[modelName], func(i, j int) bool {
+			return ret.migrations[modelName][i].Version < ret.migrations[modelName][j].Version
+		})
+	}
+	return ret
+}
+
+func (runner *MigrationRunner) modelNames() []string {
+	ret := make([]string, 0, len(runner.migrations))
+	for modelName := range runner.migrations {
+		ret = append(ret, modelName)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+/*
+Creates the dbversion table if needed and reads the current version of each model
+*/
+func (runner *MigrationRunner) readVersions() (map[string]int, error) {
+	dbr := runner.repo
+	version := NewDBVersion()
+	if _, err := dbr.DbConnection.ExecContext(dbr.Context(), GetCreateTableSQL(dbr.DbContext, version)); err != nil {
+		return nil, err
+	}
+	results, err := dbr.Search(version, false, false, "")
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]int)
+	for _, row := range results {
+		ret[row.GetValue("model_name")] = int(row.GetInt("version"))
+	}
+	return ret, nil
+}
+
+/*
+Returns the status of every model with migrations, or with a version in the db
+*/
+func (runner *MigrationRunner) Status() ([]MigrationStatus, error) {
+	versions, err := runner.readVersions()
+	if err != nil {
+		return nil, err
+	}
+	modelNames := runner.modelNames()
+	for modelName := range versions {
+		if _, known := runner.migrations[modelName]; !known {
+			modelNames = append(modelNames, modelName)
+		}
+	}
+	sort.Strings(modelNames)
+
+	ret := make([]MigrationStatus, 0, len(modelNames))
+	for _, modelName := range modelNames {
+		status := MigrationStatus{ModelName: modelName, CurrentVersion: versions[modelName], Pending: []Migration{}}
+		for _, m := range runner.migrations[modelName] {
+			status.LatestVersion = m.Version
+			if m.Version > status.CurrentVersion {
+				status.Pending = append(status.Pending, m)
+			}
+		}
+		ret = append(ret, status)
+	}
+	return ret, nil
+}
+
+/*
+Returns ErrSchemaAhead if the db has a model version unknown to this binary
+*/
+func (runner *MigrationRunner) Check() error {
+	statuses, err := runner.Status()
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		if status.CurrentVersion > status.LatestVersion {
+			return fmt.Errorf("%w: model %s is at version %d, the latest known is %d",
+				ErrSchemaAhead, status.ModelName, status.CurrentVersion, status.LatestVersion)
+		}
+	}
+	return nil
+}
+
+/*
+Applies the pending migrations in order and records the progress in dbversion.
+Each migration runs in its own transaction together with the update of its version.
+With dryRun the migrations are only listed.
+*/
+func (runner *MigrationRunner) Up(dryRun bool) ([]Migration, error) {
+	if err := runner.Check(); err != nil {
+		return nil, err
+	}
+	statuses, err := runner.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make([]Migration, 0)
+	for _, status := range statuses {
+		for _, m := range status.Pending {
+			if dryRun {
+				log.Printf("MigrationRunner::Up: would apply %s version %d: %s", m.ModelName, m.Version, m.Description)
+				for _, statement := range m.Statements {
+					log.Print("MigrationRunner::Up:   ", runner.expandStatement(statement))
+				}
+				applied = append(applied, m)
+				continue
+			}
+			if err := runner.apply(m); err != nil {
+				return applied, fmt.Errorf("migration %s version %d failed: %w", m.ModelName, m.Version, err)
+			}
+			applied = append(applied, m)
+		}
+	}
+	return applied, nil
+}
+
+func (runner *MigrationRunner) expandStatement(statement string) string {
+	return strings.ReplaceAll(statement, "{prefix}", BuildTableName(runner.repo.DbContext, ""))
+}
+
+func (runner *MigrationRunner) apply(m Migration) error {
+	dbr := runner.repo
+	log.Printf("MigrationRunner: applying %s version %d: %s", m.ModelName, m.Version, m.Description)
+
+	tx, err := dbr.DbConnection.BeginTx(dbr.Context(), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range m.Statements {
+		statement = runner.expandStatement(statement)
+		if runner.Verbose {
+			log.Print("MigrationRunner: ", statement)
+		}
+		if _, err := tx.ExecContext(dbr.Context(), statement); err != nil {
+			return err
+		}
+	}
+	if m.Apply != nil {
+		if err := m.Apply(dbr, tx); err != nil {
+			return err
+		}
+	}
+
+	// Record the progress
+	version := NewDBVersion()
+	version.SetValue("model_name", m.ModelName)
+	version.SetValue("version", strconv.Itoa(m.Version))
+	var count int
+	if err := tx.QueryRowContext(dbr.Context(), "SELECT COUNT(*) FROM "+dbr.buildTableName(version)+" WHERE model_name = ?", m.ModelName).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		err = dbr.UpdateWithTx(tx, version)
+	} else {
+		err = dbr.InsertWithTx(tx, version)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,387 @@
+package dblayer
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+var ErrPermissionDenied = errors.New("permission denied")
+var ErrAuthenticationRequired = errors.New("authentication required")
+
+const DefaultPermissions = "rwx------"
+
+// The group of the administrators: they can change owner, group and permissions of any object
+const AdminGroupID = "-2"
+
+var permissionsPattern = regexp.MustCompile(`^[r-][w-][x-][r-][w-][x-][r-][w-][x-]$`)
+
+/* Format used to write datetime columns */
+const DateTimeFormat = "2006-01-02 15:04:05"
+
+/* Value of deleted_date for the objects not deleted */
+const ZeroDateTime = "0000-00-00 00:00:00"
+
+/*
+Position of the permission flags in the 9 chars string, ie. rwxrw-r--
+*/
+const (
+	permRead    = 0
+	permWrite   = 1
+	permExecute = 2
+
+	permUser  = 0
+	permGroup = 3
+	permAll   = 6
+)
+
+/*
+Common columns of every DBObject table.
+In the db they are replicated for each table.
+*/
+func dbObjectColumns() []Column {
+	return []Column{
+		{Name: "id", Type: "varchar(16)", Constraints: []string{"NOT NULL"}},
+		{Name: "owner", Type: "varchar(16)", Constraints: []string{"NOT NULL"}},
+		{Name: "group_id", Type: "varchar(16)", Constraints: []string{"NOT NULL"}},
+		{Name: "permissions", Type: "char(9)", Constraints: []string{"NOT NULL", "DEFAULT 'rwx------'"}},
+		{Name: "creator", Type: "varchar(16)", Constraints: []string{"NOT NULL"}},
+		{Name: "creation_date", Type: "datetime", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "last_modify", Type: "varchar(16)", Constraints: []string{"NOT NULL"}},
+		{Name: "last_modify_date", Type: "datetime", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "deleted_by", Type: "varchar(16)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "deleted_date", Type: "datetime", Constraints: []string{"NOT NULL", "DEFAULT '0000-00-00 00:00:00'"}},
+		{Name: "father_id", Type: "varchar(16)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "name", Type: "varchar(255)", Constraints: []string{"NOT NULL"}},
+		{Name: "description", Type: "text", Constraints: []string{"DEFAULT NULL"}},
+	}
+}
+
+/*
+DBObjectInterface is implemented by DBObject and all its subclasses.
+*/
+type DBObjectInterface interface {
+	DBEntityInterface
+
+	CanRead(dbctx *DBContext) bool
+	CanWrite(dbctx *DBContext) bool
+	CanExecute(dbctx *DBContext) bool
+	IsDeleted() bool
+	CheckWritePermission(dbr *DBRepository, tx *sql.Tx) error
+	SetDefaultValues(dbr *DBRepository, tx *sql.Tx) error
+}
+
+/*
+CREATE TABLE `rprj_objects` (
+
+	`id` varchar(16) NOT NULL,
+	`owner` varchar(16) NOT NULL,
+	`group_id` varchar(16) NOT NULL,
+	`permissions` char(9) NOT NULL DEFAULT 'rwx------',
+	`creator` varchar(16) NOT NULL,
+	`creation_date` datetime DEFAULT NULL,
+	`last_modify` varchar(16) NOT NULL,
+	`last_modify_date` datetime DEFAULT NULL,
+	`deleted_by` varchar(16) DEFAULT NULL,
+	`deleted_date` datetime NOT NULL DEFAULT '0000-00-00 00:00:00',
+	`father_id` varchar(16) DEFAULT NULL,
+	`name` varchar(255) NOT NULL,
+	`description` text DEFAULT NULL,
+	PRIMARY KEY (`id`),
+	KEY `rprj_objects_0` (`id`),
+	KEY `rprj_objects_1` (`owner`),
+	KEY `rprj_objects_2` (`group_id`),
+	KEY `rprj_objects_3` (`creator`),
+	KEY `rprj_objects_4` (`last_modify`),
+	KEY `rprj_objects_5` (`deleted_by`),
+	KEY `rprj_objects_6` (`father_id`)
+
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+*/
+type DBObject struct {
+	DBEntity
+}
+
+func NewDBObject() *DBObject {
+	return NewDBObjectEntity("DBObject", "objects", []Column{}, []ForeignKey{})
+}
+
+/*
+Builds a DBObject for a subclass: the common columns are added to the given ones.
+*/
+func NewDBObjectEntity(typename string, tablename string, columns []Column, foreignKeys []ForeignKey) *DBObject {
+	allColumns := append(dbObjectColumns(), columns...)
+	keys := []string{"id"}
+	allForeignKeys := append([]ForeignKey{
+		{Column: "owner", RefTable: "users", RefColumn: "id"},
+		{Column: "group_id", RefTable: "groups", RefColumn: "id"},
+		{Column: "creator", RefTable: "users", RefColumn: "id"},
+		{Column: "last_modify", RefTable: "users", RefColumn: "id"},
+		{Column: "deleted_by", RefTable: "users", RefColumn: "id"},
+	}, foreignKeys...)
+	return &DBObject{
+		DBEntity: *NewDBEntity(
+			typename,
+			tablename,
+			allColumns,
+			keys,
+			allForeignKeys,
+			make(map[string]any),
+		),
+	}
+}
+func (dbObject *DBObject) NewInstance() DBEntityInterface {
+	return NewDBObject()
+}
+
+/*
+Returns a *ValidationError if the permissions are not a 9 chars string like rwxr-x---
+*/
+func ValidatePermissions(permissions string) error {
+	if !permissionsPattern.MatchString(permissions) {
+		return &ValidationError{Column: "permissions", Message: fmt.Sprintf("'%s' is not like rwxr-x---", permissions)}
+	}
+	return nil
+}
+
+/*
+Checks a single flag (r, w or x) of the permissions string.
+The user can be the owner, belong to the group or be anybody else:
+the flag is granted if any of the matching triplets allows it.
+Undefined permissions are DefaultPermissions, malformed ones grant nothing.
+*/
+func checkPermission(dbctx *DBContext, owner string, groupID string, permissions string, flag int) bool {
+	if permissions == "" {
+		permissions = DefaultPermissions
+	}
+	if !permissionsPattern.MatchString(permissions) {
+		return false
+	}
+	granted := func(triplet int) bool {
+		return permissions[triplet+flag] != '-'
+	}
+	if granted(permAll) {
+		return true
+	}
+	if dbctx == nil {
+		return false
+	}
+	if dbctx.UserID != "" && dbctx.IsUser(owner) && granted(permUser) {
+		return true
+	}
+	if groupID != "" && dbctx.IsInGroup(groupID) && granted(permGroup) {
+		return true
+	}
+	return false
+}
+
+/*
+Returns true if the object has been soft deleted
+*/
+func (dbObject *DBObject) IsDeleted() bool {
+	deletedDate := dbObject.GetValue("deleted_date")
+	return deletedDate != "" && deletedDate != ZeroDateTime
+}
+
+/*
+Returns the clause selecting the deleted (or not deleted) DBObjects
+*/
+func buildDeletedClause(deleted bool) string {
+	if deleted {
+		return "deleted_date <> '" + ZeroDateTime + "'"
+	}
+	return "deleted_date = '" + ZeroDateTime + "'"
+}
+
+func (dbObject *DBObject) CanRead(dbctx *DBContext) bool {
+	return checkPermission(dbctx, dbObject.GetValue("owner"), dbObject.GetValue("group_id"), dbObject.GetValue("permissions"), permRead)
+}
+func (dbObject *DBObject) CanWrite(dbctx *DBContext) bool {
+	return checkPermission(dbctx, dbObject.GetValue("owner"), dbObject.GetValue("group_id"), dbObject.GetValue("permissions"), permWrite)
+}
+func (dbObject *DBObject) CanExecute(dbctx *DBContext) bool {
+	return checkPermission(dbctx, dbObject.GetValue("owner"), dbObject.GetValue("group_id"), dbObject.GetValue("permissions"), permExecute)
+}
+
+/*
+Checks owner and group given for a new object: only an administrator can create objects
+of other users, or of groups he does not belong to. An empty value is the default.
+*/
+func checkNewOwnership(dbctx *DBContext, owner string, groupID string) error {
+	if dbctx.IsAdmin() {
+		return nil
+	}
+	if owner != "" && !dbctx.IsUser(owner) {
+		return fmt.Errorf("%w: cannot create objects owned by %s", ErrPermissionDenied, owner)
+	}
+	if groupID != "" && !dbctx.IsInGroup(groupID) {
+		return fmt.Errorf("%w: not a member of the group %s", ErrPermissionDenied, groupID)
+	}
+	return nil
+}
+
+/*
+Checks the changes of owner, group and permissions of an object, stored being the values in the db:
+only the owner and the administrators can change them, and the owner can give the object
+only to one of their groups
+*/
+func checkOwnershipChange(dbctx *DBContext, stored *DBObject, changed *DBObject) error {
+	owner, ownerSet := changed.dictionary["owner"]
+	groupID, groupSet := changed.dictionary["group_id"]
+	permissions, permissionsSet := changed.dictionary["permissions"]
+	ownerChanged := ownerSet && fmt.Sprint(owner) != stored.GetValue("owner")
+	groupChanged := groupSet && fmt.Sprint(groupID) != stored.GetValue("group_id")
+	permissionsChanged := permissionsSet && fmt.Sprint(permissions) != stored.GetValue("permissions")
+	if permissionsChanged {
+		if err := ValidatePermissions(changed.GetValue("permissions")); err != nil {
+			return err
+		}
+	}
+	if !ownerChanged && !groupChanged && !permissionsChanged {
+		return nil
+	}
+	if dbctx.IsAdmin() {
+		return nil
+	}
+	if !dbctx.IsUser(stored.GetValue("owner")) {
+		return fmt.Errorf("%w: only the owner can change owner, group and permissions of %s", ErrPermissionDenied, changed.GetValue("id"))
+	}
+	if groupChanged && !dbctx.IsInGroup(changed.GetValue("group_id")) {
+		return fmt.Errorf("%w: not a member of the group %s", ErrPermissionDenied, changed.GetValue("group_id"))
+	}
+	return nil
+}
+
+/*
+Sets the values not given by the caller before inserting:
+id, owner, group (the primary group of the user) and permissions.
+Owner and group given by the caller are checked, see checkNewOwnership.
+*/
+func (dbObject *DBObject) SetDefaultValues(dbr *DBRepository, tx *sql.Tx) error {
+	dbctx := dbr.DbContext
+	if err := checkNewOwnership(dbctx, dbObject.GetValue("owner"), dbObject.GetValue("group_id")); err != nil {
+		return err
+	}
+	if dbObject.GetValue("id") == "" {
+		objectID, err := UUID16Hex()
+		if err != nil {
+			return err
+		}
+		dbObject.SetValue("id", objectID)
+	}
+	if dbObject.GetValue("owner") == "" {
+		dbObject.SetValue("owner", dbctx.UserID)
+	}
+	if dbObject.GetValue("group_id") == "" {
+		user := NewDBUser()
+		var groupID string
+		err := tx.QueryRowContext(dbr.Context(), "SELECT group_id FROM "+dbr.buildTableName(user)+" WHERE id = ?", dbctx.UserID).Scan(&groupID)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if groupID == "" && len(dbctx.GroupIDs) > 0 {
+			groupID = dbctx.GroupIDs[0]
+		}
+		dbObject.SetValue("group_id", groupID)
+	}
+	if dbObject.GetValue("permissions") == "" {
+		dbObject.SetValue("permissions", DefaultPermissions)
+	}
+	return ValidatePermissions(dbObject.GetValue("permissions"))
+}
+
+/*
+Loads owner, group and permissions currently stored in the db for this object
+*/
+func (dbObject *DBObject) loadStoredPermissions(dbr *DBRepository, tx *sql.Tx) (*DBObject, error) {
+	var owner, groupID, permissions string
+	err := tx.QueryRowContext(dbr.Context(),
+		"SELECT owner, group_id, permissions FROM "+dbr.buildTableName(dbObject)+" WHERE id = ?",
+		dbObject.GetValue("id"),
+	).Scan(&owner, &groupID, &permissions)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%s %s not found", dbObject.GetTypeName(), dbObject.GetValue("id"))
+	}
+	if err != nil {
+		return nil, err
+	}
+	stored := NewDBObject()
+	stored.SetValue("owner", owner)
+	stored.SetValue("group_id", groupID)
+	stored.SetValue("permissions", permissions)
+	return stored, nil
+}
+
+func (dbObject *DBObject) checkContext(dbr *DBRepository) error {
+	if dbr.DbContext == nil || dbr.DbContext.UserID == "" {
+		return fmt.Errorf("%w: %s requires an authenticated user", ErrAuthenticationRequired, dbObject.GetTypeName())
+	}
+	return nil
+}
+
+func (dbObject *DBObject) BeforeInsert(dbr *DBRepository, tx *sql.Tx) error {
+	if err := dbObject.checkContext(dbr); err != nil {
+		return err
+	}
+	if err := dbObject.SetDefaultValues(dbr, tx); err != nil {
+		return err
+	}
+	now := time.Now()
+	dbObject.SetValue("creator", dbr.DbContext.UserID)
+	dbObject.SetTime("creation_date", now)
+	dbObject.SetValue("last_modify", dbr.DbContext.UserID)
+	dbObject.SetTime("last_modify_date", now)
+	return nil
+}
+
+/*
+Checks the user can write the object as it is currently stored in the db,
+and can make the changes of owner, group and permissions (see checkOwnershipChange)
+*/
+func (dbObject *DBObject) CheckWritePermission(dbr *DBRepository, tx *sql.Tx) error {
+	if err := dbObject.checkContext(dbr); err != nil {
+		return err
+	}
+	stored, err := dbObject.loadStoredPermissions(dbr, tx)
+	if err != nil {
+		return err
+	}
+	if !stored.CanWrite(dbr.DbContext) {
+		return fmt.Errorf("%w: cannot write %s %s", ErrPermissionDenied, dbObject.GetTypeName(), dbObject.GetValue("id"))
+	}
+	return checkOwnershipChange(dbr.DbContext, stored, dbObject)
+}
+
+func (dbObject *DBObject) BeforeUpdate(dbr *DBRepository, tx *sql.Tx) error {
+	if err := dbObject.CheckWritePermission(dbr, tx); err != nil {
+		return err
+	}
+	// Creation info cannot be changed
+	delete(dbObject.dictionary, "creator")
+	delete(dbObject.dictionary, "creation_date")
+	dbObject.SetValue("last_modify", dbr.DbContext.UserID)
+	dbObject.SetTime("last_modify_date", time.Now())
+	return nil
+}
+
+func (dbObject *DBObject) BeforeDelete(dbr *DBRepository, tx *sql.Tx) error {
+	return dbObject.CheckWritePermission(dbr, tx)
+}
+
+/*
+The search index is updated in the transaction of the write: see dbsearch.go.
+The subclasses overriding these hooks must call them.
+*/
+func (dbObject *DBObject) AfterInsert(dbr *DBRepository, tx *sql.Tx) error {
+	return dbr.updateSearchIndex(tx, dbObject)
+}
+
+func (dbObject *DBObject) AfterUpdate(dbr *DBRepository, tx *sql.Tx) error {
+	return dbr.updateSearchIndex(tx, dbObject)
+}
+
+func (dbObject *DBObject) AfterDelete(dbr *DBRepository, tx *sql.Tx) error {
+	return dbr.updateSearchIndex(tx, dbObject)
+}
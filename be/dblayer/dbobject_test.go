@@ -0,0 +1,148 @@
+package dblayer
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDBObjectPermissions(t *testing.T) {
+	owner := &DBContext{UserID: "u1", GroupIDs: []string{"g1"}}
+	member := &DBContext{UserID: "u2", GroupIDs: []string{"g2", "g1"}}
+	other := &DBContext{UserID: "u3", GroupIDs: []string{"g3"}}
+
+	obj := NewDBObject()
+	obj.SetValue("owner", "u1")
+	obj.SetValue("group_id", "g1")
+
+	obj.SetValue("permissions", "rwxr-----")
+	if !obj.CanRead(owner) || !obj.CanWrite(owner) || !obj.CanExecute(owner) {
+		t.Error("owner must have full access on rwxr-----")
+	}
+	if !obj.CanRead(member) || obj.CanWrite(member) {
+		t.Error("group member must only read on rwxr-----")
+	}
+	if obj.CanRead(other) {
+		t.Error("others must not read on rwxr-----")
+	}
+
+	obj.SetValue("permissions", "rw-rw-r--")
+	if !obj.CanRead(other) || obj.CanWrite(other) {
+		t.Error("others must only read on rw-rw-r--")
+	}
+	if !obj.CanRead(nil) {
+		t.Error("anonymous must read on rw-rw-r--")
+	}
+
+	// Undefined permissions are DefaultPermissions: only the owner
+	obj.SetValue("permissions", "")
+	if !obj.CanRead(owner) || !obj.CanWrite(owner) || !obj.CanExecute(owner) {
+		t.Error("owner must have full access with undefined permissions")
+	}
+	if obj.CanRead(member) || obj.CanWrite(member) || obj.CanRead(other) || obj.CanRead(nil) {
+		t.Error("undefined permissions must not grant access to group, others and anonymous")
+	}
+
+	obj.SetValue("permissions", "rwxrwxrwxrwx")
+	if obj.CanRead(owner) || obj.CanRead(other) {
+		t.Error("malformed permissions must grant nothing")
+	}
+}
+
+func TestOwnershipChanges(t *testing.T) {
+	owner := &DBContext{UserID: "u1", GroupIDs: []string{"g1", "g4"}}
+	member := &DBContext{UserID: "u2", GroupIDs: []string{"g1"}}
+	admin := &DBContext{UserID: "-1", GroupIDs: []string{AdminGroupID}}
+
+	// New objects: owner and group of the caller, or of anybody for the admins
+	if err := checkNewOwnership(member, "u2", "g1"); err != nil {
+		t.Error("own objects:", err)
+	}
+	if err := checkNewOwnership(member, "", ""); err != nil {
+		t.Error("defaults:", err)
+	}
+	if err := checkNewOwnership(member, "u1", ""); !errors.Is(err, ErrPermissionDenied) {
+		t.Error("objects owned by another user: expected ErrPermissionDenied, got", err)
+	}
+	if err := checkNewOwnership(member, "u2", "g3"); !errors.Is(err, ErrPermissionDenied) {
+		t.Error("objects of a foreign group: expected ErrPermissionDenied, got", err)
+	}
+	if err := checkNewOwnership(admin, "u1", "g3"); err != nil {
+		t.Error("admin:", err)
+	}
+
+	stored := NewDBObject()
+	stored.SetValue("id", "o1")
+	stored.SetValue("owner", "u1")
+	stored.SetValue("group_id", "g1")
+	stored.SetValue("permissions", "rwxrw----")
+	change := func(column string, value string) *DBObject {
+		changed := NewDBObject()
+		changed.SetValue("id", "o1")
+		changed.SetValue("name", "renamed")
+		if column != "" {
+			changed.SetValue(column, value)
+		}
+		return changed
+	}
+
+	cases := []struct {
+		name      string
+		dbContext *DBContext
+		changed   *DBObject
+		denied    bool
+	}{
+		{"member, other columns", member, change("", ""), false},
+		{"member, same permissions", member, change("permissions", "rwxrw----"), false},
+		{"member, permissions", member, change("permissions", "rwxrwxrwx"), true},
+		{"member, owner", member, change("owner", "u2"), true},
+		{"member, group", member, change("group_id", "g1x"), true},
+		{"owner, permissions", owner, change("permissions", "rwx------"), false},
+		{"owner, owner", owner, change("owner", "u2"), false},
+		{"owner, own group", owner, change("group_id", "g4"), false},
+		{"owner, foreign group", owner, change("group_id", "g3"), true},
+		{"admin, owner", admin, change("owner", "u2"), false},
+		{"admin, group", admin, change("group_id", "g3"), false},
+	}
+	for _, c := range cases {
+		err := checkOwnershipChange(c.dbContext, stored, c.changed)
+		if c.denied && !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("%s: expected ErrPermissionDenied, got %v", c.name, err)
+		}
+		if !c.denied && err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		}
+	}
+
+	var validationError *ValidationError
+	if err := checkOwnershipChange(owner, stored, change("permissions", "rwxrwxrwX")); !errors.As(err, &validationError) {
+		t.Error("malformed permissions: expected a ValidationError, got", err)
+	}
+}
+
+func TestValidatePermissions(t *testing.T) {
+	for _, permissions := range []string{"rwx------", "rw-r--r--", "---------", "rwxrwxrwx"} {
+		if err := ValidatePermissions(permissions); err != nil {
+			t.Errorf("%s: %v", permissions, err)
+		}
+	}
+	for _, permissions := range []string{"", "rwx", "rwx------x", "xwr------", "rwxrwxrwX", "777"} {
+		if err := ValidatePermissions(permissions); err == nil {
+			t.Errorf("%s: expected a ValidationError", permissions)
+		}
+	}
+}
+
+func TestDBObjectIsDeleted(t *testing.T) {
+	obj := NewDBObject()
+	if obj.IsDeleted() {
+		t.Error("a new object is not deleted")
+	}
+	obj.SetValue("deleted_date", ZeroDateTime)
+	if obj.IsDeleted() {
+		t.Error("zero deleted_date means not deleted")
+	}
+	obj.SetValue("deleted_date", "2025-01-01 10:00:00")
+	if !obj.IsDeleted() {
+		t.Error("object with deleted_date must be deleted")
+	}
+}
@@ -0,0 +1,205 @@
+package dblayer
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+/*
+Search of class DBObject itself.
+
+For each registered subclass of DBObject it selects the common columns only,
+plus the type name in the column "classname", and returns the union of the results.
+The results are lightweight DBObjects: use FullObject to drill down.
+Only the objects the user can read are returned.
+The filters can use the common columns only.
+*/
+func (dbr *DBRepository) SearchObjects(criteria *DBObject, useLike bool, caseSensitive bool, orderBy string, filters ...Filter) ([]*DBObject, error) {
+	if dbr.Verbose {
+		log.Print("DBRepository::SearchObjects: criteria=", criteria)
+	}
+	deletedClause := ""
+	if !dbr.IncludeDeleted {
+		deletedClause = buildDeletedClause(false)
+	}
+	return dbr.searchObjects(criteria, useLike, caseSensitive, orderBy, deletedClause, filters)
+}
+
+/*
+Returns the soft deleted DBObjects the user can read, the most recently deleted first
+*/
+func (dbr *DBRepository) SearchTrash(criteria *DBObject) ([]*DBObject, error) {
+	if dbr.Verbose {
+		log.Print("DBRepository::SearchTrash: criteria=", criteria)
+	}
+	return dbr.searchObjects(criteria, true, false, "-deleted_date", buildDeletedClause(true), nil)
+}
+
+/*
+Like SearchObjects, but returns a page of the results with the total count.
+orderBy is a sort spec of common columns (ie. "name,-creation_date"), name by default.
+*/
+func (dbr *DBRepository) SearchObjectsPage(criteria *DBObject, useLike bool, caseSensitive bool, orderBy string, page PageRequest, filters ...Filter) (*SearchPage, error) {
+	deletedClause := ""
+	if !dbr.IncludeDeleted {
+		deletedClause = buildDeletedClause(false)
+	}
+	return dbr.searchObjectsPage(criteria, useLike, caseSensitive, orderBy, page, deletedClause, filters)
+}
+
+/*
+Like SearchTrash, but returns a page of the results with the total count
+*/
+func (dbr *DBRepository) SearchTrashPage(criteria *DBObject, page PageRequest) (*SearchPage, error) {
+	return dbr.searchObjectsPage(criteria, true, false, "-deleted_date", page, buildDeletedClause(true), nil)
+}
+
+/*
+Parses the sort spec of the search of DBObjects: the common columns and classname are accepted
+*/
+func parseObjectsOrderBy(criteria *DBObject, orderBy string) ([]OrderTerm, error) {
+	if orderBy == "" {
+		orderBy = "name"
+	}
+	terms, err := ParseOrderBy(orderBy)
+	if err != nil {
+		return nil, err
+	}
+	for _, term := range terms {
+		if term.Column != "classname" && !criteria.HasColumn(term.Column) {
+			return nil, fmt.Errorf("%w: unknown column '%s'", ErrInvalidOrderBy, term.Column)
+		}
+	}
+	return terms, nil
+}
+
+func (dbr *DBRepository) searchObjectsPage(criteria *DBObject, useLike bool, caseSensitive bool, orderBy string, page PageRequest, deletedClause string, filters []Filter) (*SearchPage, error) {
+	terms, err := parseObjectsOrderBy(criteria, orderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := dbr.buildObjectsQuery(criteria, useLike, caseSensitive, deletedClause, filters)
+	if err != nil {
+		return nil, err
+	}
+	if query == "" {
+		return &SearchPage{Items: []DBEntityInterface{}}, nil
+	}
+	return dbr.queryPage(query, args, StableOrder(terms, []string{"id"}), page, func() DBEntityInterface { return NewDBObject() })
+}
+
+/*
+Builds the UNION ALL of the common columns of all the DBObject classes.
+Returns "" if there are no classes.
+*/
+func (dbr *DBRepository) buildObjectsQuery(criteria *DBObject, useLike bool, caseSensitive bool, deletedClause string, filters []Filter) (string, []interface{}, error) {
+	classNames := dbr.factory.GetAllDBObjectClassNames()
+	if len(classNames) == 0 {
+		return "", nil, nil
+	}
+
+	commonColumns := make([]string, 0)
+	for _, col := range dbObjectColumns() {
+		commonColumns = append(commonColumns, col.Name)
+	}
+
+	whereClauses, whereArgs := dbr.buildWhere(criteria, useLike, caseSensitive)
+	filterClauses, filterArgs, err := buildFilters(criteria, filters)
+	if err != nil {
+		return "", nil, err
+	}
+	whereClauses = append(whereClauses, filterClauses...)
+	whereArgs = append(whereArgs, filterArgs...)
+	permClause, permArgs := dbr.buildReadPermissionClause()
+	whereClauses = append(whereClauses, permClause)
+	if deletedClause != "" {
+		whereClauses = append(whereClauses, deletedClause)
+	}
+	where := strings.Join(whereClauses, " AND ")
+
+	selects := make([]string, 0, len(classNames))
+	args := make([]interface{}, 0)
+	for _, className := range classNames {
+		dbe := dbr.factory.GetInstanceByClassName(className)
+		selects = append(selects,
+			"SELECT '"+className+"' AS classname, "+strings.Join(commonColumns, ", ")+
+				" FROM "+dbr.buildTableName(dbe)+" WHERE "+where)
+		args = append(args, whereArgs...)
+		args = append(args, permArgs...)
+	}
+	return strings.Join(selects, " UNION ALL "), args, nil
+}
+
+func (dbr *DBRepository) searchObjects(criteria *DBObject, useLike bool, caseSensitive bool, orderBy string, deletedClause string, filters []Filter) ([]*DBObject, error) {
+	terms, err := parseObjectsOrderBy(criteria, orderBy)
+	if err != nil {
+		return nil, err
+	}
+	union, args, err := dbr.buildObjectsQuery(criteria, useLike, caseSensitive, deletedClause, filters)
+	if err != nil {
+		return nil, err
+	}
+	if union == "" {
+		return []*DBObject{}, nil
+	}
+
+	query := "SELECT * FROM (" + union + ") AS objects ORDER BY " + orderByClause(terms)
+
+	if dbr.Verbose {
+		log.Print("DBRepository::searchObjects: query=", query, " args=", args)
+	}
+
+	rows, err := dbr.DbConnection.QueryContext(dbr.Context(), query, args...)
+	if err != nil {
+		log.Print("DBRepository::searchObjects: Query error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	entities, err := dbr.scanRows(rows, func() DBEntityInterface { return NewDBObject() })
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*DBObject, 0, len(entities))
+	for _, dbe := range entities {
+		results = append(results, dbe.(*DBObject))
+	}
+
+	if dbr.Verbose {
+		log.Printf("DBRepository::searchObjects: found %d results", len(results))
+	}
+	return results, nil
+}
+
+/*
+Returns the instance of the concrete class (ie. DBPerson) with all the fields populated.
+Returns nil if the object does not exist or the user cannot read it.
+*/
+func (dbr *DBRepository) FullObject(id string) (DBObjectInterface, error) {
+	criteria := NewDBObject()
+	criteria.SetValue("id", id)
+	objects, err := dbr.SearchObjects(criteria, false, false, "")
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) == 0 {
+		return nil, nil
+	}
+
+	className := objects[0].GetValue("classname")
+	dbe := dbr.factory.GetInstanceByClassName(className)
+	if dbe == nil {
+		return nil, fmt.Errorf("class %s not registered", className)
+	}
+	dbe.SetValue("id", id)
+	results, err := dbr.Search(dbe, false, false, "")
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	return results[0].(DBObjectInterface), nil
+}
@@ -0,0 +1,344 @@
+package dblayer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+var DefaultPageSize = 50
+var MaxPageSize = 500
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+var ErrInvalidOrderBy = errors.New("invalid order by")
+
+/*
+The page to read: Page is 1-based.
+With After (the NextCursor of the previous page) Page is ignored and
+the rows following the cursor are returned: stable also if rows are inserted meanwhile.
+*/
+type PageRequest struct {
+	Page     int
+	PageSize int
+	After    string
+}
+
+/*
+Returns the request with the defaults applied and the page size limited to MaxPageSize
+*/
+func (page PageRequest) Normalize() PageRequest {
+	if page.PageSize <= 0 {
+		page.PageSize = DefaultPageSize
+	}
+	if page.PageSize > MaxPageSize {
+		page.PageSize = MaxPageSize
+	}
+	if page.Page < 1 || page.After != "" {
+		page.Page = 1
+	}
+	return page
+}
+
+/*
+A page of the results of a search: Total is the number of rows matching the search,
+NextCursor is "" on the last page.
+*/
+type SearchPage struct {
+	Items      []DBEntityInterface
+	Total      int
+	NextCursor string
+}
+
+type OrderTerm struct {
+	Column string
+	Desc   bool
+}
+
+var identifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+/*
+Quotes a column or table name for MySQL
+*/
+func QuoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+/*
+Parses a sort spec in the form "name,-creation_date": a leading '-' means descending.
+The SQL form "name, creation_date DESC" is accepted too.
+Only column names are accepted: no expressions.
+*/
+func ParseOrderBy(orderBy string) ([]OrderTerm, error) {
+	terms := make([]OrderTerm, 0)
+	if strings.TrimSpace(orderBy) == "" {
+		return terms, nil
+	}
+	for _, part := range strings.Split(orderBy, ",") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 || len(fields) > 2 {
+			return nil, fmt.Errorf("%w: '%s'", ErrInvalidOrderBy, strings.TrimSpace(part))
+		}
+		term := OrderTerm{Column: fields[0]}
+		if len(fields) == 1 && len(term.Column) > 1 && (term.Column[0] == '-' || term.Column[0] == '+') {
+			term.Desc = term.Column[0] == '-'
+			term.Column = term.Column[1:]
+		}
+		if !identifierRegexp.MatchString(term.Column) {
+			return nil, fmt.Errorf("%w: '%s'", ErrInvalidOrderBy, strings.TrimSpace(part))
+		}
+		if len(fields) == 2 {
+			switch strings.ToUpper(fields[1]) {
+			case "ASC":
+			case "DESC":
+				term.Desc = true
+			default:
+				return nil, fmt.Errorf("%w: '%s'", ErrInvalidOrderBy, strings.TrimSpace(part))
+			}
+		}
+		terms = append(terms, term)
+	}
+	return terms, nil
+}
+
+/*
+Parses orderBy (see ParseOrderBy) accepting only the columns of the entity
+*/
+func ParseOrderByFor(dbe DBEntityInterface, orderBy string) ([]OrderTerm, error) {
+	terms, err := ParseOrderBy(orderBy)
+	if err != nil {
+		return nil, err
+	}
+	for _, term := range terms {
+		if !dbe.HasColumn(term.Column) {
+			return nil, fmt.Errorf("%w: unknown column '%s'", ErrInvalidOrderBy, term.Column)
+		}
+	}
+	return terms, nil
+}
+
+/*
+Appends the keys not already in the order: rows with the same values keep a stable order
+*/
+func StableOrder(terms []OrderTerm, keys []string) []OrderTerm {
+	ret := append([]OrderTerm{}, terms...)
+	for _, key := range keys {
+		found := false
+		for _, term := range terms {
+			if term.Column == key {
+				found = true
+				break
+			}
+		}
+		if !found {
+			ret = append(ret, OrderTerm{Column: key})
+		}
+	}
+	return ret
+}
+
+func orderByClause(terms []OrderTerm) string {
+	parts := make([]string, 0, len(terms))
+	for _, term := range terms {
+		if term.Desc {
+			parts = append(parts, QuoteIdentifier(term.Column)+" DESC")
+		} else {
+			parts = append(parts, QuoteIdentifier(term.Column)+" ASC")
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+/*
+Returns the sort spec of the order, ie. "name,-id"
+*/
+func OrderSpec(terms []OrderTerm) string {
+	parts := make([]string, 0, len(terms))
+	for _, term := range terms {
+		if term.Desc {
+			parts = append(parts, "-"+term.Column)
+		} else {
+			parts = append(parts, term.Column)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+/*
+The content of a cursor: the order of the page and the values of its columns in the last row,
+null for the NULL values
+*/
+type pageCursor struct {
+	Order  string    `json:"o"`
+	Values []*string `json:"v"`
+}
+
+/*
+The cursor is the order and the values of the order columns of the last row, in base64 JSON.
+valueOf returns the value of a column and false if it is NULL.
+*/
+func EncodeCursor(terms []OrderTerm, valueOf func(column string) (string, bool)) string {
+	cursor := pageCursor{Order: OrderSpec(terms), Values: make([]*string, 0, len(terms))}
+	for _, term := range terms {
+		if value, valid := valueOf(term.Column); valid {
+			cursor.Values = append(cursor.Values, &value)
+		} else {
+			cursor.Values = append(cursor.Values, nil)
+		}
+	}
+	b, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+/*
+Returns the values of the cursor: the cursor of a page with a different order is rejected
+*/
+func decodeCursor(after string, terms []OrderTerm) ([]*string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(after)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor pageCursor
+	if err := json.Unmarshal(b, &cursor); err != nil || len(cursor.Values) != len(terms) {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.Order != OrderSpec(terms) {
+		return nil, fmt.Errorf("%w: the cursor is of the order '%s', not '%s'", ErrInvalidCursor, cursor.Order, OrderSpec(terms))
+	}
+	return cursor.Values, nil
+}
+
+func cursorArg(value *string) interface{} {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
+
+/*
+Builds the condition selecting the rows after the cursor values, ie. for "a, b DESC":
+
+	(a > ?) OR (a <=> ? AND (b < ? OR b IS NULL))
+
+NULL-safe: MySQL sorts the NULLs first in ASC and last in DESC order,
+so after a NULL there are the not NULL values (ASC) or nothing else (DESC).
+*/
+func keysetClause(terms []OrderTerm, values []*string) (string, []interface{}) {
+	clauses := make([]string, 0, len(terms))
+	args := make([]interface{}, 0)
+	for i, term := range terms {
+		parts := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			parts = append(parts, QuoteIdentifier(terms[j].Column)+" <=> ?")
+			args = append(args, cursorArg(values[j]))
+		}
+		column := QuoteIdentifier(term.Column)
+		switch {
+		case values[i] == nil && term.Desc:
+			// Nothing after the NULLs
+			continue
+		case values[i] == nil:
+			parts = append(parts, column+" IS NOT NULL")
+		case term.Desc:
+			parts = append(parts, "("+column+" < ? OR "+column+" IS NULL)")
+			args = append(args, *values[i])
+		default:
+			parts = append(parts, column+" > ?")
+			args = append(args, *values[i])
+		}
+		clauses = append(clauses, "("+strings.Join(parts, " AND ")+")")
+	}
+	if len(clauses) == 0 {
+		return "(1 = 0)", args
+	}
+	return "(" + strings.Join(clauses, " OR ") + ")", args
+}
+
+/*
+The queries to read a page of the results of a base query
+*/
+type PagedQuery struct {
+	CountQuery string
+	CountArgs  []interface{}
+	Query      string // returns up to Limit+1 rows: the extra one tells that there is a next page
+	Args       []interface{}
+	Limit      int
+	Order      []OrderTerm
+}
+
+/*
+Wraps the base query (a SELECT with its WHERE) to count its rows and to read a page of them,
+in the given order. The order must be stable, see StableOrder.
+*/
+func BuildPagedQuery(baseQuery string, args []interface{}, order []OrderTerm, page PageRequest) (*PagedQuery, error) {
+	page = page.Normalize()
+	if len(order) == 0 {
+		return nil, fmt.Errorf("%w: the paged queries need an order", ErrInvalidOrderBy)
+	}
+
+	ret := &PagedQuery{
+		CountQuery: "SELECT COUNT(*) FROM (" + baseQuery + ") AS page_rows",
+		CountArgs:  args,
+		Limit:      page.PageSize,
+		Order:      order,
+	}
+	query := "SELECT * FROM (" + baseQuery + ") AS page_rows"
+	queryArgs := append([]interface{}{}, args...)
+	if page.After != "" {
+		values, err := decodeCursor(page.After, order)
+		if err != nil {
+			return nil, err
+		}
+		clause, clauseArgs := keysetClause(order, values)
+		query += " WHERE " + clause
+		queryArgs = append(queryArgs, clauseArgs...)
+	}
+	query += " ORDER BY " + orderByClause(order) + " LIMIT ? OFFSET ?"
+	queryArgs = append(queryArgs, page.PageSize+1, (page.Page-1)*page.PageSize)
+	ret.Query = query
+	ret.Args = queryArgs
+	return ret, nil
+}
+
+/*
+Reads a page of the rows of the base query as instances of newInstance
+*/
+func (dbr *DBRepository) queryPage(baseQuery string, args []interface{}, order []OrderTerm, page PageRequest, newInstance func() DBEntityInterface) (*SearchPage, error) {
+	paged, err := BuildPagedQuery(baseQuery, args, order, page)
+	if err != nil {
+		return nil, err
+	}
+	if dbr.Verbose {
+		log.Print("DBRepository::queryPage: query=", paged.Query, " args=", paged.Args)
+	}
+
+	ret := &SearchPage{}
+	if err := dbr.DbConnection.QueryRowContext(dbr.Context(), paged.CountQuery, paged.CountArgs...).Scan(&ret.Total); err != nil {
+		log.Print("DBRepository::queryPage: Count error:", err)
+		return nil, err
+	}
+
+	rows, err := dbr.DbConnection.QueryContext(dbr.Context(), paged.Query, paged.Args...)
+	if err != nil {
+		log.Print("DBRepository::queryPage: Query error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	items, err := dbr.scanRows(rows, newInstance)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(items) > paged.Limit {
+		items = items[:paged.Limit]
+		last := items[len(items)-1]
+		ret.NextCursor = EncodeCursor(order, func(column string) (string, bool) {
+			return last.GetValue(column), !last.IsNull(column)
+		})
+	}
+	ret.Items = items
+	return ret, nil
+}
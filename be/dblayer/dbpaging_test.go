@@ -0,0 +1,138 @@
+package dblayer
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseOrderBy(t *testing.T) {
+	expected := []OrderTerm{{"name", false}, {"creation_date", true}, {"id", false}}
+	for _, orderBy := range []string{"name,-creation_date,+id", "name, creation_date DESC,id asc"} {
+		terms, err := ParseOrderBy(orderBy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(terms, expected) {
+			t.Errorf("%q: got %v", orderBy, terms)
+		}
+	}
+
+	for _, orderBy := range []string{"name; DROP TABLE x", "name DESC extra", "1", "name,", "name sideways", "-", "--name", "-name DESC", "`name`"} {
+		if _, err := ParseOrderBy(orderBy); !errors.Is(err, ErrInvalidOrderBy) {
+			t.Errorf("%q: expected ErrInvalidOrderBy, got %v", orderBy, err)
+		}
+	}
+}
+
+func TestParseOrderByFor(t *testing.T) {
+	user := NewDBUser()
+	if _, err := ParseOrderByFor(user, "login,-fullname"); err != nil {
+		t.Error(err)
+	}
+	if _, err := ParseOrderByFor(user, "login,-nonexistent"); !errors.Is(err, ErrInvalidOrderBy) {
+		t.Errorf("expected ErrInvalidOrderBy, got %v", err)
+	}
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	if got := QuoteIdentifier("na`me"); got != "`na``me`" {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestStableOrder(t *testing.T) {
+	terms := StableOrder([]OrderTerm{{"name", false}, {"id", true}}, []string{"id", "code"})
+	expected := []OrderTerm{{"name", false}, {"id", true}, {"code", false}}
+	if !reflect.DeepEqual(terms, expected) {
+		t.Errorf("got %v", terms)
+	}
+}
+
+func TestPageRequestNormalize(t *testing.T) {
+	page := PageRequest{Page: 0, PageSize: MaxPageSize + 1}.Normalize()
+	if page.Page != 1 || page.PageSize != MaxPageSize {
+		t.Errorf("got %+v", page)
+	}
+	page = PageRequest{Page: 3, After: "x"}.Normalize()
+	if page.Page != 1 || page.PageSize != DefaultPageSize {
+		t.Errorf("the cursor must ignore the page: got %+v", page)
+	}
+}
+
+func TestBuildPagedQuery(t *testing.T) {
+	order := []OrderTerm{{"name", false}, {"id", true}}
+	paged, err := BuildPagedQuery("SELECT * FROM t WHERE a = ?", []interface{}{"x"}, order, PageRequest{Page: 3, PageSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paged.CountQuery != "SELECT COUNT(*) FROM (SELECT * FROM t WHERE a = ?) AS page_rows" {
+		t.Errorf("CountQuery: %s", paged.CountQuery)
+	}
+	if paged.Query != "SELECT * FROM (SELECT * FROM t WHERE a = ?) AS page_rows ORDER BY `name` ASC, `id` DESC LIMIT ? OFFSET ?" {
+		t.Errorf("Query: %s", paged.Query)
+	}
+	if !reflect.DeepEqual(paged.Args, []interface{}{"x", 11, 20}) {
+		t.Errorf("Args: %v", paged.Args)
+	}
+
+	cursor := EncodeCursor(order, func(column string) (string, bool) {
+		return map[string]string{"name": "Bob", "id": "42"}[column], true
+	})
+	paged, err = BuildPagedQuery("SELECT * FROM t", nil, order, PageRequest{Page: 3, PageSize: 10, After: cursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paged.Query != "SELECT * FROM (SELECT * FROM t) AS page_rows WHERE ((`name` > ?) OR (`name` <=> ? AND (`id` < ? OR `id` IS NULL))) ORDER BY `name` ASC, `id` DESC LIMIT ? OFFSET ?" {
+		t.Errorf("Query: %s", paged.Query)
+	}
+	if !reflect.DeepEqual(paged.Args, []interface{}{"Bob", "Bob", "42", 11, 0}) {
+		t.Errorf("Args: %v", paged.Args)
+	}
+
+	if _, err := BuildPagedQuery("SELECT * FROM t", nil, order, PageRequest{After: "garbage!"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("expected ErrInvalidCursor, got %v", err)
+	}
+	if _, err := BuildPagedQuery("SELECT * FROM t", nil, nil, PageRequest{}); err == nil {
+		t.Error("expected an error without order")
+	}
+	// The cursor of another order_by
+	other := []OrderTerm{{"name", true}, {"id", true}}
+	if _, err := BuildPagedQuery("SELECT * FROM t", nil, other, PageRequest{After: cursor}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("expected ErrInvalidCursor for a different order, got %v", err)
+	}
+}
+
+func TestKeysetClauseNulls(t *testing.T) {
+	null := func(column string) (string, bool) {
+		if column == "id" {
+			return "42", true
+		}
+		return "", false
+	}
+	cases := []struct {
+		order    []OrderTerm
+		expected string
+		args     []interface{}
+	}{
+		// ASC: the NULLs come first, after them the not NULL values
+		{[]OrderTerm{{"start_date", false}, {"id", false}},
+			"((`start_date` IS NOT NULL) OR (`start_date` <=> ? AND `id` > ?))", []interface{}{nil, "42"}},
+		// DESC: the NULLs come last, after them only the other NULLs
+		{[]OrderTerm{{"start_date", true}, {"id", false}},
+			"((`start_date` <=> ? AND `id` > ?))", []interface{}{nil, "42"}},
+	}
+	for _, c := range cases {
+		values, err := decodeCursor(EncodeCursor(c.order, null), c.order)
+		if err != nil {
+			t.Fatal(err)
+		}
+		clause, args := keysetClause(c.order, values)
+		if clause != c.expected {
+			t.Errorf("%s: got %s", OrderSpec(c.order), clause)
+		}
+		if !reflect.DeepEqual(args, c.args) {
+			t.Errorf("%s: got args %v", OrderSpec(c.order), args)
+		}
+	}
+}
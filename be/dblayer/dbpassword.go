@@ -0,0 +1,88 @@
+package dblayer
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+/*
+Passwords are stored as bcrypt(pwd_salt + password) in pwd, with a random
+pwd_salt of 4 chars (the size of the column).
+
+An empty pwd_salt marks a legacy row with the password in plain text:
+it is still accepted at login, and must be rehashed.
+*/
+const PasswordSaltLength = 4
+
+var PasswordCost = bcrypt.DefaultCost
+
+func newPasswordSalt() (string, error) {
+	b := make([]byte, PasswordSaltLength*3/4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+/*
+Returns the hash and the salt to store in pwd and pwd_salt
+*/
+func HashPassword(password string) (hash string, salt string, err error) {
+	salt, err = newPasswordSalt()
+	if err != nil {
+		return "", "", err
+	}
+	b, err := bcrypt.GenerateFromPassword([]byte(salt+password), PasswordCost)
+	if err != nil {
+		return "", "", err
+	}
+	return string(b), salt, nil
+}
+
+/*
+Checks the password against the stored pwd and pwd_salt.
+needsRehash is true if the password is right but stored in plain text
+or with an obsolete cost.
+*/
+func VerifyPassword(password string, hash string, salt string) (ok bool, needsRehash bool) {
+	if salt == "" {
+		ok = hash != "" && subtle.ConstantTimeCompare([]byte(hash), []byte(password)) == 1
+		return ok, ok
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(salt+password)) != nil {
+		return false, false
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return true, err != nil || cost < PasswordCost
+}
+
+/*
+Sets pwd and pwd_salt from the password in clear
+*/
+func (dbUser *DBUser) SetPassword(password string) error {
+	hash, salt, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	dbUser.SetValue("pwd", hash)
+	dbUser.SetValue("pwd_salt", salt)
+	return nil
+}
+
+func (dbUser *DBUser) CheckPassword(password string) (ok bool, needsRehash bool) {
+	return VerifyPassword(password, dbUser.GetValue("pwd"), dbUser.GetValue("pwd_salt"))
+}
+
+/*
+A password set without salt is in clear: hash it before writing.
+To change the password of a user read from the db use SetPassword.
+*/
+func (dbUser *DBUser) hashClearPassword() error {
+	if dbUser.GetValue("pwd") == "" || dbUser.GetValue("pwd_salt") != "" {
+		return nil
+	}
+	return dbUser.SetPassword(dbUser.GetValue("pwd"))
+}
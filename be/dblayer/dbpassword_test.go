@@ -0,0 +1,65 @@
+package dblayer
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHashAndVerifyPassword(t *testing.T) {
+	hash, salt, err := HashPassword("mysecretpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(salt) != PasswordSaltLength {
+		t.Errorf("salt must be %d chars, got '%s'", PasswordSaltLength, salt)
+	}
+	if hash == "mysecretpass" || len(hash) > 255 {
+		t.Errorf("unexpected hash '%s'", hash)
+	}
+	if ok, needsRehash := VerifyPassword("mysecretpass", hash, salt); !ok || needsRehash {
+		t.Errorf("expected ok without rehash, got ok=%v needsRehash=%v", ok, needsRehash)
+	}
+	if ok, _ := VerifyPassword("wrong", hash, salt); ok {
+		t.Error("wrong password accepted")
+	}
+	if ok, _ := VerifyPassword("mysecretpass", hash, "xxxx"); ok {
+		t.Error("password accepted with the wrong salt")
+	}
+}
+
+func TestVerifyLegacyPassword(t *testing.T) {
+	if ok, needsRehash := VerifyPassword("mysecretpass", "mysecretpass", ""); !ok || !needsRehash {
+		t.Errorf("legacy password must be accepted and rehashed, got ok=%v needsRehash=%v", ok, needsRehash)
+	}
+	if ok, _ := VerifyPassword("wrong", "mysecretpass", ""); ok {
+		t.Error("wrong legacy password accepted")
+	}
+	if ok, _ := VerifyPassword("", "", ""); ok {
+		t.Error("empty password accepted")
+	}
+}
+
+func TestVerifyPasswordObsoleteCost(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("abcdpass"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, needsRehash := VerifyPassword("pass", string(hash), "abcd"); !ok || !needsRehash {
+		t.Errorf("expected rehash for obsolete cost, got ok=%v needsRehash=%v", ok, needsRehash)
+	}
+}
+
+func TestDBUserHashClearPassword(t *testing.T) {
+	user := NewDBUser()
+	user.SetValue("pwd", "mysecretpass")
+	if err := user.hashClearPassword(); err != nil {
+		t.Fatal(err)
+	}
+	if user.GetValue("pwd_salt") == "" || user.GetValue("pwd") == "mysecretpass" {
+		t.Error("password not hashed")
+	}
+	if ok, _ := user.CheckPassword("mysecretpass"); !ok {
+		t.Error("hashed password not verified")
+	}
+}
@@ -1,10 +1,12 @@
 package dblayer
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"strings"
+	"time"
 )
 
 type DBContext struct {
@@ -34,13 +36,49 @@ func (dbctx *DBContext) IsUser(userID string) bool {
 	return dbctx.UserID == userID
 }
 
+// True for the administrators, see AdminGroupID
+func (dbctx *DBContext) IsAdmin() bool {
+	return dbctx != nil && dbctx.IsInGroup(AdminGroupID)
+}
+
+// True for the anonymous user (no context or no user): it can only read the public objects
+func (dbctx *DBContext) IsAnonymous() bool {
+	return dbctx == nil || dbctx.UserID == ""
+}
+
+type dbContextKey struct{}
+
+/*
+Returns a copy of ctx carrying the DBContext, ie. of the authenticated user of a request
+*/
+func NewContext(ctx context.Context, dbContext *DBContext) context.Context {
+	return context.WithValue(ctx, dbContextKey{}, dbContext)
+}
+
+/*
+Returns the DBContext carried by ctx, nil if none
+*/
+func FromContext(ctx context.Context) *DBContext {
+	if ctx == nil {
+		return nil
+	}
+	dbContext, _ := ctx.Value(dbContextKey{}).(*DBContext)
+	return dbContext
+}
+
 type DBRepository struct {
 	Verbose   bool
 	DbContext *DBContext
 	factory   *DBEFactory
 
+	/* If true the search returns also the soft deleted DBObjects */
+	IncludeDeleted bool
+
 	/* Can be a connection to mysql, postgresql, sqlite, etc. */
 	DbConnection *sql.DB
+
+	/* Context of the queries, ie. of the http request: see WithContext */
+	ctx context.Context
 }
 
 func NewDBRepository(dbContext *DBContext, factory *DBEFactory, dbConnection *sql.DB) *DBRepository {
@@ -52,75 +90,107 @@ func NewDBRepository(dbContext *DBContext, factory *DBEFactory, dbConnection *sq
 	}
 }
 
-func (dbr *DBRepository) GetInstanceByClassName(classname string) *DBEntity {
+/*
+Sets the context of the queries: when it is canceled (ie. the client disconnects) the queries are interrupted
+*/
+func (dbr *DBRepository) WithContext(ctx context.Context) *DBRepository {
+	dbr.ctx = ctx
+	return dbr
+}
+
+// Returns the context of the queries, context.Background() if not set
+func (dbr *DBRepository) Context() context.Context {
+	if dbr.ctx == nil {
+		return context.Background()
+	}
+	return dbr.ctx
+}
+
+func (dbr *DBRepository) GetInstanceByClassName(classname string) DBEntityInterface {
 	return dbr.factory.GetInstanceByClassName(classname)
 }
-func (dbr *DBRepository) GetInstanceByTableName(tablename string) *DBEntity {
+func (dbr *DBRepository) GetInstanceByTableName(tablename string) DBEntityInterface {
 	return dbr.factory.GetInstanceByTableName(tablename)
 }
 
-func (dbr *DBRepository) buildTableName(dbe *DBEntity) string {
-	if dbr.DbContext != nil && dbr.DbContext.Schema != "" {
-		return dbr.DbContext.Schema + "_" + dbe.GetTableName()
+/*
+Returns the table name with the schema prefix of the context, if any
+*/
+func BuildTableName(dbContext *DBContext, tablename string) string {
+	if dbContext != nil && dbContext.Schema != "" {
+		return dbContext.Schema + "_" + tablename
 	}
-	return dbe.GetTableName()
+	return tablename
 }
 
-func (dbr *DBRepository) Search(dbe *DBEntity, useLike bool, caseSensitive bool, orderBy string) ([]*DBEntity, error) {
-	if dbr.Verbose {
-		log.Print("DBRepository::Search: dbe=", dbe)
-	}
+func (dbr *DBRepository) buildTableName(dbe DBEntityInterface) string {
+	return BuildTableName(dbr.DbContext, dbe.GetTableName())
+}
 
-	// 1. Build WHERE clauses
+/*
+Builds the WHERE clauses from the values set in the entity (columns only):
+  - strings: column LIKE '%value%' (unless useLike is false)
+  - numbers, dates, etc.: column = value
+*/
+func (dbr *DBRepository) buildWhere(dbe DBEntityInterface, useLike bool, caseSensitive bool) ([]string, []interface{}) {
 	whereClauses := make([]string, 0)
 	args := make([]interface{}, 0) // slice of interface{} for values
 
-	for key, value := range dbe.dictionary {
+	for _, key := range dbr.populatedColumns(dbe) {
+		value := dbe.getValueToDB(key)
+		column := QuoteIdentifier(key)
 		if useLike {
 			// For strings: LIKE '%value%'
 			if strings.Contains(dbe.GetColumnType(key), "varchar") || dbe.GetColumnType(key) == "text" {
 				if caseSensitive {
-					whereClauses = append(whereClauses, key+" LIKE ?")
-					args = append(args, "%"+fmt.Sprint(value)+"%")
+					whereClauses = append(whereClauses, column+" LIKE ?")
+					args = append(args, "%"+dbe.GetValue(key)+"%")
 				} else {
-					whereClauses = append(whereClauses, "LOWER("+key+") LIKE LOWER(?)")
-					args = append(args, "%"+fmt.Sprint(value)+"%")
+					whereClauses = append(whereClauses, "LOWER("+column+") LIKE LOWER(?)")
+					args = append(args, "%"+dbe.GetValue(key)+"%")
 				}
 			} else {
 				// Per numeri/date: exact match
-				whereClauses = append(whereClauses, key+" = ?")
+				whereClauses = append(whereClauses, column+" = ?")
 				args = append(args, value)
 			}
 		} else {
 			// Exact match
-			whereClauses = append(whereClauses, key+" = ?")
+			whereClauses = append(whereClauses, column+" = ?")
 			args = append(args, value)
 		}
 	}
+	return whereClauses, args
+}
 
-	// 2. Build the final query
-	query := "SELECT * FROM " + dbr.buildTableName(dbe)
-	if len(whereClauses) > 0 {
-		query += " WHERE " + strings.Join(whereClauses, " AND ")
-	}
-	if orderBy != "" {
-		query += " ORDER BY " + orderBy
+/*
+Builds the clause returning only the DBObjects the current user can read:
+as owner OR as member of the group OR because the object is public.
+The anonymous user reads only the public objects: "all" triplet with read.
+Empty permissions are DefaultPermissions (see checkPermission): readable only by the owner.
+*/
+func (dbr *DBRepository) buildReadPermissionClause() (string, []interface{}) {
+	if dbr.DbContext.IsAnonymous() {
+		return "(permissions LIKE '______r__')", []interface{}{}
 	}
-
-	if dbr.Verbose {
-		log.Print("DBRepository::Search: query=", query, " args=", args)
-	}
-
-	// 3. Execute the query
-	rows, err := dbr.DbConnection.Query(query, args...)
-	if err != nil {
-		log.Print("DBRepository::Search: Query error:", err)
-		return nil, err
+	clauses := []string{"permissions LIKE '______r__'", "(owner = ? AND (permissions = '' OR permissions LIKE 'r________'))"}
+	args := []interface{}{dbr.DbContext.UserID}
+	if len(dbr.DbContext.GroupIDs) > 0 {
+		placeholders := make([]string, 0, len(dbr.DbContext.GroupIDs))
+		for _, groupID := range dbr.DbContext.GroupIDs {
+			placeholders = append(placeholders, "?")
+			args = append(args, groupID)
+		}
+		clauses = append(clauses, "(group_id IN ("+strings.Join(placeholders, ", ")+") AND permissions LIKE '___r_____')")
 	}
-	defer rows.Close()
+	return "(" + strings.Join(clauses, " OR ") + ")", args
+}
 
-	// 4. Process results
-	results := make([]*DBEntity, 0)
+/*
+Maps each row to a new instance created with newInstance
+*/
+func (dbr *DBRepository) scanRows(rows *sql.Rows, newInstance func() DBEntityInterface) ([]DBEntityInterface, error) {
+	results := make([]DBEntityInterface, 0)
 	columns, err := rows.Columns()
 	if err != nil {
 		return nil, err
@@ -128,7 +198,7 @@ func (dbr *DBRepository) Search(dbe *DBEntity, useLike bool, caseSensitive bool,
 
 	for rows.Next() {
 		// Create a new instance of the DBEntity
-		resultEntity := dbe.NewInstance()
+		resultEntity := newInstance()
 
 		// Prepare a slice of interfaces to hold column values
 		columnValues := make([]interface{}, len(columns))
@@ -142,26 +212,418 @@ func (dbr *DBRepository) Search(dbe *DBEntity, useLike bool, caseSensitive bool,
 			return nil, err
 		}
 
-		// Map column values to the result entity's dictionary
+		// Map column values to the result entity's dictionary, converted to the column type
 		for i, colName := range columns {
-			val := columnValues[i]
-			if b, ok := val.([]byte); ok {
-				resultEntity.SetValue(colName, string(b))
-			} else if val != nil {
-				resultEntity.SetValue(colName, fmt.Sprint(val))
-			} else {
-				resultEntity.SetValue(colName, "")
-			}
+			resultEntity.setValueFromDB(colName, columnValues[i])
 		}
 
 		results = append(results, resultEntity)
 	}
+	return results, rows.Err()
+}
+
+/*
+Builds the SELECT of the entities matching the values set in dbe and the filters.
+For DBObjects only the rows the user can read are selected.
+*/
+func (dbr *DBRepository) buildSearchQuery(dbe DBEntityInterface, useLike bool, caseSensitive bool, filters []Filter) (string, []interface{}, error) {
+	whereClauses, args := dbr.buildWhere(dbe, useLike, caseSensitive)
+	filterClauses, filterArgs, err := buildFilters(dbe, filters)
+	if err != nil {
+		return "", nil, err
+	}
+	whereClauses = append(whereClauses, filterClauses...)
+	args = append(args, filterArgs...)
+	if _, isObject := dbe.(DBObjectInterface); isObject {
+		permClause, permArgs := dbr.buildReadPermissionClause()
+		whereClauses = append(whereClauses, permClause)
+		args = append(args, permArgs...)
+		if !dbr.IncludeDeleted {
+			whereClauses = append(whereClauses, buildDeletedClause(false))
+		}
+	}
+
+	query := "SELECT * FROM " + dbr.buildTableName(dbe)
+	if len(whereClauses) > 0 {
+		query += " WHERE " + strings.Join(whereClauses, " AND ")
+	}
+	return query, args, nil
+}
 
+/*
+Search the entities matching the values set in dbe.
+For DBObjects only the rows the user can read are returned.
+orderBy is a sort spec of columns of the entity, ie. "name,-creation_date" (see ParseOrderBy).
+The filters add conditions beyond equality and LIKE, see Filter.
+*/
+func (dbr *DBRepository) Search(dbe DBEntityInterface, useLike bool, caseSensitive bool, orderBy string, filters ...Filter) ([]DBEntityInterface, error) {
 	if dbr.Verbose {
-		log.Printf("DBRepository::Search: found %d results", len(results))
+		log.Print("DBRepository::Search: dbe=", dbe)
 	}
 
-	// 5. Return results
+	// 1. Build the query
+	terms, err := ParseOrderByFor(dbe, orderBy)
+	if err != nil {
+		return nil, err
+	}
+	query, args, err := dbr.buildSearchQuery(dbe, useLike, caseSensitive, filters)
+	if err != nil {
+		return nil, err
+	}
+	if len(terms) > 0 {
+		query += " ORDER BY " + orderByClause(terms)
+	}
+
+	if dbr.Verbose {
+		log.Print("DBRepository::Search: query=", query, " args=", args)
+	}
+
+	// 2. Execute the query
+	rows, err := dbr.DbConnection.QueryContext(dbr.Context(), query, args...)
+	if err != nil {
+		log.Print("DBRepository::Search: Query error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	// 3. Process results
+	results, err := dbr.scanRows(rows, dbe.NewInstance)
+	if err != nil {
+		return nil, err
+	}
+
+	if dbr.Verbose {
+		log.Printf("DBRepository::Search: found %d results", len(results))
+	}
 
 	return results, nil
 }
+
+/*
+Like Search, but returns a page of the results with the total count.
+orderBy is a sort spec of columns of the entity (ie. "name,-creation_date");
+the primary keys are added to make the order stable.
+*/
+func (dbr *DBRepository) SearchPage(dbe DBEntityInterface, useLike bool, caseSensitive bool, orderBy string, page PageRequest, filters ...Filter) (*SearchPage, error) {
+	if dbr.Verbose {
+		log.Print("DBRepository::SearchPage: dbe=", dbe, " page=", page)
+	}
+
+	terms, err := ParseOrderByFor(dbe, orderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := dbr.buildSearchQuery(dbe, useLike, caseSensitive, filters)
+	if err != nil {
+		return nil, err
+	}
+	return dbr.queryPage(query, args, StableOrder(terms, dbe.GetKeys()), page, dbe.NewInstance)
+}
+
+/*
+Returns the populated columns of the entity that are part of its definition.
+Keys of the dictionary that are not columns of the table are ignored.
+*/
+func (dbr *DBRepository) populatedColumns(dbe DBEntityInterface) []string {
+	ret := make([]string, 0)
+	for _, key := range dbe.GetDictionaryKeys() {
+		if dbe.HasColumn(key) {
+			ret = append(ret, key)
+		}
+	}
+	return ret
+}
+
+/*
+Builds the WHERE clause on the primary keys of the entity.
+Returns an error if one of the keys is not set.
+*/
+func (dbr *DBRepository) buildKeysWhere(dbe DBEntityInterface) (string, []interface{}, error) {
+	keys := dbe.GetKeys()
+	if len(keys) == 0 {
+		return "", nil, fmt.Errorf("entity %s has no primary keys", dbe.GetTypeName())
+	}
+	whereClauses := make([]string, 0, len(keys))
+	args := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		if !dbe.HasValue(key) {
+			return "", nil, fmt.Errorf("entity %s: primary key %s not set", dbe.GetTypeName(), key)
+		}
+		whereClauses = append(whereClauses, QuoteIdentifier(key)+" = ?")
+		args = append(args, dbe.getValueToDB(key))
+	}
+	return strings.Join(whereClauses, " AND "), args, nil
+}
+
+/*
+Insert the entity in the db.
+
+It is transactional:
+ 1. dbe.BeforeInsert(tx)
+ 2. INSERT INTO with the populated columns only
+ 3. dbe.AfterInsert(tx)
+*/
+func (dbr *DBRepository) Insert(dbe DBEntityInterface) (DBEntityInterface, error) {
+	if dbr.Verbose {
+		log.Print("DBRepository::Insert: dbe=", dbe)
+	}
+
+	tx, err := dbr.DbConnection.BeginTx(dbr.Context(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := dbr.InsertWithTx(tx, dbe); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return dbe, nil
+}
+
+/*
+Insert the entity using an already open transaction.
+Useful in the lifecycle hooks to write related entities atomically.
+*/
+func (dbr *DBRepository) InsertWithTx(tx *sql.Tx, dbe DBEntityInterface) error {
+	if err := dbe.BeforeInsert(dbr, tx); err != nil {
+		return err
+	}
+
+	columns := dbr.populatedColumns(dbe)
+	if len(columns) == 0 {
+		return fmt.Errorf("entity %s: nothing to insert", dbe.GetTypeName())
+	}
+	quoted := make([]string, 0, len(columns))
+	placeholders := make([]string, 0, len(columns))
+	args := make([]interface{}, 0, len(columns))
+	for _, col := range columns {
+		quoted = append(quoted, QuoteIdentifier(col))
+		placeholders = append(placeholders, "?")
+		args = append(args, dbe.getValueToDB(col))
+	}
+	query := "INSERT INTO " + dbr.buildTableName(dbe) +
+		" (" + strings.Join(quoted, ", ") + ") VALUES (" + strings.Join(placeholders, ", ") + ")"
+
+	if dbr.Verbose {
+		log.Print("DBRepository::Insert: query=", query, " args=", args)
+	}
+
+	if _, err := tx.ExecContext(dbr.Context(), query, args...); err != nil {
+		log.Print("DBRepository::Insert: Exec error:", err)
+		return err
+	}
+
+	return dbe.AfterInsert(dbr, tx)
+}
+
+/*
+Update the entity in the db.
+
+Only the populated columns are written, the primary keys are used in the WHERE clause.
+It is transactional like Insert.
+*/
+func (dbr *DBRepository) Update(dbe DBEntityInterface) (DBEntityInterface, error) {
+	if dbr.Verbose {
+		log.Print("DBRepository::Update: dbe=", dbe)
+	}
+
+	tx, err := dbr.DbConnection.BeginTx(dbr.Context(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := dbr.UpdateWithTx(tx, dbe); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return dbe, nil
+}
+
+/*
+Update the entity using an already open transaction.
+*/
+func (dbr *DBRepository) UpdateWithTx(tx *sql.Tx, dbe DBEntityInterface) error {
+	if err := dbe.BeforeUpdate(dbr, tx); err != nil {
+		return err
+	}
+
+	setClauses := make([]string, 0)
+	args := make([]interface{}, 0)
+	for _, col := range dbr.populatedColumns(dbe) {
+		if dbe.IsPrimaryKey(col) {
+			continue
+		}
+		setClauses = append(setClauses, QuoteIdentifier(col)+" = ?")
+		args = append(args, dbe.getValueToDB(col))
+	}
+	if len(setClauses) == 0 {
+		return fmt.Errorf("entity %s: nothing to update", dbe.GetTypeName())
+	}
+	where, whereArgs, err := dbr.buildKeysWhere(dbe)
+	if err != nil {
+		return err
+	}
+	args = append(args, whereArgs...)
+	query := "UPDATE " + dbr.buildTableName(dbe) + " SET " + strings.Join(setClauses, ", ") + " WHERE " + where
+
+	if dbr.Verbose {
+		log.Print("DBRepository::Update: query=", query, " args=", args)
+	}
+
+	if _, err := tx.ExecContext(dbr.Context(), query, args...); err != nil {
+		log.Print("DBRepository::Update: Exec error:", err)
+		return err
+	}
+
+	return dbe.AfterUpdate(dbr, tx)
+}
+
+/*
+Delete the entity from the db using its primary keys.
+DBObjects are soft deleted: see Purge to remove them from the db.
+It is transactional like Insert.
+*/
+func (dbr *DBRepository) Delete(dbe DBEntityInterface) (DBEntityInterface, error) {
+	if dbr.Verbose {
+		log.Print("DBRepository::Delete: dbe=", dbe)
+	}
+
+	tx, err := dbr.DbConnection.BeginTx(dbr.Context(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := dbr.DeleteWithTx(tx, dbe); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return dbe, nil
+}
+
+/*
+Delete the entity using an already open transaction.
+*/
+func (dbr *DBRepository) DeleteWithTx(tx *sql.Tx, dbe DBEntityInterface) error {
+	_, isObject := dbe.(DBObjectInterface)
+	return dbr.deleteWithTx(tx, dbe, !isObject)
+}
+
+/*
+Remove the entity from the db, even if it is a DBObject.
+*/
+func (dbr *DBRepository) Purge(dbe DBEntityInterface) (DBEntityInterface, error) {
+	if dbr.Verbose {
+		log.Print("DBRepository::Purge: dbe=", dbe)
+	}
+
+	tx, err := dbr.DbConnection.BeginTx(dbr.Context(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := dbr.deleteWithTx(tx, dbe, true); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return dbe, nil
+}
+
+func (dbr *DBRepository) deleteWithTx(tx *sql.Tx, dbe DBEntityInterface, purge bool) error {
+	if err := dbe.BeforeDelete(dbr, tx); err != nil {
+		return err
+	}
+
+	where, args, err := dbr.buildKeysWhere(dbe)
+	if err != nil {
+		return err
+	}
+	var query string
+	if purge {
+		query = "DELETE FROM " + dbr.buildTableName(dbe) + " WHERE " + where
+	} else {
+		// Soft delete
+		deletedBy := ""
+		if dbr.DbContext != nil {
+			deletedBy = dbr.DbContext.UserID
+		}
+		deletedDate := time.Now()
+		query = "UPDATE " + dbr.buildTableName(dbe) + " SET deleted_by = ?, deleted_date = ? WHERE " + where
+		args = append([]interface{}{deletedBy, deletedDate.Format(DateTimeFormat)}, args...)
+		dbe.SetValue("deleted_by", deletedBy)
+		dbe.SetTime("deleted_date", deletedDate)
+	}
+
+	if dbr.Verbose {
+		log.Print("DBRepository::Delete: query=", query, " args=", args)
+	}
+
+	if _, err := tx.ExecContext(dbr.Context(), query, args...); err != nil {
+		log.Print("DBRepository::Delete: Exec error:", err)
+		return err
+	}
+
+	return dbe.AfterDelete(dbr, tx)
+}
+
+/*
+Restore a soft deleted DBObject.
+The user must have write permission on the object.
+*/
+func (dbr *DBRepository) Restore(dbe DBObjectInterface) (DBObjectInterface, error) {
+	if dbr.Verbose {
+		log.Print("DBRepository::Restore: dbe=", dbe)
+	}
+
+	tx, err := dbr.DbConnection.BeginTx(dbr.Context(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := dbe.CheckWritePermission(dbr, tx); err != nil {
+		return nil, err
+	}
+
+	where, args, err := dbr.buildKeysWhere(dbe)
+	if err != nil {
+		return nil, err
+	}
+	query := "UPDATE " + dbr.buildTableName(dbe) + " SET deleted_by = NULL, deleted_date = ? WHERE " + where
+	args = append([]interface{}{ZeroDateTime}, args...)
+
+	if dbr.Verbose {
+		log.Print("DBRepository::Restore: query=", query, " args=", args)
+	}
+
+	if _, err := tx.ExecContext(dbr.Context(), query, args...); err != nil {
+		log.Print("DBRepository::Restore: Exec error:", err)
+		return nil, err
+	}
+	if err := dbr.updateSearchIndex(tx, dbe); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	dbe.SetNull("deleted_by")
+	dbe.SetTime("deleted_date", time.Time{})
+	return dbe, nil
+}
@@ -1,8 +1,11 @@
 package dblayer
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"log"
+	"strings"
 
 	"testing"
 
@@ -26,7 +29,7 @@ func TestSearchUserByLogin(t *testing.T) {
 	}
 	factory := NewDBEFactory(true)
 	user := NewDBUser()
-	factory.Register(&user.DBEntity)
+	factory.Register(user)
 	dbConnection, err := sql.Open("mysql", "root:mysecret@tcp(localhost:3306)/rproject")
 	if err != nil {
 		t.Fatal("Failed to connect to database:", err)
@@ -61,3 +64,102 @@ func TestSearchUserByLogin(t *testing.T) {
 		log.Printf("- %s\t%s\t%s\n", user.GetValue("id"), user.GetValue("login"), user.GetValue("fullname"))
 	}
 }
+
+/*
+Insert, update and delete a DBVersion row in a transaction
+*/
+func TestInsertUpdateDeleteDBVersion(t *testing.T) {
+	dbContext := &DBContext{
+		UserID:   "-1",
+		GroupIDs: []string{"-2"},
+		Schema:   "rprj",
+	}
+	factory := NewDBEFactory(true)
+	factory.Register(NewDBVersion())
+	dbConnection, err := sql.Open("mysql", "root:mysecret@tcp(localhost:3306)/rproject")
+	if err != nil {
+		t.Fatal("Failed to connect to database:", err)
+	}
+	defer dbConnection.Close()
+
+	repo := NewDBRepository(dbContext, factory, dbConnection)
+	repo.Verbose = true
+
+	version := factory.GetInstanceByClassName("DBVersion")
+	version.SetValue("model_name", "test_crud")
+	version.SetValue("version", "1")
+	if _, err := repo.Insert(version); err != nil {
+		t.Fatal("Failed to insert:", err)
+	}
+
+	version.SetValue("version", "2")
+	if _, err := repo.Update(version); err != nil {
+		t.Fatal("Failed to update:", err)
+	}
+
+	search := factory.GetInstanceByClassName("DBVersion")
+	search.SetValue("model_name", "test_crud")
+	results, err := repo.Search(search, false, false, "")
+	if err != nil {
+		t.Fatal("Failed to search:", err)
+	}
+	if len(results) != 1 || results[0].GetValue("version") != "2" {
+		t.Fatal("Unexpected search results:", results)
+	}
+
+	if _, err := repo.Delete(version); err != nil {
+		t.Fatal("Failed to delete:", err)
+	}
+}
+
+func TestDBContextInContext(t *testing.T) {
+	if FromContext(context.Background()) != nil {
+		t.Fatal("expected nil DBContext in an empty context")
+	}
+	dbContext := &DBContext{UserID: "-1", GroupIDs: []string{"-2"}, Schema: "rprj"}
+	ctx := NewContext(context.Background(), dbContext)
+	if FromContext(ctx) != dbContext {
+		t.Fatal("expected the DBContext put in the context")
+	}
+}
+
+func TestRepositoryContext(t *testing.T) {
+	factory := NewDBEFactory(false)
+	factory.Register(NewDBUser())
+	dbConnection, err := sql.Open("mysql", "root:mysecret@tcp(localhost:3306)/rproject")
+	if err != nil {
+		t.Fatal("Failed to open the connection:", err)
+	}
+	defer dbConnection.Close()
+
+	repo := NewDBRepository(&DBContext{UserID: "-1", GroupIDs: []string{"-2"}}, factory, dbConnection)
+	if repo.Context() != context.Background() {
+		t.Error("expected context.Background() without WithContext")
+	}
+	// The queries of a canceled request are not executed
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	user := NewDBUser()
+	user.SetValue("login", "adm")
+	if _, err := repo.WithContext(ctx).Search(user, false, false, "login"); !errors.Is(err, context.Canceled) {
+		t.Error("expected context.Canceled, got", err)
+	}
+}
+
+func TestBuildReadPermissionClause(t *testing.T) {
+	repo := &DBRepository{DbContext: &DBContext{UserID: "-1", GroupIDs: []string{"-2"}}}
+	clause, args := repo.buildReadPermissionClause()
+	// Empty permissions are DefaultPermissions: readable only by the owner
+	if strings.Count(clause, "permissions = ''") != 1 ||
+		!strings.Contains(clause, "(owner = ? AND (permissions = '' OR permissions LIKE 'r________'))") {
+		t.Error("empty permissions must be readable only by the owner:", clause)
+	}
+	if len(args) != 2 || args[0] != "-1" || args[1] != "-2" {
+		t.Error("unexpected args:", args)
+	}
+
+	repo.DbContext = &DBContext{}
+	if clause, _ := repo.buildReadPermissionClause(); strings.Contains(clause, "''") {
+		t.Error("the anonymous user must read only the public objects:", clause)
+	}
+}
@@ -1,5 +1,9 @@
 package dblayer
 
+import (
+	"database/sql"
+)
+
 /*
 CREATE TABLE `rprj_dbversion` (
 
@@ -31,6 +35,9 @@ func NewDBVersion() *DBVersion {
 		),
 	}
 }
+func (dbVersion *DBVersion) NewInstance() DBEntityInterface {
+	return NewDBVersion()
+}
 
 /*
 CREATE TABLE `rprj_users` (
@@ -56,8 +63,8 @@ func NewDBUser() *DBUser {
 		{Name: "id", Type: "varchar(16)", Constraints: []string{"NOT NULL"}},
 		{Name: "login", Type: "varchar(255)", Constraints: []string{"NOT NULL"}},
 		{Name: "pwd", Type: "varchar(255)", Constraints: []string{"NOT NULL"}},
-		{Name: "pwd_salt", Type: "varchar(4)", Constraints: []string{}},
-		{Name: "fullname", Type: "text", Constraints: []string{}},
+		{Name: "pwd_salt", Type: "varchar(4)", Constraints: []string{"DEFAULT ''"}},
+		{Name: "fullname", Type: "text", Constraints: []string{"DEFAULT NULL"}},
 		{Name: "group_id", Type: "varchar(16)", Constraints: []string{"NOT NULL"}},
 	}
 	keys := []string{"id"}
@@ -75,3 +82,325 @@ func NewDBUser() *DBUser {
 		),
 	}
 }
+func (dbUser *DBUser) NewInstance() DBEntityInterface {
+	return NewDBUser()
+}
+
+/*
+Generates the id and, if not given, a personal group for the user.
+A password in clear is hashed.
+*/
+func (dbUser *DBUser) BeforeInsert(dbr *DBRepository, tx *sql.Tx) error {
+	if err := dbUser.hashClearPassword(); err != nil {
+		return err
+	}
+	if !dbUser.HasValue("id") || dbUser.GetValue("id") == "" {
+		userID, err := UUID16Hex()
+		if err != nil {
+			return err
+		}
+		dbUser.SetValue("id", userID)
+	}
+	if !dbUser.HasValue("group_id") || dbUser.GetValue("group_id") == "" {
+		login := dbUser.GetValue("login")
+		group := NewDBGroup()
+		group.SetValue("name", login+"'s group")
+		group.SetValue("description", "Personal group for "+login)
+		if err := dbr.InsertWithTx(tx, group); err != nil {
+			return err
+		}
+		dbUser.ReadFKFrom(group)
+	}
+	return nil
+}
+
+/*
+Hashes the password if given in clear
+*/
+func (dbUser *DBUser) BeforeUpdate(dbr *DBRepository, tx *sql.Tx) error {
+	return dbUser.hashClearPassword()
+}
+
+/*
+Adds the user to its primary group
+*/
+func (dbUser *DBUser) AfterInsert(dbr *DBRepository, tx *sql.Tx) error {
+	userGroup := NewDBUserGroup()
+	userGroup.SetValue("user_id", dbUser.GetValue("id"))
+	userGroup.SetValue("group_id", dbUser.GetValue("group_id"))
+	return dbr.InsertWithTx(tx, userGroup)
+}
+
+/*
+Removes the user from all its groups
+*/
+func (dbUser *DBUser) BeforeDelete(dbr *DBRepository, tx *sql.Tx) error {
+	userGroup := NewDBUserGroup()
+	_, err := tx.ExecContext(dbr.Context(), "DELETE FROM "+dbr.buildTableName(userGroup)+" WHERE user_id = ?", dbUser.GetValue("id"))
+	return err
+}
+
+/*
+CREATE TABLE `rprj_groups` (
+
+	`id` varchar(16) NOT NULL,
+	`name` varchar(255) NOT NULL,
+	`description` text DEFAULT NULL,
+	PRIMARY KEY (`id`),
+	KEY `rprj_groups_0` (`id`)
+
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+*/
+type DBGroup struct {
+	DBEntity
+}
+
+func NewDBGroup() *DBGroup {
+	columns := []Column{
+		{Name: "id", Type: "varchar(16)", Constraints: []string{"NOT NULL"}},
+		{Name: "name", Type: "varchar(255)", Constraints: []string{"NOT NULL"}},
+		{Name: "description", Type: "text", Constraints: []string{"DEFAULT NULL"}},
+	}
+	keys := []string{"id"}
+	return &DBGroup{
+		DBEntity: *NewDBEntity(
+			"DBGroup",
+			"groups",
+			columns,
+			keys,
+			[]ForeignKey{},
+			make(map[string]any),
+		),
+	}
+}
+func (dbGroup *DBGroup) NewInstance() DBEntityInterface {
+	return NewDBGroup()
+}
+
+func (dbGroup *DBGroup) BeforeInsert(dbr *DBRepository, tx *sql.Tx) error {
+	if !dbGroup.HasValue("id") || dbGroup.GetValue("id") == "" {
+		groupID, err := UUID16Hex()
+		if err != nil {
+			return err
+		}
+		dbGroup.SetValue("id", groupID)
+	}
+	return nil
+}
+
+/*
+Removes all the users from the group
+*/
+func (dbGroup *DBGroup) BeforeDelete(dbr *DBRepository, tx *sql.Tx) error {
+	userGroup := NewDBUserGroup()
+	_, err := tx.ExecContext(dbr.Context(), "DELETE FROM "+dbr.buildTableName(userGroup)+" WHERE group_id = ?", dbGroup.GetValue("id"))
+	return err
+}
+
+/*
+CREATE TABLE `rprj_users_groups` (
+
+	`user_id` varchar(16) NOT NULL,
+	`group_id` varchar(16) NOT NULL,
+	PRIMARY KEY (`user_id`,`group_id`),
+	KEY `rprj_users_groups_0` (`user_id`),
+	KEY `rprj_users_groups_1` (`group_id`),
+	KEY `rprj_users_groups_2` (`user_id`),
+	KEY `rprj_users_groups_3` (`group_id`)
+
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+*/
+type DBUserGroup struct {
+	DBEntity
+}
+
+func NewDBUserGroup() *DBUserGroup {
+	columns := []Column{
+		{Name: "user_id", Type: "varchar(16)", Constraints: []string{"NOT NULL"}},
+		{Name: "group_id", Type: "varchar(16)", Constraints: []string{"NOT NULL"}},
+	}
+	keys := []string{"user_id", "group_id"}
+	foreignKeys := []ForeignKey{
+		{Column: "user_id", RefTable: "users", RefColumn: "id"},
+		{Column: "group_id", RefTable: "groups", RefColumn: "id"},
+	}
+	return &DBUserGroup{
+		DBEntity: *NewDBEntity(
+			"DBUserGroup",
+			"users_groups",
+			columns,
+			keys,
+			foreignKeys,
+			make(map[string]any),
+		),
+	}
+}
+func (dbUserGroup *DBUserGroup) NewInstance() DBEntityInterface {
+	return NewDBUserGroup()
+}
+
+/*
+CREATE TABLE `rprj_oauth_tokens` (
+
+	`token_id` varchar(512) NOT NULL,
+	`user_id` varchar(16) NOT NULL,
+	`access_token` text NOT NULL,
+	`refresh_token` text DEFAULT NULL,
+	`expires_at` datetime NOT NULL,
+	`created_at` datetime DEFAULT current_timestamp(),
+	`family_id` varchar(16) DEFAULT NULL,
+	`refresh_expires_at` datetime DEFAULT NULL,
+	`rotated_at` datetime DEFAULT NULL,
+	`last_seen` datetime DEFAULT NULL,
+	PRIMARY KEY (`token_id`),
+	KEY `rprj_oauth_tokens_0` (`token_id`),
+	KEY `rprj_oauth_tokens_1` (`user_id`)
+
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+One row per access/refresh token pair: refresh_token is the sha256 of the token.
+The pairs obtained by refreshing share the family_id of the login;
+rotated_at is set when the pair has been replaced by a refresh.
+All the times are in UTC.
+*/
+type DBOAuthToken struct {
+	DBEntity
+}
+
+func NewDBOAuthToken() *DBOAuthToken {
+	columns := []Column{
+		{Name: "token_id", Type: "varchar(512)", Constraints: []string{"NOT NULL"}},
+		{Name: "user_id", Type: "varchar(16)", Constraints: []string{"NOT NULL"}},
+		{Name: "access_token", Type: "text", Constraints: []string{"NOT NULL"}},
+		{Name: "refresh_token", Type: "text", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "expires_at", Type: "datetime", Constraints: []string{"NOT NULL"}},
+		{Name: "created_at", Type: "datetime", Constraints: []string{"DEFAULT current_timestamp()"}},
+		{Name: "family_id", Type: "varchar(16)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "refresh_expires_at", Type: "datetime", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "rotated_at", Type: "datetime", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "last_seen", Type: "datetime", Constraints: []string{"DEFAULT NULL"}},
+	}
+	keys := []string{"token_id"}
+	foreignKeys := []ForeignKey{
+		{Column: "user_id", RefTable: "users", RefColumn: "id"},
+	}
+	return &DBOAuthToken{
+		DBEntity: *NewDBEntity(
+			"DBOAuthToken",
+			"oauth_tokens",
+			columns,
+			keys,
+			foreignKeys,
+			make(map[string]any),
+		),
+	}
+}
+func (dbOAuthToken *DBOAuthToken) NewInstance() DBEntityInterface {
+	return NewDBOAuthToken()
+}
+
+/*
+CREATE TABLE `rprj_calendar_feeds` (
+
+	`id` varchar(16) NOT NULL,
+	`user_id` varchar(16) NOT NULL,
+	`token_hash` char(64) NOT NULL,
+	`name` varchar(255) NOT NULL DEFAULT '',
+	`created_at` datetime DEFAULT NULL,
+	`last_used` datetime DEFAULT NULL,
+	PRIMARY KEY (`id`),
+	KEY `rprj_calendar_feeds_0` (`user_id`)
+
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+The tokens of the calendar feeds (GET /calendar.ics?token=...): they let the calendar clients
+read the events of the user without the Authorization header.
+token_hash is the sha256 of the token. All the times are in UTC.
+*/
+type DBCalendarFeed struct {
+	DBEntity
+}
+
+func NewDBCalendarFeed() *DBCalendarFeed {
+	columns := []Column{
+		{Name: "id", Type: "varchar(16)", Constraints: []string{"NOT NULL"}},
+		{Name: "user_id", Type: "varchar(16)", Constraints: []string{"NOT NULL"}},
+		{Name: "token_hash", Type: "char(64)", Constraints: []string{"NOT NULL"}},
+		{Name: "name", Type: "varchar(255)", Constraints: []string{"NOT NULL", "DEFAULT ''"}},
+		{Name: "created_at", Type: "datetime", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "last_used", Type: "datetime", Constraints: []string{"DEFAULT NULL"}},
+	}
+	keys := []string{"id"}
+	foreignKeys := []ForeignKey{
+		{Column: "user_id", RefTable: "users", RefColumn: "id"},
+	}
+	return &DBCalendarFeed{
+		DBEntity: *NewDBEntity(
+			"DBCalendarFeed",
+			"calendar_feeds",
+			columns,
+			keys,
+			foreignKeys,
+			make(map[string]any),
+		),
+	}
+}
+func (dbCalendarFeed *DBCalendarFeed) NewInstance() DBEntityInterface {
+	return NewDBCalendarFeed()
+}
+
+/*
+CREATE TABLE `rprj_search_index` (
+
+	`id` varchar(16) NOT NULL,
+	`classname` varchar(255) NOT NULL,
+	`owner` varchar(16) NOT NULL,
+	`group_id` varchar(16) NOT NULL,
+	`permissions` char(9) NOT NULL DEFAULT 'rwx------',
+	`deleted_date` datetime NOT NULL DEFAULT '0000-00-00 00:00:00',
+	`last_modify_date` datetime DEFAULT NULL,
+	`name` varchar(255) NOT NULL,
+	`body` mediumtext DEFAULT NULL,
+	PRIMARY KEY (`id`),
+	KEY `rprj_search_index_0` (`id`),
+	FULLTEXT KEY `rprj_search_index_ft_name` (`name`),
+	FULLTEXT KEY `rprj_search_index_ft` (`name`,`body`)
+
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+
+The text of the DBObjects for the full text search, one row per object:
+body is the text of the searchable columns (see SearchColumns), without HTML.
+owner, group_id, permissions and deleted_date are copied from the object to filter the results.
+The table and its FULLTEXT keys are created by the migrations (see db/migrations.go).
+*/
+type DBSearchIndex struct {
+	DBEntity
+}
+
+func NewDBSearchIndex() *DBSearchIndex {
+	columns := []Column{
+		{Name: "id", Type: "varchar(16)", Constraints: []string{"NOT NULL"}},
+		{Name: "classname", Type: "varchar(255)", Constraints: []string{"NOT NULL"}},
+		{Name: "owner", Type: "varchar(16)", Constraints: []string{"NOT NULL"}},
+		{Name: "group_id", Type: "varchar(16)", Constraints: []string{"NOT NULL"}},
+		{Name: "permissions", Type: "char(9)", Constraints: []string{"NOT NULL", "DEFAULT 'rwx------'"}},
+		{Name: "deleted_date", Type: "datetime", Constraints: []string{"NOT NULL", "DEFAULT '0000-00-00 00:00:00'"}},
+		{Name: "last_modify_date", Type: "datetime", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "name", Type: "varchar(255)", Constraints: []string{"NOT NULL"}},
+		{Name: "body", Type: "mediumtext", Constraints: []string{"DEFAULT NULL"}},
+	}
+	keys := []string{"id"}
+	return &DBSearchIndex{
+		DBEntity: *NewDBEntity(
+			"DBSearchIndex",
+			"search_index",
+			columns,
+			keys,
+			[]ForeignKey{},
+			make(map[string]any),
+		),
+	}
+}
+func (dbSearchIndex *DBSearchIndex) NewInstance() DBEntityInterface {
+	return NewDBSearchIndex()
+}
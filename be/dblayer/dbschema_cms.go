@@ -0,0 +1,433 @@
+package dblayer
+
+/** *********************************** CMS: start. *********************************** */
+
+/*
+CREATE TABLE `rprj_events` (
+
+	`id` varchar(16) NOT NULL,
+	`owner` varchar(16) NOT NULL,
+	`group_id` varchar(16) NOT NULL,
+	`permissions` char(9) NOT NULL DEFAULT 'rwx------',
+	`creator` varchar(16) NOT NULL,
+	`creation_date` datetime DEFAULT NULL,
+	`last_modify` varchar(16) NOT NULL,
+	`last_modify_date` datetime DEFAULT NULL,
+	`deleted_by` varchar(16) DEFAULT NULL,
+	`deleted_date` datetime NOT NULL DEFAULT '0000-00-00 00:00:00',
+	`father_id` varchar(16) DEFAULT NULL,
+	`name` varchar(255) NOT NULL,
+	`description` text DEFAULT NULL,
+	`fk_obj_id` varchar(16) DEFAULT NULL,
+	`start_date` datetime NOT NULL DEFAULT '0000-00-00 00:00:00',
+	`end_date` datetime NOT NULL DEFAULT '0000-00-00 00:00:00',
+	`all_day` char(1) NOT NULL DEFAULT '1',
+	`url` varchar(255) DEFAULT NULL,
+	`alarm` char(1) DEFAULT '0',
+	`alarm_minute` int(11) DEFAULT 0,
+	`alarm_unit` char(1) DEFAULT '0',
+	`before_event` char(1) DEFAULT '0',
+	`category` varchar(255) DEFAULT '',
+	`recurrence` char(1) DEFAULT '0',
+	`recurrence_type` char(1) DEFAULT '0',
+	`daily_every_x` int(11) DEFAULT 0,
+	`weekly_every_x` int(11) DEFAULT 0,
+	`weekly_day_of_the_week` char(1) DEFAULT '0',
+	`monthly_every_x` int(11) DEFAULT 0,
+	`monthly_day_of_the_month` int(11) DEFAULT 0,
+	`monthly_week_number` int(11) DEFAULT 0,
+	`monthly_week_day` char(1) DEFAULT '0',
+	`yearly_month_number` int(11) DEFAULT 0,
+	`yearly_month_day` int(11) DEFAULT 0,
+	`yearly_week_number` int(11) DEFAULT 0,
+	`yearly_week_day` char(1) DEFAULT '0',
+	`yearly_day_of_the_year` int(11) DEFAULT 0,
+	`recurrence_times` int(11) DEFAULT 0,
+	`recurrence_end_date` datetime NOT NULL DEFAULT '0000-00-00 00:00:00',
+	PRIMARY KEY (`id`),
+	KEY `rprj_events_0` (`id`),
+	KEY `rprj_events_1` (`owner`),
+	KEY `rprj_events_2` (`group_id`),
+	KEY `rprj_events_3` (`creator`),
+	KEY `rprj_events_4` (`last_modify`),
+	KEY `rprj_events_5` (`deleted_by`),
+	KEY `rprj_events_6` (`father_id`),
+	KEY `rprj_events_7` (`fk_obj_id`),
+	KEY `rprj_events_8` (`fk_obj_id`),
+	KEY `rprj_events_9` (`fk_obj_id`),
+	KEY `rprj_events_10` (`fk_obj_id`),
+	KEY `rprj_events_idx2` (`start_date`),
+	KEY `rprj_events_idx3` (`end_date`)
+
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+*/
+type DBEvent struct {
+	DBObject
+}
+
+func NewDBEvent() *DBEvent {
+	columns := []Column{
+		{Name: "fk_obj_id", Type: "varchar(16)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "start_date", Type: "datetime", Constraints: []string{"NOT NULL", "DEFAULT '0000-00-00 00:00:00'"}},
+		{Name: "end_date", Type: "datetime", Constraints: []string{"NOT NULL", "DEFAULT '0000-00-00 00:00:00'"}},
+		{Name: "all_day", Type: "char(1)", Constraints: []string{"NOT NULL", "DEFAULT '1'"}},
+		{Name: "url", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "alarm", Type: "char(1)", Constraints: []string{"DEFAULT '0'"}},
+		{Name: "alarm_minute", Type: "int(11)", Constraints: []string{"DEFAULT 0"}},
+		{Name: "alarm_unit", Type: "char(1)", Constraints: []string{"DEFAULT '0'"}},
+		{Name: "before_event", Type: "char(1)", Constraints: []string{"DEFAULT '0'"}},
+		{Name: "category", Type: "varchar(255)", Constraints: []string{"DEFAULT ''"}},
+		{Name: "recurrence", Type: "char(1)", Constraints: []string{"DEFAULT '0'"}},
+		{Name: "recurrence_type", Type: "char(1)", Constraints: []string{"DEFAULT '0'"}},
+		{Name: "daily_every_x", Type: "int(11)", Constraints: []string{"DEFAULT 0"}},
+		{Name: "weekly_every_x", Type: "int(11)", Constraints: []string{"DEFAULT 0"}},
+		{Name: "weekly_day_of_the_week", Type: "char(1)", Constraints: []string{"DEFAULT '0'"}},
+		{Name: "monthly_every_x", Type: "int(11)", Constraints: []string{"DEFAULT 0"}},
+		{Name: "monthly_day_of_the_month", Type: "int(11)", Constraints: []string{"DEFAULT 0"}},
+		{Name: "monthly_week_number", Type: "int(11)", Constraints: []string{"DEFAULT 0"}},
+		{Name: "monthly_week_day", Type: "char(1)", Constraints: []string{"DEFAULT '0'"}},
+		{Name: "yearly_month_number", Type: "int(11)", Constraints: []string{"DEFAULT 0"}},
+		{Name: "yearly_month_day", Type: "int(11)", Constraints: []string{"DEFAULT 0"}},
+		{Name: "yearly_week_number", Type: "int(11)", Constraints: []string{"DEFAULT 0"}},
+		{Name: "yearly_week_day", Type: "char(1)", Constraints: []string{"DEFAULT '0'"}},
+		{Name: "yearly_day_of_the_year", Type: "int(11)", Constraints: []string{"DEFAULT 0"}},
+		{Name: "recurrence_times", Type: "int(11)", Constraints: []string{"DEFAULT 0"}},
+		{Name: "recurrence_end_date", Type: "datetime", Constraints: []string{"NOT NULL", "DEFAULT '0000-00-00 00:00:00'"}},
+	}
+	return &DBEvent{
+		DBObject: *NewDBObjectEntity("DBEvent", "events", columns, []ForeignKey{}),
+	}
+}
+func (dbEvent *DBEvent) NewInstance() DBEntityInterface {
+	return NewDBEvent()
+}
+
+/*
+CREATE TABLE `rprj_files` (
+
+	`id` varchar(16) NOT NULL,
+	`owner` varchar(16) NOT NULL,
+	`group_id` varchar(16) NOT NULL,
+	`permissions` char(9) NOT NULL DEFAULT 'rwx------',
+	`creator` varchar(16) NOT NULL,
+	`creation_date` datetime DEFAULT NULL,
+	`last_modify` varchar(16) NOT NULL,
+	`last_modify_date` datetime DEFAULT NULL,
+	`deleted_by` varchar(16) DEFAULT NULL,
+	`deleted_date` datetime NOT NULL DEFAULT '0000-00-00 00:00:00',
+	`father_id` varchar(16) DEFAULT NULL,
+	`name` varchar(255) NOT NULL,
+	`description` text DEFAULT NULL,
+	`fk_obj_id` varchar(16) DEFAULT NULL,
+	`path` text DEFAULT NULL,
+	`filename` text NOT NULL,
+	`checksum` char(40) DEFAULT NULL,
+	`mime` varchar(255) DEFAULT NULL,
+	`alt_link` varchar(255) NOT NULL DEFAULT '',
+	PRIMARY KEY (`id`),
+	KEY `rprj_files_0` (`id`),
+	KEY `rprj_files_1` (`owner`),
+	KEY `rprj_files_2` (`group_id`),
+	KEY `rprj_files_3` (`creator`),
+	KEY `rprj_files_4` (`last_modify`),
+	KEY `rprj_files_5` (`deleted_by`),
+	KEY `rprj_files_6` (`father_id`),
+	KEY `rprj_files_7` (`father_id`),
+	KEY `rprj_files_8` (`fk_obj_id`),
+	KEY `rprj_files_9` (`father_id`),
+	KEY `rprj_files_10` (`fk_obj_id`),
+	KEY `rprj_files_11` (`father_id`)
+
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+*/
+type DBFile struct {
+	DBObject
+}
+
+func NewDBFile() *DBFile {
+	columns := []Column{
+		{Name: "fk_obj_id", Type: "varchar(16)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "path", Type: "text", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "filename", Type: "text", Constraints: []string{"NOT NULL"}},
+		{Name: "checksum", Type: "char(40)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "mime", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "alt_link", Type: "varchar(255)", Constraints: []string{"NOT NULL", "DEFAULT ''"}},
+	}
+	return &DBFile{
+		DBObject: *NewDBObjectEntity("DBFile", "files", columns, []ForeignKey{}),
+	}
+}
+func (dbFile *DBFile) NewInstance() DBEntityInterface {
+	return NewDBFile()
+}
+
+/*
+The storage key of the content (path), its SHA-1 and its MIME type
+are written only by the upload, see api.UploadFileHandler
+*/
+func (dbFile *DBFile) IsManagedColumn(columnName string) bool {
+	switch columnName {
+	case "path", "checksum", "mime":
+		return true
+	}
+	return false
+}
+
+/*
+CREATE TABLE `rprj_folders` (
+
+	`id` varchar(16) NOT NULL,
+	`owner` varchar(16) NOT NULL,
+	`group_id` varchar(16) NOT NULL,
+	`permissions` char(9) NOT NULL DEFAULT 'rwx------',
+	`creator` varchar(16) NOT NULL,
+	`creation_date` datetime DEFAULT NULL,
+	`last_modify` varchar(16) NOT NULL,
+	`last_modify_date` datetime DEFAULT NULL,
+	`deleted_by` varchar(16) DEFAULT NULL,
+	`deleted_date` datetime NOT NULL DEFAULT '0000-00-00 00:00:00',
+	`father_id` varchar(16) DEFAULT NULL,
+	`name` varchar(255) NOT NULL,
+	`description` text DEFAULT NULL,
+	`fk_obj_id` varchar(16) DEFAULT NULL,
+	`childs_sort_order` text DEFAULT NULL,
+	PRIMARY KEY (`id`),
+	KEY `rprj_folders_0` (`id`),
+	KEY `rprj_folders_1` (`owner`),
+	KEY `rprj_folders_2` (`group_id`),
+	KEY `rprj_folders_3` (`creator`),
+	KEY `rprj_folders_4` (`last_modify`),
+	KEY `rprj_folders_5` (`deleted_by`),
+	KEY `rprj_folders_6` (`father_id`),
+	KEY `rprj_folders_7` (`fk_obj_id`),
+	KEY `rprj_folders_8` (`fk_obj_id`),
+	KEY `rprj_folders_9` (`fk_obj_id`)
+
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+*/
+type DBFolder struct {
+	DBObject
+}
+
+func NewDBFolder() *DBFolder {
+	columns := []Column{
+		{Name: "fk_obj_id", Type: "varchar(16)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "childs_sort_order", Type: "text", Constraints: []string{"DEFAULT NULL"}},
+	}
+	return &DBFolder{
+		DBObject: *NewDBObjectEntity("DBFolder", "folders", columns, []ForeignKey{}),
+	}
+}
+func (dbFolder *DBFolder) NewInstance() DBEntityInterface {
+	return NewDBFolder()
+}
+
+/*
+CREATE TABLE `rprj_links` (
+
+	`id` varchar(16) NOT NULL,
+	`owner` varchar(16) NOT NULL,
+	`group_id` varchar(16) NOT NULL,
+	`permissions` char(9) NOT NULL DEFAULT 'rwx------',
+	`creator` varchar(16) NOT NULL,
+	`creation_date` datetime DEFAULT NULL,
+	`last_modify` varchar(16) NOT NULL,
+	`last_modify_date` datetime DEFAULT NULL,
+	`deleted_by` varchar(16) DEFAULT NULL,
+	`deleted_date` datetime NOT NULL DEFAULT '0000-00-00 00:00:00',
+	`father_id` varchar(16) DEFAULT NULL,
+	`name` varchar(255) NOT NULL,
+	`description` text DEFAULT NULL,
+	`href` varchar(255) NOT NULL,
+	`target` varchar(255) DEFAULT '_blank',
+	`fk_obj_id` varchar(16) DEFAULT NULL,
+	PRIMARY KEY (`id`),
+	KEY `rprj_links_0` (`id`),
+	KEY `rprj_links_1` (`owner`),
+	KEY `rprj_links_2` (`group_id`),
+	KEY `rprj_links_3` (`creator`),
+	KEY `rprj_links_4` (`last_modify`),
+	KEY `rprj_links_5` (`deleted_by`),
+	KEY `rprj_links_6` (`father_id`),
+	KEY `rprj_links_7` (`fk_obj_id`),
+	KEY `rprj_links_8` (`fk_obj_id`),
+	KEY `rprj_links_9` (`fk_obj_id`),
+	KEY `rprj_links_10` (`fk_obj_id`),
+	KEY `rprj_links_11` (`fk_obj_id`),
+	KEY `rprj_links_12` (`father_id`),
+	KEY `rprj_links_13` (`fk_obj_id`),
+	KEY `rprj_links_14` (`father_id`)
+
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+*/
+type DBLink struct {
+	DBObject
+}
+
+func NewDBLink() *DBLink {
+	columns := []Column{
+		{Name: "href", Type: "varchar(255)", Constraints: []string{"NOT NULL"}},
+		{Name: "target", Type: "varchar(255)", Constraints: []string{"DEFAULT '_blank'"}},
+		{Name: "fk_obj_id", Type: "varchar(16)", Constraints: []string{"DEFAULT NULL"}},
+	}
+	return &DBLink{
+		DBObject: *NewDBObjectEntity("DBLink", "links", columns, []ForeignKey{}),
+	}
+}
+func (dbLink *DBLink) NewInstance() DBEntityInterface {
+	return NewDBLink()
+}
+
+/*
+CREATE TABLE `rprj_notes` (
+
+	`id` varchar(16) NOT NULL,
+	`owner` varchar(16) NOT NULL,
+	`group_id` varchar(16) NOT NULL,
+	`permissions` char(9) NOT NULL DEFAULT 'rwx------',
+	`creator` varchar(16) NOT NULL,
+	`creation_date` datetime DEFAULT NULL,
+	`last_modify` varchar(16) NOT NULL,
+	`last_modify_date` datetime DEFAULT NULL,
+	`deleted_by` varchar(16) DEFAULT NULL,
+	`deleted_date` datetime NOT NULL DEFAULT '0000-00-00 00:00:00',
+	`father_id` varchar(16) DEFAULT NULL,
+	`name` varchar(255) NOT NULL,
+	`description` text DEFAULT NULL,
+	`fk_obj_id` varchar(16) DEFAULT NULL,
+	PRIMARY KEY (`id`),
+	KEY `rprj_notes_0` (`id`),
+	KEY `rprj_notes_1` (`owner`),
+	KEY `rprj_notes_2` (`group_id`),
+	KEY `rprj_notes_3` (`creator`),
+	KEY `rprj_notes_4` (`last_modify`),
+	KEY `rprj_notes_5` (`deleted_by`),
+	KEY `rprj_notes_6` (`father_id`),
+	KEY `rprj_notes_7` (`fk_obj_id`),
+	KEY `rprj_notes_8` (`fk_obj_id`),
+	KEY `rprj_notes_9` (`fk_obj_id`),
+	KEY `rprj_notes_10` (`fk_obj_id`)
+
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+*/
+type DBNote struct {
+	DBObject
+}
+
+func NewDBNote() *DBNote {
+	columns := []Column{
+		{Name: "fk_obj_id", Type: "varchar(16)", Constraints: []string{"DEFAULT NULL"}},
+	}
+	return &DBNote{
+		DBObject: *NewDBObjectEntity("DBNote", "notes", columns, []ForeignKey{}),
+	}
+}
+func (dbNote *DBNote) NewInstance() DBEntityInterface {
+	return NewDBNote()
+}
+
+/*
+CREATE TABLE `rprj_pages` (
+
+	`id` varchar(16) NOT NULL,
+	`owner` varchar(16) NOT NULL,
+	`group_id` varchar(16) NOT NULL,
+	`permissions` char(9) NOT NULL DEFAULT 'rwx------',
+	`creator` varchar(16) NOT NULL,
+	`creation_date` datetime DEFAULT NULL,
+	`last_modify` varchar(16) NOT NULL,
+	`last_modify_date` datetime DEFAULT NULL,
+	`deleted_by` varchar(16) DEFAULT NULL,
+	`deleted_date` datetime NOT NULL DEFAULT '0000-00-00 00:00:00',
+	`father_id` varchar(16) DEFAULT NULL,
+	`name` varchar(255) NOT NULL,
+	`description` text DEFAULT NULL,
+	`html` text DEFAULT NULL,
+	`fk_obj_id` varchar(16) DEFAULT NULL,
+	`language` varchar(5) DEFAULT 'en_us',
+	PRIMARY KEY (`id`),
+	KEY `rprj_pages_0` (`id`),
+	KEY `rprj_pages_1` (`owner`),
+	KEY `rprj_pages_2` (`group_id`),
+	KEY `rprj_pages_3` (`creator`),
+	KEY `rprj_pages_4` (`last_modify`),
+	KEY `rprj_pages_5` (`deleted_by`),
+	KEY `rprj_pages_6` (`father_id`),
+	KEY `rprj_pages_7` (`fk_obj_id`),
+	KEY `rprj_pages_8` (`fk_obj_id`),
+	KEY `rprj_pages_9` (`fk_obj_id`),
+	KEY `rprj_pages_10` (`fk_obj_id`)
+
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+*/
+type DBPage struct {
+	DBObject
+}
+
+func NewDBPage() *DBPage {
+	columns := []Column{
+		{Name: "html", Type: "text", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "fk_obj_id", Type: "varchar(16)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "language", Type: "varchar(5)", Constraints: []string{"DEFAULT 'en_us'"}},
+	}
+	return &DBPage{
+		DBObject: *NewDBObjectEntity("DBPage", "pages", columns, []ForeignKey{}),
+	}
+}
+func (dbPage *DBPage) NewInstance() DBEntityInterface {
+	return NewDBPage()
+}
+
+/*
+CREATE TABLE `rprj_news` (
+
+	`id` varchar(16) NOT NULL,
+	`owner` varchar(16) NOT NULL,
+	`group_id` varchar(16) NOT NULL,
+	`permissions` char(9) NOT NULL DEFAULT 'rwx------',
+	`creator` varchar(16) NOT NULL,
+	`creation_date` datetime DEFAULT NULL,
+	`last_modify` varchar(16) NOT NULL,
+	`last_modify_date` datetime DEFAULT NULL,
+	`deleted_by` varchar(16) DEFAULT NULL,
+	`deleted_date` datetime NOT NULL DEFAULT '0000-00-00 00:00:00',
+	`father_id` varchar(16) DEFAULT NULL,
+	`name` varchar(255) NOT NULL,
+	`description` text DEFAULT NULL,
+	`html` text DEFAULT NULL,
+	`fk_obj_id` varchar(16) DEFAULT NULL,
+	`language` varchar(5) DEFAULT 'en_us',
+	PRIMARY KEY (`id`),
+	KEY `rprj_news_0` (`id`),
+	KEY `rprj_news_1` (`owner`),
+	KEY `rprj_news_2` (`group_id`),
+	KEY `rprj_news_3` (`creator`),
+	KEY `rprj_news_4` (`last_modify`),
+	KEY `rprj_news_5` (`deleted_by`),
+	KEY `rprj_news_6` (`father_id`),
+	KEY `rprj_news_7` (`fk_obj_id`),
+	KEY `rprj_news_8` (`fk_obj_id`),
+	KEY `rprj_news_9` (`fk_obj_id`),
+	KEY `rprj_news_10` (`fk_obj_id`)
+
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+*/
+type DBNews struct {
+	DBObject
+}
+
+func NewDBNews() *DBNews {
+	columns := []Column{
+		{Name: "html", Type: "text", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "fk_obj_id", Type: "varchar(16)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "language", Type: "varchar(5)", Constraints: []string{"DEFAULT 'en_us'"}},
+	}
+	return &DBNews{
+		DBObject: *NewDBObjectEntity("DBNews", "news", columns, []ForeignKey{}),
+	}
+}
+func (dbNews *DBNews) NewInstance() DBEntityInterface {
+	return NewDBNews()
+}
+
+/** *********************************** CMS: end. *********************************** */
@@ -0,0 +1,210 @@
+package dblayer
+
+/** *********************************** Contacts: start. *********************************** */
+
+/*
+CREATE TABLE `rprj_countrylist` (
+
+	`id` varchar(16) NOT NULL,
+	`Common_Name` varchar(255) DEFAULT NULL,
+	`Formal_Name` varchar(255) DEFAULT NULL,
+	`Type` varchar(255) DEFAULT NULL,
+	`Sub_Type` varchar(255) DEFAULT NULL,
+	`Sovereignty` varchar(255) DEFAULT NULL,
+	`Capital` varchar(255) DEFAULT NULL,
+	`ISO_4217_Currency_Code` varchar(255) DEFAULT NULL,
+	`ISO_4217_Currency_Name` varchar(255) DEFAULT NULL,
+	`ITU_T_Telephone_Code` varchar(255) DEFAULT NULL,
+	`ISO_3166_1_2_Letter_Code` varchar(255) DEFAULT NULL,
+	`ISO_3166_1_3_Letter_Code` varchar(255) DEFAULT NULL,
+	`ISO_3166_1_Number` varchar(255) DEFAULT NULL,
+	`IANA_Country_Code_TLD` varchar(255) DEFAULT NULL,
+	PRIMARY KEY (`id`),
+	KEY `rprj_countrylist_0` (`id`)
+
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+*/
+type DBCountry struct {
+	DBEntity
+}
+
+func NewDBCountry() *DBCountry {
+	columns := []Column{
+		{Name: "id", Type: "varchar(16)", Constraints: []string{"NOT NULL"}},
+		{Name: "Common_Name", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "Formal_Name", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "Type", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "Sub_Type", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "Sovereignty", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "Capital", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "ISO_4217_Currency_Code", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "ISO_4217_Currency_Name", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "ITU_T_Telephone_Code", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "ISO_3166_1_2_Letter_Code", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "ISO_3166_1_3_Letter_Code", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "ISO_3166_1_Number", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "IANA_Country_Code_TLD", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+	}
+	keys := []string{"id"}
+	return &DBCountry{
+		DBEntity: *NewDBEntity(
+			"DBCountry",
+			"countrylist",
+			columns,
+			keys,
+			[]ForeignKey{},
+			make(map[string]any),
+		),
+	}
+}
+func (dbCountry *DBCountry) NewInstance() DBEntityInterface {
+	return NewDBCountry()
+}
+
+/*
+CREATE TABLE `rprj_companies` (
+
+	`id` varchar(16) NOT NULL,
+	`owner` varchar(16) NOT NULL,
+	`group_id` varchar(16) NOT NULL,
+	`permissions` char(9) NOT NULL DEFAULT 'rwx------',
+	`creator` varchar(16) NOT NULL,
+	`creation_date` datetime DEFAULT NULL,
+	`last_modify` varchar(16) NOT NULL,
+	`last_modify_date` datetime DEFAULT NULL,
+	`deleted_by` varchar(16) DEFAULT NULL,
+	`deleted_date` datetime NOT NULL DEFAULT '0000-00-00 00:00:00',
+	`father_id` varchar(16) DEFAULT NULL,
+	`name` varchar(255) NOT NULL,
+	`description` text DEFAULT NULL,
+	`street` varchar(255) DEFAULT NULL,
+	`zip` varchar(255) DEFAULT NULL,
+	`city` varchar(255) DEFAULT NULL,
+	`state` varchar(255) DEFAULT NULL,
+	`fk_countrylist_id` varchar(16) DEFAULT NULL,
+	`phone` varchar(255) DEFAULT NULL,
+	`fax` varchar(255) DEFAULT NULL,
+	`email` varchar(255) DEFAULT NULL,
+	`url` varchar(255) DEFAULT NULL,
+	`p_iva` varchar(16) DEFAULT NULL,
+	PRIMARY KEY (`id`),
+	KEY `rprj_companies_0` (`id`),
+	KEY `rprj_companies_1` (`owner`),
+	KEY `rprj_companies_2` (`group_id`),
+	KEY `rprj_companies_3` (`creator`),
+	KEY `rprj_companies_4` (`last_modify`),
+	KEY `rprj_companies_5` (`deleted_by`),
+	KEY `rprj_companies_6` (`father_id`),
+	KEY `rprj_companies_7` (`fk_countrylist_id`)
+
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+*/
+type DBCompany struct {
+	DBObject
+}
+
+func NewDBCompany() *DBCompany {
+	columns := []Column{
+		{Name: "street", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "zip", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "city", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "state", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "fk_countrylist_id", Type: "varchar(16)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "phone", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "fax", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "email", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "url", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "p_iva", Type: "varchar(16)", Constraints: []string{"DEFAULT NULL"}},
+	}
+	foreignKeys := []ForeignKey{
+		{Column: "fk_countrylist_id", RefTable: "countrylist", RefColumn: "id"},
+	}
+	return &DBCompany{
+		DBObject: *NewDBObjectEntity("DBCompany", "companies", columns, foreignKeys),
+	}
+}
+func (dbCompany *DBCompany) NewInstance() DBEntityInterface {
+	return NewDBCompany()
+}
+
+/*
+CREATE TABLE `rprj_people` (
+
+	`id` varchar(16) NOT NULL,
+	`owner` varchar(16) NOT NULL,
+	`group_id` varchar(16) NOT NULL,
+	`permissions` char(9) NOT NULL DEFAULT 'rwx------',
+	`creator` varchar(16) NOT NULL,
+	`creation_date` datetime DEFAULT NULL,
+	`last_modify` varchar(16) NOT NULL,
+	`last_modify_date` datetime DEFAULT NULL,
+	`deleted_by` varchar(16) DEFAULT NULL,
+	`deleted_date` datetime NOT NULL DEFAULT '0000-00-00 00:00:00',
+	`father_id` varchar(16) DEFAULT NULL,
+	`name` varchar(255) NOT NULL,
+	`description` text DEFAULT NULL,
+	`street` varchar(255) DEFAULT NULL,
+	`zip` varchar(255) DEFAULT NULL,
+	`city` varchar(255) DEFAULT NULL,
+	`state` varchar(255) DEFAULT NULL,
+	`fk_countrylist_id` varchar(16) DEFAULT NULL,
+	`fk_companies_id` varchar(16) DEFAULT NULL,
+	`fk_users_id` varchar(16) DEFAULT NULL,
+	`phone` varchar(255) DEFAULT NULL,
+	`office_phone` varchar(255) DEFAULT NULL,
+	`mobile` varchar(255) DEFAULT NULL,
+	`fax` varchar(255) DEFAULT NULL,
+	`email` varchar(255) DEFAULT NULL,
+	`url` varchar(255) DEFAULT NULL,
+	`codice_fiscale` varchar(20) DEFAULT NULL,
+	`p_iva` varchar(16) DEFAULT NULL,
+	PRIMARY KEY (`id`),
+	KEY `rprj_people_0` (`id`),
+	KEY `rprj_people_1` (`owner`),
+	KEY `rprj_people_2` (`group_id`),
+	KEY `rprj_people_3` (`creator`),
+	KEY `rprj_people_4` (`last_modify`),
+	KEY `rprj_people_5` (`deleted_by`),
+	KEY `rprj_people_6` (`father_id`),
+	KEY `rprj_people_7` (`fk_countrylist_id`),
+	KEY `rprj_people_8` (`fk_companies_id`),
+	KEY `rprj_people_9` (`fk_users_id`)
+
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci;
+*/
+type DBPerson struct {
+	DBObject
+}
+
+func NewDBPerson() *DBPerson {
+	columns := []Column{
+		{Name: "street", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "zip", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "city", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "state", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "fk_countrylist_id", Type: "varchar(16)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "fk_companies_id", Type: "varchar(16)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "fk_users_id", Type: "varchar(16)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "phone", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "office_phone", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "mobile", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "fax", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "email", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "url", Type: "varchar(255)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "codice_fiscale", Type: "varchar(20)", Constraints: []string{"DEFAULT NULL"}},
+		{Name: "p_iva", Type: "varchar(16)", Constraints: []string{"DEFAULT NULL"}},
+	}
+	foreignKeys := []ForeignKey{
+		{Column: "fk_countrylist_id", RefTable: "countrylist", RefColumn: "id"},
+		{Column: "fk_companies_id", RefTable: "companies", RefColumn: "id"},
+		{Column: "fk_users_id", RefTable: "users", RefColumn: "id"},
+	}
+	return &DBPerson{
+		DBObject: *NewDBObjectEntity("DBPerson", "people", columns, foreignKeys),
+	}
+}
+func (dbPerson *DBPerson) NewInstance() DBEntityInterface {
+	return NewDBPerson()
+}
+
+/** *********************************** Contacts: end. *********************************** */
@@ -0,0 +1,377 @@
+package dblayer
+
+import (
+	"database/sql"
+	"html"
+	"log"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+/*
+Full text search of the DBObjects.
+
+The text of each object is copied in the search_index table (see DBSearchIndex)
+by the hooks of DBObject, in the same transaction of the write,
+and searched with the FULLTEXT keys of MariaDB.
+*/
+
+/*
+The columns indexed for each class, besides name: description by default
+*/
+var SearchColumns = map[string][]string{
+	"DBPage":    {"description", "html"},
+	"DBNews":    {"description", "html"},
+	"DBNote":    {"description"},
+	"DBEvent":   {"description", "category"},
+	"DBFile":    {"description", "filename"},
+	"DBLink":    {"description", "href"},
+	"DBCompany": {"description", "street", "zip", "city", "state", "phone", "fax", "email", "url", "p_iva"},
+	"DBPerson": {"description", "street", "zip", "city", "state", "phone", "office_phone", "mobile",
+		"fax", "email", "url", "codice_fiscale", "p_iva"},
+}
+
+var defaultSearchColumns = []string{"description"}
+
+// Columns containing HTML: the tags are removed before indexing
+var htmlColumns = map[string]bool{"html": true}
+
+// Shorter words are not indexed by InnoDB (innodb_ft_min_token_size)
+var MinSearchTermLength = 3
+
+var (
+	htmlBlockRegexp  = regexp.MustCompile(`(?is)<(script|style)[^>]*>.*?</(script|style)>`)
+	htmlTagRegexp    = regexp.MustCompile(`(?s)<[^>]*>`)
+	whitespaceRegexp = regexp.MustCompile(`\s+`)
+)
+
+/*
+Returns the text of an HTML fragment: tags, scripts and styles removed, entities decoded
+*/
+func StripHTML(s string) string {
+	s = htmlBlockRegexp.ReplaceAllString(s, " ")
+	s = htmlTagRegexp.ReplaceAllString(s, " ")
+	s = html.UnescapeString(s)
+	return strings.TrimSpace(whitespaceRegexp.ReplaceAllString(s, " "))
+}
+
+/*
+Returns the text to index of the object: its searchable columns, one per line
+*/
+func searchBody(dbe DBEntityInterface) string {
+	columns, exists := SearchColumns[dbe.GetTypeName()]
+	if !exists {
+		columns = defaultSearchColumns
+	}
+	parts := make([]string, 0, len(columns))
+	for _, col := range columns {
+		value := dbe.GetValue(col)
+		if htmlColumns[col] {
+			value = StripHTML(value)
+		}
+		if value != "" {
+			parts = append(parts, value)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+/*
+Splits the query in lowercase words, without duplicates
+*/
+func SearchTerms(q string) []string {
+	ret := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(q), func(r rune) bool { return !isWordRune(r) }) {
+		if !seen[word] {
+			seen[word] = true
+			ret = append(ret, word)
+		}
+	}
+	return ret
+}
+
+/*
+Builds the query in boolean mode: all the words are required, as prefixes
+*/
+func booleanQuery(terms []string) string {
+	parts := make([]string, 0, len(terms))
+	for _, term := range terms {
+		if len([]rune(term)) >= MinSearchTermLength {
+			parts = append(parts, "+"+term+"*")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Returns the length of the word starting at i if it starts with one of the terms, 0 otherwise
+func matchTerm(lower []rune, i int, terms [][]rune) int {
+	if i > 0 && isWordRune(lower[i-1]) {
+		return 0
+	}
+	for _, term := range terms {
+		if len(term) == 0 || i+len(term) > len(lower) || string(lower[i:i+len(term)]) != string(term) {
+			continue
+		}
+		end := i + len(term)
+		for end < len(lower) && isWordRune(lower[end]) {
+			end++
+		}
+		return end - i
+	}
+	return 0
+}
+
+/*
+Returns about width chars of the text around the first match of the terms,
+HTML escaped, with the words starting with the terms in <mark></mark>
+*/
+func Snippet(text string, terms []string, width int) string {
+	runes := []rune(text)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	lowerTerms := make([][]rune, 0, len(terms))
+	for _, term := range terms {
+		lowerTerms = append(lowerTerms, []rune(strings.ToLower(term)))
+	}
+
+	// The window around the first match
+	first := 0
+	for i := range lower {
+		if matchTerm(lower, i, lowerTerms) > 0 {
+			first = i
+			break
+		}
+	}
+	start := first - width/3
+	if start < 0 {
+		start = 0
+	}
+	for start > 0 && start < first && isWordRune(lower[start-1]) {
+		start++
+	}
+	end := start + width
+	if end > len(runes) {
+		end = len(runes)
+	}
+	for end < len(runes) && end > first && isWordRune(lower[end]) {
+		end--
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	plain := start
+	for i := start; i < end; {
+		n := matchTerm(lower, i, lowerTerms)
+		if n == 0 {
+			i++
+			continue
+		}
+		if i+n > end {
+			n = end - i
+		}
+		b.WriteString(html.EscapeString(string(runes[plain:i])))
+		b.WriteString("<mark>" + html.EscapeString(string(runes[i:i+n])) + "</mark>")
+		i += n
+		plain = i
+	}
+	b.WriteString(html.EscapeString(string(runes[plain:end])))
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+/*
+Writes the object in the search index, reading it from its table in the transaction:
+the values of dbe can be partial, ie. in an Update.
+If the object no longer exists (purged) it is removed from the index.
+Does nothing if DBSearchIndex is not registered in the factory.
+*/
+func (dbr *DBRepository) updateSearchIndex(tx *sql.Tx, dbe DBEntityInterface) error {
+	index := dbr.factory.GetInstanceByClassName("DBSearchIndex")
+	object := dbr.factory.GetInstanceByClassName(dbe.GetTypeName())
+	if index == nil || object == nil {
+		return nil
+	}
+	id := dbe.GetValue("id")
+
+	rows, err := tx.QueryContext(dbr.Context(), "SELECT * FROM "+dbr.buildTableName(object)+" WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	stored, err := dbr.scanRows(rows, object.NewInstance)
+	rows.Close()
+	if err != nil {
+		return err
+	}
+	if len(stored) == 0 {
+		_, err := tx.ExecContext(dbr.Context(), "DELETE FROM "+dbr.buildTableName(index)+" WHERE id = ?", id)
+		return err
+	}
+	return dbr.writeSearchIndex(tx, index, stored[0])
+}
+
+func (dbr *DBRepository) writeSearchIndex(tx *sql.Tx, index DBEntityInterface, object DBEntityInterface) error {
+	_, err := tx.ExecContext(dbr.Context(),
+		"REPLACE INTO "+dbr.buildTableName(index)+
+			" (id, classname, owner, group_id, permissions, deleted_date, last_modify_date, name, body) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		object.GetValue("id"), object.GetTypeName(),
+		object.GetValue("owner"), object.GetValue("group_id"), object.GetValue("permissions"),
+		object.getValueToDB("deleted_date"), object.getValueToDB("last_modify_date"),
+		object.GetValue("name"), searchBody(object),
+	)
+	if err != nil {
+		log.Print("DBRepository::writeSearchIndex: Exec error:", err)
+	}
+	return err
+}
+
+/*
+Rebuilds the search index from the tables of all the DBObject classes
+*/
+func (dbr *DBRepository) RebuildSearchIndex(tx *sql.Tx) (int, error) {
+	index := dbr.factory.GetInstanceByClassName("DBSearchIndex")
+	if index == nil {
+		return 0, nil
+	}
+	if _, err := tx.ExecContext(dbr.Context(), "DELETE FROM "+dbr.buildTableName(index)); err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, className := range dbr.factory.GetAllDBObjectClassNames() {
+		object := dbr.factory.GetInstanceByClassName(className)
+		rows, err := tx.QueryContext(dbr.Context(), "SELECT * FROM "+dbr.buildTableName(object))
+		if err != nil {
+			return count, err
+		}
+		objects, err := dbr.scanRows(rows, object.NewInstance)
+		rows.Close()
+		if err != nil {
+			return count, err
+		}
+		for _, obj := range objects {
+			if err := dbr.writeSearchIndex(tx, index, obj); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+	return count, nil
+}
+
+/*
+A result of the full text search: Snippet is HTML, with the matches in <mark></mark>
+*/
+type SearchHit struct {
+	ID        string  `json:"id"`
+	ClassName string  `json:"classname"`
+	Name      string  `json:"name"`
+	Snippet   string  `json:"snippet"`
+	Score     float64 `json:"score"`
+}
+
+/*
+A page of the results, the most relevant first. Facets are the number of results per class,
+also when the search is limited to a class.
+*/
+type SearchResults struct {
+	Items  []SearchHit    `json:"items"`
+	Total  int            `json:"total"`
+	Facets map[string]int `json:"facets"`
+}
+
+// Length of the snippets, in chars
+var SnippetWidth = 160
+
+/*
+Full text search of the objects the user can read, optionally of a single class.
+The matches in the name weigh more than the ones in the body.
+*/
+func (dbr *DBRepository) FullTextSearch(q string, className string, page PageRequest) (*SearchResults, error) {
+	page = page.Normalize()
+	index := dbr.factory.GetInstanceByClassName("DBSearchIndex")
+	if index == nil {
+		return nil, &ValidationError{Message: "the search index is not available"}
+	}
+	terms := SearchTerms(q)
+	against := booleanQuery(terms)
+	if against == "" {
+		return nil, &ValidationError{Column: "q", Message: "no words to search"}
+	}
+
+	permClause, permArgs := dbr.buildReadPermissionClause()
+	where := "MATCH(name, body) AGAINST (? IN BOOLEAN MODE) AND " + permClause
+	args := append([]interface{}{against}, permArgs...)
+	if !dbr.IncludeDeleted {
+		where += " AND " + buildDeletedClause(false)
+	}
+	table := dbr.buildTableName(index)
+
+	// Facets
+	rows, err := dbr.DbConnection.QueryContext(dbr.Context(), "SELECT classname, COUNT(*) FROM "+table+" WHERE "+where+" GROUP BY classname", args...)
+	if err != nil {
+		log.Print("DBRepository::FullTextSearch: Query error:", err)
+		return nil, err
+	}
+	ret := &SearchResults{Items: []SearchHit{}, Facets: make(map[string]int)}
+	for rows.Next() {
+		var name string
+		var count int
+		if err := rows.Scan(&name, &count); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ret.Facets[name] = count
+		if className == "" || className == name {
+			ret.Total += count
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if className != "" {
+		where += " AND classname = ?"
+		args = append(args, className)
+	}
+	query := "SELECT id, classname, name, body, " +
+		"2 * MATCH(name) AGAINST (? IN BOOLEAN MODE) + MATCH(name, body) AGAINST (? IN BOOLEAN MODE) AS score" +
+		" FROM " + table + " WHERE " + where + " ORDER BY score DESC, id ASC LIMIT ? OFFSET ?"
+	args = append([]interface{}{against, against}, args...)
+	args = append(args, page.PageSize, (page.Page-1)*page.PageSize)
+	if dbr.Verbose {
+		log.Print("DBRepository::FullTextSearch: query=", query, " args=", args)
+	}
+
+	rows, err = dbr.DbConnection.QueryContext(dbr.Context(), query, args...)
+	if err != nil {
+		log.Print("DBRepository::FullTextSearch: Query error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hit SearchHit
+		var body sql.NullString
+		if err := rows.Scan(&hit.ID, &hit.ClassName, &hit.Name, &body, &hit.Score); err != nil {
+			return nil, err
+		}
+		text := body.String
+		if text == "" {
+			text = hit.Name
+		}
+		hit.Snippet = Snippet(text, terms, SnippetWidth)
+		ret.Items = append(ret.Items, hit)
+	}
+	return ret, rows.Err()
+}
@@ -0,0 +1,46 @@
+package dblayer
+
+import (
+	"testing"
+)
+
+func TestStripHTML(t *testing.T) {
+	got := StripHTML(`<h1>Title</h1><script>alert("x")</script><p>Caf&eacute; &amp; <b>bar</b></p><style>p{}</style>`)
+	if got != "Title Café & bar" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestSearchBody(t *testing.T) {
+	page := NewDBPage()
+	page.SetValue("name", "Home")
+	page.SetValue("description", "The home page")
+	page.SetValue("html", "<p>Welcome <i>home</i></p>")
+	if got := searchBody(page); got != "The home page\nWelcome home" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestSearchTerms(t *testing.T) {
+	terms := SearchTerms("Meeting, meeting! +Roma* a")
+	if len(terms) != 3 || terms[0] != "meeting" || terms[1] != "roma" || terms[2] != "a" {
+		t.Errorf("got %v", terms)
+	}
+	if got := booleanQuery(terms); got != "+meeting* +roma*" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	got := Snippet("The <team> meeting is in Roma, meetings every week", []string{"meet"}, 200)
+	expected := "The &lt;team&gt; <mark>meeting</mark> is in Roma, <mark>meetings</mark> every week"
+	if got != expected {
+		t.Errorf("got %q", got)
+	}
+
+	text := "Lorem ipsum dolor sit amet, consectetur adipiscing elit. Sed do eiusmod tempor incididunt ut labore et dolore magna aliqua. Ut enim ad minim veniam."
+	got = Snippet(text, []string{"labore"}, 40)
+	if got != "…ut <mark>labore</mark> et dolore magna aliqua. Ut…" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,237 @@
+package dblayer
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+/*
+Navigation of the tree of the DBObjects: every object can be the child of another one,
+of any class, through father_id. The roots have no father_id.
+The children of a folder are sorted by its childs_sort_order, see SortChildren.
+Only the objects the user can read are returned: the anonymous user sees the public ones.
+*/
+
+var ErrTreeCycle = errors.New("cycle in the father_id hierarchy")
+
+// Maximum depth of GetSubtree
+var MaxTreeDepth = 10
+
+/*
+A node of a subtree: the object (a lightweight DBObject, see SearchObjects) with its children
+*/
+type TreeNode struct {
+	Object   *DBObject   `json:"object"`
+	Children []*TreeNode `json:"children"`
+}
+
+/*
+Sorts the children as listed in sortOrder, a comma separated list of ids
+(the childs_sort_order of the folders); the children not listed follow, by name.
+*/
+func SortChildren(children []*DBObject, sortOrder string) {
+	position := make(map[string]int)
+	for i, id := range strings.Split(sortOrder, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			if _, exists := position[id]; !exists {
+				position[id] = i
+			}
+		}
+	}
+	sort.SliceStable(children, func(i, j int) bool {
+		pi, listedI := position[children[i].GetValue("id")]
+		pj, listedJ := position[children[j].GetValue("id")]
+		switch {
+		case listedI && listedJ:
+			return pi < pj
+		case listedI != listedJ:
+			return listedI
+		}
+		return strings.ToLower(children[i].GetValue("name")) < strings.ToLower(children[j].GetValue("name"))
+	})
+}
+
+/*
+Returns the object, nil if it does not exist or the user cannot read it
+*/
+func (dbr *DBRepository) getTreeObject(id string) (*DBObject, error) {
+	criteria := NewDBObject()
+	criteria.SetValue("id", id)
+	objects, err := dbr.SearchObjects(criteria, false, false, "")
+	if err != nil || len(objects) == 0 {
+		return nil, err
+	}
+	return objects[0], nil
+}
+
+/*
+Returns the childs_sort_order of the folders among the given ids
+*/
+func (dbr *DBRepository) childsSortOrders(ids []string) (map[string]string, error) {
+	ret := make(map[string]string)
+	folder := dbr.factory.GetInstanceByClassName("DBFolder")
+	if folder == nil || len(ids) == 0 {
+		return ret, nil
+	}
+	values := make([]any, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, id)
+	}
+	folders, err := dbr.Search(folder.NewInstance(), false, false, "", In("id", values...))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range folders {
+		ret[f.GetValue("id")] = f.GetValue("childs_sort_order")
+	}
+	return ret, nil
+}
+
+/*
+Returns the children of the given objects, per father id, sorted (see SortChildren)
+*/
+func (dbr *DBRepository) childrenOf(ids []string) (map[string][]*DBObject, error) {
+	ret := make(map[string][]*DBObject)
+	if len(ids) == 0 {
+		return ret, nil
+	}
+	values := make([]any, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, id)
+	}
+	children, err := dbr.SearchObjects(NewDBObject(), false, false, "name", In("father_id", values...))
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range children {
+		fatherID := child.GetValue("father_id")
+		ret[fatherID] = append(ret[fatherID], child)
+	}
+	sortOrders, err := dbr.childsSortOrders(ids)
+	if err != nil {
+		return nil, err
+	}
+	for fatherID := range ret {
+		SortChildren(ret[fatherID], sortOrders[fatherID])
+	}
+	return ret, nil
+}
+
+/*
+Returns the objects without father, by name
+*/
+func (dbr *DBRepository) GetRootObjects() ([]*DBObject, error) {
+	return dbr.SearchObjects(NewDBObject(), false, false, "name", Or(IsNull("father_id"), Eq("father_id", "")))
+}
+
+/*
+Returns the children of the object; nil if the object does not exist or the user cannot read it
+*/
+func (dbr *DBRepository) GetChildren(id string) ([]*DBObject, error) {
+	father, err := dbr.getTreeObject(id)
+	if err != nil || father == nil {
+		return nil, err
+	}
+	children, err := dbr.childrenOf([]string{id})
+	if err != nil {
+		return nil, err
+	}
+	if children[id] == nil {
+		return []*DBObject{}, nil
+	}
+	return children[id], nil
+}
+
+/*
+Returns the path from the root to the object, the object included.
+The path starts from the first ancestor the user can read.
+Returns nil if the object does not exist or the user cannot read it,
+ErrTreeCycle if an object is its own ancestor.
+*/
+func (dbr *DBRepository) GetBreadcrumbs(id string) ([]*DBObject, error) {
+	return breadcrumbs(id, dbr.getTreeObject)
+}
+
+// See GetBreadcrumbs: getObject returns the object, nil if not readable
+func breadcrumbs(id string, getObject func(id string) (*DBObject, error)) ([]*DBObject, error) {
+	path := make([]*DBObject, 0)
+	visited := make(map[string]bool)
+	for id != "" {
+		if visited[id] {
+			log.Printf("DBRepository::GetBreadcrumbs: cycle at %s", id)
+			return nil, fmt.Errorf("%w: object %s", ErrTreeCycle, id)
+		}
+		visited[id] = true
+		obj, err := getObject(id)
+		if err != nil {
+			return nil, err
+		}
+		if obj == nil {
+			break
+		}
+		path = append(path, obj)
+		id = obj.GetValue("father_id")
+	}
+	if len(path) == 0 {
+		return nil, nil
+	}
+	// Root first
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, nil
+}
+
+/*
+Returns the object with its descendants, up to depth levels (at most MaxTreeDepth).
+The objects already in the subtree are not repeated: a cycle is logged and cut.
+Returns nil if the object does not exist or the user cannot read it.
+*/
+func (dbr *DBRepository) GetSubtree(id string, depth int) (*TreeNode, error) {
+	if depth > MaxTreeDepth {
+		depth = MaxTreeDepth
+	}
+	obj, err := dbr.getTreeObject(id)
+	if err != nil || obj == nil {
+		return nil, err
+	}
+	return subtree(obj, depth, dbr.childrenOf)
+}
+
+// See GetSubtree: childrenOf returns the readable children of the given ids, per father id
+func subtree(obj *DBObject, depth int, childrenOf func(ids []string) (map[string][]*DBObject, error)) (*TreeNode, error) {
+	id := obj.GetValue("id")
+	root := &TreeNode{Object: obj, Children: []*TreeNode{}}
+	visited := map[string]bool{id: true}
+	level := map[string]*TreeNode{id: root}
+	for d := 0; d < depth && len(level) > 0; d++ {
+		ids := make([]string, 0, len(level))
+		for fatherID := range level {
+			ids = append(ids, fatherID)
+		}
+		sort.Strings(ids)
+		children, err := childrenOf(ids)
+		if err != nil {
+			return nil, err
+		}
+		next := make(map[string]*TreeNode)
+		for _, fatherID := range ids {
+			for _, child := range children[fatherID] {
+				childID := child.GetValue("id")
+				if visited[childID] {
+					log.Printf("DBRepository::GetSubtree: cycle at %s, child of %s", childID, fatherID)
+					continue
+				}
+				visited[childID] = true
+				node := &TreeNode{Object: child, Children: []*TreeNode{}}
+				level[fatherID].Children = append(level[fatherID].Children, node)
+				next[childID] = node
+			}
+		}
+		level = next
+	}
+	return root, nil
+}
@@ -0,0 +1,120 @@
+package dblayer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// An in-memory tree: "id:father_id" items
+func testTree(items ...string) map[string]*DBObject {
+	objects := make(map[string]*DBObject)
+	for _, item := range items {
+		parts := strings.Split(item, ":")
+		obj := NewDBObject()
+		obj.SetValue("id", parts[0])
+		obj.SetValue("name", parts[0])
+		obj.SetValue("father_id", parts[1])
+		objects[parts[0]] = obj
+	}
+	return objects
+}
+
+func treeGetter(objects map[string]*DBObject) func(id string) (*DBObject, error) {
+	return func(id string) (*DBObject, error) { return objects[id], nil }
+}
+
+func treeChildren(objects map[string]*DBObject) func(ids []string) (map[string][]*DBObject, error) {
+	return func(ids []string) (map[string][]*DBObject, error) {
+		ret := make(map[string][]*DBObject)
+		for _, id := range ids {
+			for _, obj := range objects {
+				if obj.GetValue("father_id") == id {
+					ret[id] = append(ret[id], obj)
+				}
+			}
+			SortChildren(ret[id], "")
+		}
+		return ret, nil
+	}
+}
+
+func TestSortChildren(t *testing.T) {
+	children := make([]*DBObject, 0)
+	for _, idName := range []string{"1:delta", "2:Alpha", "3:charlie", "4:bravo"} {
+		parts := strings.Split(idName, ":")
+		child := NewDBObject()
+		child.SetValue("id", parts[0])
+		child.SetValue("name", parts[1])
+		children = append(children, child)
+	}
+
+	SortChildren(children, "3, 1,99")
+	ids := make([]string, 0)
+	for _, child := range children {
+		ids = append(ids, child.GetValue("id"))
+	}
+	if got := strings.Join(ids, ","); got != "3,1,2,4" {
+		t.Errorf("got %s", got)
+	}
+
+	SortChildren(children, "")
+	ids = ids[:0]
+	for _, child := range children {
+		ids = append(ids, child.GetValue("id"))
+	}
+	if got := strings.Join(ids, ","); got != "2,4,3,1" {
+		t.Errorf("without sort order: got %s", got)
+	}
+}
+
+func TestBreadcrumbs(t *testing.T) {
+	objects := testTree("root:", "a:root", "b:a")
+	path, err := breadcrumbs("b", treeGetter(objects))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, 0)
+	for _, obj := range path {
+		ids = append(ids, obj.GetValue("id"))
+	}
+	if got := strings.Join(ids, ","); got != "root,a,b" {
+		t.Errorf("got %s", got)
+	}
+
+	// a -> b -> c -> a
+	cyclic := testTree("a:c", "b:a", "c:b")
+	if _, err := breadcrumbs("b", treeGetter(cyclic)); !errors.Is(err, ErrTreeCycle) {
+		t.Errorf("expected ErrTreeCycle, got %v", err)
+	}
+	// An object that is its own father
+	if _, err := breadcrumbs("x", treeGetter(testTree("x:x"))); !errors.Is(err, ErrTreeCycle) {
+		t.Errorf("expected ErrTreeCycle, got %v", err)
+	}
+}
+
+func TestSubtreeCycle(t *testing.T) {
+	// a -> b -> c -> a, with d child of c
+	objects := testTree("a:c", "b:a", "c:b", "d:c")
+	root, err := subtree(objects["a"], MaxTreeDepth, treeChildren(objects))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every object once, the cycle cut at a
+	count := 0
+	var walk func(node *TreeNode, path string)
+	walk = func(node *TreeNode, path string) {
+		count++
+		path += "/" + node.Object.GetValue("id")
+		if len(node.Children) == 0 && path != "/a/b/c/d" {
+			t.Errorf("unexpected leaf %s", path)
+		}
+		for _, child := range node.Children {
+			walk(child, path)
+		}
+	}
+	walk(root, "")
+	if count != 4 {
+		t.Errorf("expected 4 nodes, got %d", count)
+	}
+}
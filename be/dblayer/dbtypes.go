@@ -0,0 +1,305 @@
+package dblayer
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Kind of value stored in a column, derived from Column.Type
+*/
+type ColumnKind int
+
+const (
+	KindString ColumnKind = iota
+	KindInt
+	KindFloat
+	KindDateTime
+	KindDate
+	KindTime
+	KindFlag // char(1): '0'/'1' flags, but also one char codes
+)
+
+const DateFormat = "2006-01-02"
+const TimeFormat = "15:04:05"
+const ZeroDate = "0000-00-00"
+
+/*
+Returns the kind of a sql column type, ie. int(11) -> KindInt
+*/
+func GetColumnKind(columnType string) ColumnKind {
+	t := strings.ToLower(columnType)
+	switch {
+	case strings.HasPrefix(t, "int"), strings.HasPrefix(t, "bigint"), strings.HasPrefix(t, "smallint"),
+		strings.HasPrefix(t, "tinyint"), strings.HasPrefix(t, "mediumint"):
+		return KindInt
+	case strings.HasPrefix(t, "float"), strings.HasPrefix(t, "double"), strings.HasPrefix(t, "decimal"):
+		return KindFloat
+	case t == "datetime" || strings.HasPrefix(t, "timestamp"):
+		return KindDateTime
+	case t == "date":
+		return KindDate
+	case t == "time":
+		return KindTime
+	case t == "char(1)":
+		return KindFlag
+	}
+	return KindString
+}
+
+func (dbEntity *DBEntity) GetColumnKind(columnName string) ColumnKind {
+	return GetColumnKind(dbEntity.GetColumnType(columnName))
+}
+
+/*
+Converts a value read from the db (usually []byte) to the type of the column.
+NULL is kept as nil. Values that cannot be converted are kept as strings.
+*/
+func convertFromDB(kind ColumnKind, raw any) any {
+	if raw == nil {
+		return nil
+	}
+	var s string
+	switch v := raw.(type) {
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case time.Time:
+		return v
+	case int64:
+		if kind == KindFloat {
+			return float64(v)
+		}
+		if kind == KindInt {
+			return v
+		}
+		s = strconv.FormatInt(v, 10)
+	case float64:
+		if kind == KindFloat {
+			return v
+		}
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		s = fmt.Sprint(v)
+	}
+
+	switch kind {
+	case KindInt:
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i
+		}
+	case KindFloat:
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	case KindDateTime, KindDate, KindTime:
+		if t, ok := parseTime(kind, s); ok {
+			return t
+		}
+	}
+	return s
+}
+
+/*
+Parses a datetime, date or time string as written by the db.
+Zero dates are returned as the zero time.Time.
+*/
+func parseTime(kind ColumnKind, s string) (time.Time, bool) {
+	if s == ZeroDateTime || s == ZeroDate {
+		return time.Time{}, true
+	}
+	layouts := []string{DateTimeFormat, DateFormat, time.RFC3339, TimeFormat}
+	switch kind {
+	case KindDate:
+		layouts = []string{DateFormat, DateTimeFormat, time.RFC3339}
+	case KindTime:
+		layouts = []string{TimeFormat}
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+/*
+Converts a value of the dictionary to the value to write in the db
+*/
+func convertToDB(kind ColumnKind, value any) any {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		return formatTime(kind, v)
+	case bool:
+		if kind == KindInt {
+			if v {
+				return 1
+			}
+			return 0
+		}
+		if v {
+			return "1"
+		}
+		return "0"
+	}
+	return value
+}
+
+func formatTime(kind ColumnKind, t time.Time) string {
+	switch kind {
+	case KindDate:
+		if t.IsZero() {
+			return ZeroDate
+		}
+		return t.Format(DateFormat)
+	case KindTime:
+		return t.Format(TimeFormat)
+	}
+	if t.IsZero() {
+		return ZeroDateTime
+	}
+	return t.Format(DateTimeFormat)
+}
+
+/*
+String representation of a value of the dictionary.
+NULL is the empty string: use IsNull to distinguish it.
+*/
+func formatValue(kind ColumnKind, value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return formatTime(kind, v)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+func (dbEntity *DBEntity) setValueFromDB(columnName string, raw any) {
+	dbEntity.dictionary[columnName] = convertFromDB(dbEntity.GetColumnKind(columnName), raw)
+}
+func (dbEntity *DBEntity) getValueToDB(columnName string) any {
+	return convertToDB(dbEntity.GetColumnKind(columnName), dbEntity.dictionary[columnName])
+}
+
+/*
+Typed setters
+*/
+func (dbEntity *DBEntity) SetInt(columnName string, value int64) {
+	dbEntity.dictionary[columnName] = value
+}
+func (dbEntity *DBEntity) SetFloat(columnName string, value float64) {
+	dbEntity.dictionary[columnName] = value
+}
+func (dbEntity *DBEntity) SetTime(columnName string, value time.Time) {
+	dbEntity.dictionary[columnName] = value
+}
+func (dbEntity *DBEntity) SetBool(columnName string, value bool) {
+	dbEntity.dictionary[columnName] = value
+}
+
+/*
+Sets the column to NULL
+*/
+func (dbEntity *DBEntity) SetNull(columnName string) {
+	dbEntity.dictionary[columnName] = nil
+}
+
+/*
+Returns true if the column has been set to NULL (or read as NULL from the db)
+*/
+func (dbEntity *DBEntity) IsNull(columnName string) bool {
+	val, exists := dbEntity.dictionary[columnName]
+	return exists && val == nil
+}
+
+/*
+Typed getters: the value is converted if stored as a string.
+The Null variants return Valid=false if the value is not set, NULL or not convertible.
+*/
+func (dbEntity *DBEntity) GetNullInt(columnName string) sql.NullInt64 {
+	if v, ok := dbEntity.dictionary[columnName].(bool); ok {
+		if v {
+			return sql.NullInt64{Int64: 1, Valid: true}
+		}
+		return sql.NullInt64{Int64: 0, Valid: true}
+	}
+	if v, ok := convertFromDB(KindInt, dbEntity.dictionary[columnName]).(int64); ok {
+		return sql.NullInt64{Int64: v, Valid: true}
+	}
+	return sql.NullInt64{}
+}
+func (dbEntity *DBEntity) GetInt(columnName string) int64 {
+	return dbEntity.GetNullInt(columnName).Int64
+}
+
+func (dbEntity *DBEntity) GetNullFloat(columnName string) sql.NullFloat64 {
+	if v, ok := convertFromDB(KindFloat, dbEntity.dictionary[columnName]).(float64); ok {
+		return sql.NullFloat64{Float64: v, Valid: true}
+	}
+	return sql.NullFloat64{}
+}
+func (dbEntity *DBEntity) GetFloat(columnName string) float64 {
+	return dbEntity.GetNullFloat(columnName).Float64
+}
+
+func (dbEntity *DBEntity) GetNullTime(columnName string) sql.NullTime {
+	kind := dbEntity.GetColumnKind(columnName)
+	if kind != KindDate && kind != KindTime {
+		kind = KindDateTime
+	}
+	if v, ok := convertFromDB(kind, dbEntity.dictionary[columnName]).(time.Time); ok {
+		return sql.NullTime{Time: v, Valid: true}
+	}
+	return sql.NullTime{}
+}
+func (dbEntity *DBEntity) GetTime(columnName string) time.Time {
+	return dbEntity.GetNullTime(columnName).Time
+}
+
+/*
+Flags: '1', 'y', 't' (and 'true', 'yes') are true; '0', 'n', 'f', ” are false
+*/
+func (dbEntity *DBEntity) GetNullBool(columnName string) sql.NullBool {
+	switch v := dbEntity.dictionary[columnName].(type) {
+	case nil:
+		return sql.NullBool{}
+	case bool:
+		return sql.NullBool{Bool: v, Valid: true}
+	case int64:
+		return sql.NullBool{Bool: v != 0, Valid: true}
+	}
+	switch strings.ToLower(strings.TrimSpace(dbEntity.GetValue(columnName))) {
+	case "1", "y", "t", "yes", "true", "s":
+		return sql.NullBool{Bool: true, Valid: true}
+	case "0", "n", "f", "no", "false", "":
+		return sql.NullBool{Bool: false, Valid: true}
+	}
+	return sql.NullBool{}
+}
+func (dbEntity *DBEntity) GetBool(columnName string) bool {
+	return dbEntity.GetNullBool(columnName).Bool
+}
+
+func (dbEntity *DBEntity) GetNullString(columnName string) sql.NullString {
+	val, exists := dbEntity.dictionary[columnName]
+	if !exists || val == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: dbEntity.GetValue(columnName), Valid: true}
+}
@@ -0,0 +1,68 @@
+package dblayer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestColumnKind(t *testing.T) {
+	cases := map[string]ColumnKind{
+		"int(11)":      KindInt,
+		"float":        KindFloat,
+		"datetime":     KindDateTime,
+		"date":         KindDate,
+		"time":         KindTime,
+		"char(1)":      KindFlag,
+		"varchar(255)": KindString,
+		"text":         KindString,
+	}
+	for columnType, expected := range cases {
+		if kind := GetColumnKind(columnType); kind != expected {
+			t.Errorf("%s: got %v, want %v", columnType, kind, expected)
+		}
+	}
+}
+
+/*
+Values read from the db are converted to the column type and written back unchanged
+*/
+func TestTypedValuesRoundTrip(t *testing.T) {
+	event := NewDBEvent()
+	event.setValueFromDB("start_date", []byte("2025-03-01 09:30:00"))
+	event.setValueFromDB("end_date", []byte(ZeroDateTime))
+	event.setValueFromDB("alarm_minute", int64(15))
+	event.setValueFromDB("all_day", []byte("1"))
+	event.setValueFromDB("url", nil)
+
+	start := event.GetTime("start_date")
+	if start.Year() != 2025 || start.Month() != time.March || start.Hour() != 9 || start.Minute() != 30 {
+		t.Errorf("start_date: got %v", start)
+	}
+	if event.getValueToDB("start_date") != "2025-03-01 09:30:00" {
+		t.Errorf("start_date to db: got %v", event.getValueToDB("start_date"))
+	}
+	if !event.GetTime("end_date").IsZero() || event.GetValue("end_date") != ZeroDateTime {
+		t.Errorf("end_date: got %v", event.GetValue("end_date"))
+	}
+	if event.GetInt("alarm_minute") != 15 {
+		t.Errorf("alarm_minute: got %d", event.GetInt("alarm_minute"))
+	}
+	if !event.GetBool("all_day") {
+		t.Error("all_day must be true")
+	}
+	if !event.IsNull("url") || event.GetNullString("url").Valid || event.GetValue("url") != "" {
+		t.Error("url must be NULL")
+	}
+
+	event.SetBool("all_day", false)
+	if event.getValueToDB("all_day") != "0" {
+		t.Errorf("all_day to db: got %v", event.getValueToDB("all_day"))
+	}
+	event.SetValue("alarm_minute", "30")
+	if event.GetInt("alarm_minute") != 30 {
+		t.Errorf("alarm_minute from string: got %d", event.GetInt("alarm_minute"))
+	}
+	if event.GetNullInt("name").Valid {
+		t.Error("unset value must not be valid")
+	}
+}
@@ -6,6 +6,8 @@ require (
 	github.com/go-sql-driver/mysql v1.9.3
 	github.com/golang-jwt/jwt/v5 v5.3.0
 	github.com/gorilla/mux v1.8.1
+	golang.org/x/crypto v0.54.0
+	golang.org/x/image v0.25.0
 )
 
 require filippo.io/edwards25519 v1.1.0 // indirect
@@ -14,6 +14,43 @@ curl -X GET http://localhost:1971/users/316 \
 curl -X GET http://localhost:1971/users/ \
   -H "Authorization: Bearer <access_token>"
 
+curl -X GET "http://localhost:1971/users?order_by=login&page_size=20&after=<next_cursor>" \
+  -H "Authorization: Bearer <access_token>"
+
+curl -X GET "http://localhost:1971/search?q=meeting&class=DBNote" \
+  -H "Authorization: Bearer <access_token>"
+
+# Anonimo: solo gli oggetti pubblici (permissions ______r__)
+curl -X GET http://localhost:1971/nav/root
+
+curl -X POST http://localhost:1971/files \
+  -H "Authorization: Bearer <access_token>" \
+  -F "file=@contract.pdf" -F "fk_obj_id=<company_id>" -F "description=Contract 2025"
+
+curl -X GET http://localhost:1971/files/<id>/content \
+  -H "Authorization: Bearer <access_token>" -H "Range: bytes=0-1023"
+
+curl -X GET "http://localhost:1971/files/<id>/thumbnail?w=200" \
+  -H "Authorization: Bearer <access_token>" -o thumbnail.png
+
+curl -X GET "http://localhost:1971/calendar?from=2025-03-01&to=2025-04-01" \
+  -H "Authorization: Bearer <access_token>"
+
+# Feed per i client di calendario: il token e il path sono restituiti solo alla creazione
+curl -X POST http://localhost:1971/users/<id>/calendar_feeds \
+  -H "Authorization: Bearer <access_token>" -d '{"name":"Phone"}'
+curl -X GET "http://localhost:1971/calendar.ics?token=<feed_token>&folder=<folder_id>"
+
+curl -X POST "http://localhost:1971/calendar/import?father_id=<folder_id>" \
+  -H "Authorization: Bearer <access_token>" -F "file=@holidays.ics"
+
+curl -X POST http://localhost:1971/token/refresh \
+  -H "Content-Type: application/json" \
+  -d '{"refresh_token":"<refresh_token>"}'
+
+curl -X POST http://localhost:1971/logout \
+  -H "Authorization: Bearer <access_token>"
+
 
 Test Suites:
 
@@ -26,18 +63,43 @@ go test -v ./api -run TestLoginHandler
 go clean -testcache
 # Se ho funzioni BenchmarkXxx
 go test -bench ./api
+
+
+Migrations:
+
+go run . migrate status config.json
+go run . migrate dry-run config.json
+go run . migrate up config.json
+
+# Differenze tra le entita' registrate e le tabelle del db: exit code 1 se ce ne sono
+go run . schema check config.json
+
+# Ricostruisce l'indice della ricerca full text
+go run . reindex config.json
+
+Files:
+
+# Rimuove i blob non piu' referenziati e verifica i checksum dei contenuti
+go run . gc dry-run config.json
+go run . gc config.json
 */
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
+	"sort"
 	"strings"
+	"time"
 
 	"rprj/be/api"
 	"rprj/be/db"
+	"rprj/be/dblayer"
 	"rprj/be/models"
+	"rprj/be/storage"
 
 	"github.com/gorilla/mux"
 )
@@ -46,9 +108,40 @@ var AppConfig models.Config
 
 func main() {
 
+	// Usage: be [migrate [status|dry-run|up] | schema check | reindex | gc [dry-run]] [config.json]
 	configFile := "config.json"
-	if len(os.Args) > 1 {
-		configFile = os.Args[1]
+	command := ""
+	migrateAction := "up"
+	schemaAction := ""
+	gcDryRun := false
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "migrate" {
+		command = "migrate"
+		args = args[1:]
+		if len(args) > 0 && !strings.HasSuffix(args[0], ".json") {
+			migrateAction = args[0]
+			args = args[1:]
+		}
+	} else if len(args) > 0 && args[0] == "schema" {
+		command = "schema"
+		args = args[1:]
+		if len(args) > 0 && !strings.HasSuffix(args[0], ".json") {
+			schemaAction = args[0]
+			args = args[1:]
+		}
+	} else if len(args) > 0 && args[0] == "reindex" {
+		command = "reindex"
+		args = args[1:]
+	} else if len(args) > 0 && args[0] == "gc" {
+		command = "gc"
+		args = args[1:]
+		if len(args) > 0 && args[0] == "dry-run" {
+			gcDryRun = true
+			args = args[1:]
+		}
+	}
+	if len(args) > 0 {
+		configFile = args[0]
 	}
 
 	err := models.LoadConfig(configFile, &AppConfig)
@@ -72,12 +165,73 @@ func main() {
 
 	// Passa la config ai pacchetti
 	api.JWTKey = []byte(AppConfig.JWTSecret)
+	if AppConfig.AccessTokenMinutes > 0 {
+		api.AccessTokenDuration = time.Duration(AppConfig.AccessTokenMinutes) * time.Minute
+	}
+	if AppConfig.RefreshTokenDays > 0 {
+		api.RefreshTokenDuration = time.Duration(AppConfig.RefreshTokenDays) * 24 * time.Hour
+	}
 	db.Init(AppConfig.DBUrl, AppConfig.TablePrefix)
 
+	filesDir := AppConfig.FilesDir
+	if filesDir == "" {
+		filesDir = "files"
+	}
+	api.FileStorage = storage.NewLocalStorage(filesDir)
+	if AppConfig.MaxUploadMB > 0 {
+		api.MaxUploadSize = int64(AppConfig.MaxUploadMB) << 20
+	}
+	if len(AppConfig.ThumbnailWidths) > 0 {
+		widths := append([]int{}, AppConfig.ThumbnailWidths...)
+		sort.Ints(widths)
+		api.ThumbnailWidths = widths
+	}
+	if AppConfig.AppName != "" {
+		api.ICSCalendarName = AppConfig.AppName
+	}
+
+	if command == "migrate" {
+		os.Exit(runMigrate(migrateAction))
+	}
+	if command == "schema" {
+		os.Exit(runSchema(schemaAction))
+	}
+	if command == "reindex" {
+		os.Exit(runReindex())
+	}
+	if command == "gc" {
+		os.Exit(runGC(gcDryRun))
+	}
+
+	// The schema must not be newer than this binary
+	runner := db.NewMigrationRunner()
+	if err := runner.Check(); err != nil {
+		log.Fatalf("Refusing to start: %v", err)
+	}
+	if AppConfig.AutoMigrate {
+		if _, err := runner.Up(false); err != nil {
+			log.Fatalf("Error applying migrations: %v", err)
+		}
+	} else if statuses, err := runner.Status(); err == nil {
+		for _, status := range statuses {
+			if len(status.Pending) > 0 {
+				log.Printf("WARNING: model %s has %d pending migrations, run: migrate up", status.ModelName, len(status.Pending))
+			}
+		}
+	}
+
 	db.TestConnection(AppConfig.DBUrl)
 
 	api.OllamaInit(AppConfig.AppName, AppConfig.OllamaURL, AppConfig.OllamaModel)
 
+	// Pulizia periodica dei token scaduti
+	sweepInterval := 60 * time.Minute
+	if AppConfig.TokenSweepMinutes > 0 {
+		sweepInterval = time.Duration(AppConfig.TokenSweepMinutes) * time.Minute
+	}
+	stopSweeper := db.StartTokenSweeper(sweepInterval)
+	defer stopSweeper()
+
 	// Routing
 	r := mux.NewRouter()
 	// remove cors
@@ -85,6 +239,10 @@ func main() {
 
 	// Endpoint pubblico: login
 	r.HandleFunc("/login", api.LoginHandler).Methods("POST")
+	// Endpoint pubblico: rinnovo dei token con il refresh token
+	r.HandleFunc("/token/refresh", api.RefreshTokenHandler).Methods("POST")
+	// Endpoint protected: logout
+	r.Handle("/logout", api.AuthMiddleware(http.HandlerFunc(api.LogoutHandler))).Methods("POST")
 
 	// Endpoint pubblico: hello
 	r.HandleFunc("/ping", api.PingHandler).Methods("GET")
@@ -97,25 +255,219 @@ func main() {
 	r.HandleFunc("/ollama/defaultpage", api.DefaultPageOllamaHandler).Methods("GET")
 
 	// Endpoint protected: CRUD utenti
+	// Autorizzazione per route: gli admin hanno pieno accesso,
+	// un utente puo' modificare solo se stesso ed il proprio gruppo primario
 	userRoutes := r.PathPrefix("/users").Subrouter()
 	userRoutes.Use(api.AuthMiddleware) // applica il middleware
 
-	userRoutes.HandleFunc("/{id}", api.GetUserHandler).Methods("GET")
-	userRoutes.HandleFunc("", api.GetAllUsersHandler).Methods("GET")
-	userRoutes.HandleFunc("", api.CreateUserHandler).Methods("POST")
-	userRoutes.HandleFunc("/{id}", api.UpdateUserHandler).Methods("PUT")
-	userRoutes.HandleFunc("/{id}", api.DeleteUserHandler).Methods("DELETE")
+	userRoutes.Handle("/{id}", api.Authorize(api.Authenticated, api.GetUserHandler)).Methods("GET")
+	userRoutes.Handle("", api.Authorize(api.Authenticated, api.GetAllUsersHandler)).Methods("GET")
+	userRoutes.Handle("", api.Authorize(api.AdminOnly, api.CreateUserHandler)).Methods("POST")
+	userRoutes.Handle("/{id}", api.Authorize(api.AdminOrSelf, api.UpdateUserHandler)).Methods("PUT")
+	userRoutes.Handle("/{id}", api.Authorize(api.AdminOnly, api.DeleteUserHandler)).Methods("DELETE")
+	userRoutes.Handle("/{id}/sessions", api.Authorize(api.AdminOrSelf, api.GetUserSessionsHandler)).Methods("GET")
+	userRoutes.Handle("/{id}/sessions", api.Authorize(api.AdminOrSelf, api.DeleteUserSessionsHandler)).Methods("DELETE")
+	userRoutes.Handle("/{id}/sessions/{token_id}", api.Authorize(api.AdminOrSelf, api.DeleteUserSessionHandler)).Methods("DELETE")
+	userRoutes.Handle("/{id}/calendar_feeds", api.Authorize(api.AdminOrSelf, api.GetCalendarFeedsHandler)).Methods("GET")
+	// Only the user can create their feeds: a feed reads the calendar with their permissions
+	userRoutes.Handle("/{id}/calendar_feeds", api.Authorize(api.Self, api.CreateCalendarFeedHandler)).Methods("POST")
+	userRoutes.Handle("/{id}/calendar_feeds/{feed_id}", api.Authorize(api.AdminOrSelf, api.DeleteCalendarFeedHandler)).Methods("DELETE")
 
 	// Endpoint protected: CRUD gruppi
 	groupRoutes := r.PathPrefix("/groups").Subrouter()
 	groupRoutes.Use(api.AuthMiddleware) // applica il middleware
 
-	groupRoutes.HandleFunc("/{id}", api.GetGroupHandler).Methods("GET")
-	groupRoutes.HandleFunc("", api.GetAllGroupsHandler).Methods("GET")
-	groupRoutes.HandleFunc("", api.CreateGroupHandler).Methods("POST")
-	groupRoutes.HandleFunc("/{id}", api.UpdateGroupHandler).Methods("PUT")
-	groupRoutes.HandleFunc("/{id}", api.DeleteGroupHandler).Methods("DELETE")
+	groupRoutes.Handle("/{id}", api.Authorize(api.Authenticated, api.GetGroupHandler)).Methods("GET")
+	groupRoutes.Handle("", api.Authorize(api.Authenticated, api.GetAllGroupsHandler)).Methods("GET")
+	groupRoutes.Handle("", api.Authorize(api.AdminOnly, api.CreateGroupHandler)).Methods("POST")
+	groupRoutes.Handle("/{id}", api.Authorize(api.AdminOrPrimaryGroup, api.UpdateGroupHandler)).Methods("PUT")
+	groupRoutes.Handle("/{id}", api.Authorize(api.AdminOnly, api.DeleteGroupHandler)).Methods("DELETE")
+
+	// Endpoint protected: trash of the DBObjects
+	trashRoutes := r.PathPrefix("/trash").Subrouter()
+	trashRoutes.Use(api.AuthMiddleware)
+
+	trashRoutes.HandleFunc("", api.GetTrashHandler).Methods("GET")
+
+	// Endpoint pubblico: navigazione dell'albero dei DBObjects (father_id),
+	// gli utenti anonimi vedono solo gli oggetti pubblici
+	navRoutes := r.PathPrefix("/nav").Subrouter()
+	navRoutes.Use(api.OptionalAuthMiddleware)
+
+	navRoutes.HandleFunc("/root", api.NavRootHandler).Methods("GET")
+	navRoutes.HandleFunc("/{id}/children", api.NavChildrenHandler).Methods("GET")
+	navRoutes.HandleFunc("/{id}/breadcrumbs", api.NavBreadcrumbsHandler).Methods("GET")
+	navRoutes.HandleFunc("/{id}/subtree", api.NavSubtreeHandler).Methods("GET")
+
+	// Endpoint pubblico: ricerca full text nei DBObjects
+	r.Handle("/search", api.OptionalAuthMiddleware(http.HandlerFunc(api.SearchHandler))).Methods("GET")
+
+	// Endpoint DBObjects: lettura pubblica degli oggetti pubblici, scrittura solo autenticati (401)
+	objectRoutes := r.PathPrefix("/objects").Subrouter()
+	objectRoutes.Use(api.OptionalAuthMiddleware)
+
+	// Cestino per id: il metodo (POST .../restore, DELETE) li distingue dalle route generiche
+	objectRoutes.Handle("/{id}/restore", api.Authorize(api.Authenticated, api.RestoreObjectHandler)).Methods("POST")
+	objectRoutes.Handle("/{id}", api.Authorize(api.Authenticated, api.DeleteObjectHandler)).Methods("DELETE")
+
+	// CRUD generico delle entita' registrate in db.Factory
+	objectRoutes.Handle("/{classname}", api.Authorize(api.GenericReadAllowed, api.GenericListHandler)).Methods("GET")
+	objectRoutes.Handle("/{classname}", api.Authorize(api.GenericWriteAllowed, api.GenericCreateHandler)).Methods("POST")
+	objectRoutes.Handle("/{classname}/{id}", api.Authorize(api.GenericReadAllowed, api.GenericGetHandler)).Methods("GET")
+	objectRoutes.Handle("/{classname}/{id}", api.Authorize(api.GenericWriteAllowed, api.GenericUpdateHandler)).Methods("PUT")
+	objectRoutes.Handle("/{classname}/{id}", api.Authorize(api.GenericWriteAllowed, api.GenericDeleteHandler)).Methods("DELETE")
+
+	// Endpoint DBFile: upload solo autenticati, download dei file leggibili (anche anonimo per i pubblici)
+	fileRoutes := r.PathPrefix("/files").Subrouter()
+	fileRoutes.Use(api.OptionalAuthMiddleware)
+
+	fileRoutes.Handle("", api.Authorize(api.Authenticated, api.UploadFileHandler)).Methods("POST")
+	fileRoutes.HandleFunc("/{id}/content", api.FileContentHandler).Methods("GET", "HEAD")
+	fileRoutes.HandleFunc("/{id}/thumbnail", api.FileThumbnailHandler).Methods("GET", "HEAD")
+
+	// Endpoint pubblico: calendario, occorrenze dei DBEvent leggibili
+	r.Handle("/calendar", api.OptionalAuthMiddleware(http.HandlerFunc(api.CalendarHandler))).Methods("GET")
+	// Esportazione iCalendar: i client di calendario si autenticano con il token del feed (?token=)
+	r.Handle("/calendar.ics", api.FeedTokenMiddleware(http.HandlerFunc(api.CalendarICSHandler))).Methods("GET")
+	// Endpoint protected: importazione di un file .ics
+	r.Handle("/calendar/import", api.AuthMiddleware(api.Authorize(api.Authenticated, api.ImportCalendarHandler))).Methods("POST")
 
 	log.Println("Server in ascolto su :", AppConfig.ServerPort)
 	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", AppConfig.ServerPort), r))
 }
+
+// Executes the migrate subcommand and returns the exit code
+func runMigrate(action string) int {
+	runner := db.NewMigrationRunner()
+	runner.Verbose = true
+
+	switch action {
+	case "status":
+		statuses, err := runner.Status()
+		if err != nil {
+			log.Printf("Error reading the migrations status: %v", err)
+			return 1
+		}
+		for _, status := range statuses {
+			fmt.Printf("%s: version %d, latest %d\n", status.ModelName, status.CurrentVersion, status.LatestVersion)
+			for _, m := range status.Pending {
+				fmt.Printf("  pending %d: %s\n", m.Version, m.Description)
+			}
+		}
+		if err := runner.Check(); err != nil {
+			fmt.Println(err)
+			return 1
+		}
+	case "up", "dry-run":
+		applied, err := runner.Up(action == "dry-run")
+		for _, m := range applied {
+			fmt.Printf("%s version %d: %s\n", m.ModelName, m.Version, m.Description)
+		}
+		if err != nil {
+			log.Printf("Error applying migrations: %v", err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("Nothing to migrate")
+		}
+	default:
+		log.Printf("Unknown migrate action: %s (use status, dry-run or up)", action)
+		return 2
+	}
+	return 0
+}
+
+/*
+Executes the schema subcommand and returns the exit code:
+check prints the differences between the registered entities and the db, 1 if there are any
+*/
+func runSchema(action string) int {
+	if action != "check" {
+		log.Printf("Unknown schema action: '%s' (use check)", action)
+		return 2
+	}
+	drifts, err := db.NewDBRepository(context.Background()).CheckSchema()
+	if err != nil {
+		log.Printf("Error reading the schema: %v", err)
+		return 1
+	}
+	return printSchemaDrift(os.Stdout, drifts)
+}
+
+// Prints the differences, returns 1 if there are any
+func printSchemaDrift(w io.Writer, drifts []dblayer.SchemaDrift) int {
+	for _, drift := range drifts {
+		fmt.Fprintln(w, drift)
+	}
+	if len(drifts) > 0 {
+		fmt.Fprintf(w, "%d differences\n", len(drifts))
+		return 1
+	}
+	fmt.Fprintln(w, "Schema up to date")
+	return 0
+}
+
+// Executes the reindex subcommand: rebuilds the full text search index in a transaction
+func runReindex() int {
+	repo := db.NewDBRepository(context.Background())
+	tx, err := repo.DbConnection.BeginTx(repo.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting the transaction: %v", err)
+		return 1
+	}
+	defer tx.Rollback()
+
+	count, err := repo.RebuildSearchIndex(tx)
+	if err != nil {
+		log.Printf("Error rebuilding the search index: %v", err)
+		return 1
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error rebuilding the search index: %v", err)
+		return 1
+	}
+	fmt.Printf("%d objects indexed\n", count)
+	return 0
+}
+
+/*
+Executes the gc subcommand: removes the orphan contents of the file storage
+and reports the files whose content is missing or does not match the checksum.
+Returns 1 if there are such files.
+*/
+func runGC(dryRun bool) int {
+	refs, err := db.FileReferences()
+	if err != nil {
+		log.Printf("Error reading the files: %v", err)
+		return 1
+	}
+
+	report, err := storage.CollectGarbage(api.FileStorage, refs, dryRun)
+	if err != nil {
+		log.Printf("Error collecting the orphan contents: %v", err)
+		return 1
+	}
+	var removedBytes int64
+	for _, info := range report.Removed {
+		if dryRun {
+			fmt.Printf("to remove %s (%d bytes)\n", info.Key, info.Size)
+		} else {
+			fmt.Printf("removed %s (%d bytes)\n", info.Key, info.Size)
+		}
+		removedBytes += info.Size
+	}
+	fmt.Printf("%d files, %d contents referenced, %d orphans (%d bytes)\n", len(refs), report.Blobs, len(report.Removed), removedBytes)
+
+	problems, err := storage.Verify(api.FileStorage, refs)
+	if err != nil {
+		log.Printf("Error verifying the contents: %v", err)
+		return 1
+	}
+	for _, problem := range problems {
+		fmt.Printf("file %s: %s: %s\n", problem.FileID, problem.Key, problem.Message)
+	}
+	if len(problems) > 0 {
+		return 1
+	}
+	return 0
+}
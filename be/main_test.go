@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"rprj/be/dblayer"
+)
+
+func TestPrintSchemaDrift(t *testing.T) {
+	var out bytes.Buffer
+	if code := printSchemaDrift(&out, []dblayer.SchemaDrift{}); code != 0 {
+		t.Error("expected exit code 0 without differences, got", code)
+	}
+	if !strings.Contains(out.String(), "up to date") {
+		t.Error("unexpected output:", out.String())
+	}
+
+	out.Reset()
+	drifts := []dblayer.SchemaDrift{
+		{Table: "rprj_notes", Problem: "missing_table"},
+		{Table: "rprj_users", Column: "login", Problem: "type", Expected: "varchar(255)", Actual: "varchar(50)"},
+	}
+	if code := printSchemaDrift(&out, drifts); code != 1 {
+		t.Error("expected exit code 1 with differences, got", code)
+	}
+	for _, expected := range []string{"rprj_notes: missing_table", "rprj_users.login: type (expected 'varchar(255)', found 'varchar(50)')", "2 differences"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected '%s' in the output: %s", expected, out.String())
+		}
+	}
+}
+
+func TestRunSchemaUnknownAction(t *testing.T) {
+	for _, action := range []string{"", "fix"} {
+		if code := runSchema(action); code != 2 {
+			t.Errorf("schema '%s': expected exit code 2, got %d", action, code)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"os"
 )
@@ -16,6 +17,16 @@ type Config struct {
 	LogLevel    string `json:"log_level"`
 	OllamaModel string `json:"ollama_model"`
 	OllamaURL   string `json:"ollama_url"`
+	AutoMigrate bool   `json:"auto_migrate"` // apply the pending db migrations at startup
+
+	AccessTokenMinutes int `json:"access_token_minutes"` // default 60
+	RefreshTokenDays   int `json:"refresh_token_days"`   // default 30
+	TokenSweepMinutes  int `json:"token_sweep_minutes"`  // purge of the expired tokens, default 60
+
+	FilesDir    string `json:"files_dir"`     // contents of the DBFiles, default "files"
+	MaxUploadMB int    `json:"max_upload_mb"` // default 100
+
+	ThumbnailWidths []int `json:"thumbnail_widths"` // default [64, 200, 800]
 }
 
 func LoadConfig(filename string, config *Config) error {
@@ -31,5 +42,20 @@ func LoadConfig(filename string, config *Config) error {
 	}
 	// Implementazione per caricare la configurazione da un file JSON
 	// (omessa per brevità)
-	return err
+	return config.Validate()
+}
+
+// Validate checks the values that cannot be fixed with a default
+func (config *Config) Validate() error {
+	seen := make(map[int]bool)
+	for _, width := range config.ThumbnailWidths {
+		if width <= 0 {
+			return fmt.Errorf("thumbnail_widths: invalid width %d", width)
+		}
+		if seen[width] {
+			return fmt.Errorf("thumbnail_widths: duplicate width %d", width)
+		}
+		seen[width] = true
+	}
+	return nil
 }
@@ -0,0 +1,18 @@
+package models
+
+import "testing"
+
+func TestConfigValidate(t *testing.T) {
+	for _, widths := range [][]int{nil, {200}, {800, 64, 200}} {
+		config := Config{ThumbnailWidths: widths}
+		if err := config.Validate(); err != nil {
+			t.Errorf("%v: %v", widths, err)
+		}
+	}
+	for _, widths := range [][]int{{0}, {64, -200}, {200, 64, 200}} {
+		config := Config{ThumbnailWidths: widths}
+		if err := config.Validate(); err == nil {
+			t.Errorf("%v: expected an error", widths)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 /** *********************************** RRA Framework: start. *********************************** */
 
 /*
@@ -80,6 +82,27 @@ type DBOAuthToken struct {
 	CreatedAt    string // DATETIME in formato stringa
 }
 
+// Sessione di un utente: una coppia di token non ancora ruotata.
+// ID non e' il token, ma un suo hash: il token non deve uscire dal db.
+type Session struct {
+	ID               string     `json:"token_id"`
+	CreatedAt        *time.Time `json:"created_at"`
+	ExpiresAt        *time.Time `json:"expires_at"`
+	RefreshExpiresAt *time.Time `json:"refresh_expires_at"`
+	LastSeen         *time.Time `json:"last_seen"`
+	Current          bool       `json:"current"`
+}
+
+// Feed di calendario di un utente: il token compare solo nella risposta della creazione.
+type CalendarFeed struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	CreatedAt *time.Time `json:"created_at"`
+	LastUsed  *time.Time `json:"last_used"`
+	Token     string     `json:"token,omitempty"`
+	Path      string     `json:"path,omitempty"` // es. /calendar.ics?token=..., relativo all'API
+}
+
 /*
 CREATE TABLE IF NOT EXISTS `rra_log` (
 
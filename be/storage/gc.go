@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"time"
+)
+
+/*
+Maintenance of the storage, see the gc command.
+The blobs are reference counted by the rows of the files table, the deleted ones too
+(they can be restored): a content without references is an orphan.
+*/
+
+// The contents younger than this are kept also if orphans: their upload can be in progress
+var GracePeriod = time.Hour
+
+// The content of a DBFile
+type Reference struct {
+	FileID   string
+	Key      string
+	Checksum string
+}
+
+// A DBFile whose content is missing or does not match its checksum
+type Problem struct {
+	Reference
+	Message string
+}
+
+type GCReport struct {
+	Blobs    int    // contents referenced
+	Removed  []Info // orphans removed (to remove with dryRun)
+	Problems []Problem
+}
+
+/*
+Counts the references of each key
+*/
+func CountReferences(refs []Reference) map[string]int {
+	ret := make(map[string]int)
+	for _, ref := range refs {
+		ret[ref.Key]++
+	}
+	return ret
+}
+
+/*
+Returns true if the key is a thumbnail (see api.FileThumbnailHandler) of one of the checksums
+*/
+func isThumbnailOf(key string, checksums map[string]bool) bool {
+	parts := strings.Split(key, "/")
+	return len(parts) == 3 && parts[0] == "thumbnails" && checksums[parts[1]]
+}
+
+/*
+Removes the contents not referenced by refs, older than GracePeriod:
+the blobs, the thumbnails of removed blobs and the interrupted uploads.
+With dryRun nothing is removed.
+*/
+func CollectGarbage(store Storage, refs []Reference, dryRun bool) (*GCReport, error) {
+	counts := CountReferences(refs)
+	checksums := make(map[string]bool)
+	for _, ref := range refs {
+		if ref.Checksum != "" {
+			checksums[strings.ToLower(ref.Checksum)] = true
+		}
+	}
+
+	report := &GCReport{Blobs: len(counts)}
+	limit := time.Now().Add(-GracePeriod)
+	err := store.Walk("", func(info Info) error {
+		if counts[info.Key] > 0 || isThumbnailOf(info.Key, checksums) || info.ModTime.After(limit) {
+			return nil
+		}
+		report.Removed = append(report.Removed, info)
+		if dryRun {
+			return nil
+		}
+		return store.Delete(info.Key)
+	})
+	if err != nil {
+		log.Print("storage::CollectGarbage: ", err)
+		return report, err
+	}
+	return report, nil
+}
+
+/*
+Reads the content of each reference (once per key) checking its SHA-1
+*/
+func Verify(store Storage, refs []Reference) ([]Problem, error) {
+	problems := make([]Problem, 0)
+	actuals := make(map[string]string) // key -> SHA-1 of the content, "" if missing
+	for _, ref := range refs {
+		actual, done := actuals[ref.Key]
+		if !done {
+			var err error
+			actual, err = checksumOf(store, ref.Key)
+			if errors.Is(err, ErrNotFound) || errors.Is(err, ErrInvalidKey) {
+				actual = ""
+			} else if err != nil {
+				return problems, err
+			}
+			actuals[ref.Key] = actual
+		}
+		switch {
+		case actual == "":
+			problems = append(problems, Problem{Reference: ref, Message: "missing content"})
+		case ref.Checksum != "" && !strings.EqualFold(actual, ref.Checksum):
+			problems = append(problems, Problem{Reference: ref, Message: "checksum mismatch: content is " + actual})
+		}
+	}
+	return problems, nil
+}
+
+func checksumOf(store Storage, key string) (string, error) {
+	content, err := store.Open(key)
+	if err != nil {
+		return "", err
+	}
+	defer content.Close()
+	hash := sha1.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollectGarbage(t *testing.T) {
+	ls := NewLocalStorage(t.TempDir())
+	abc := "a9993e364706816aba3e25717850c26c9cd0d89d" // sha1 of "abc"
+	ls.Put(BlobKey(abc), strings.NewReader("abc"))
+	ls.Put("thumbnails/"+abc+"/200", strings.NewReader("thumb"))
+	ls.Put("blobs/00/orphan", strings.NewReader("orphan"))
+	ls.Put("thumbnails/orphan/200", strings.NewReader("thumb"))
+	ls.Put("tmp/interrupted", strings.NewReader("partial"))
+	ls.Put("blobs/00/recent", strings.NewReader("uploading"))
+	old := time.Now().Add(-2 * GracePeriod)
+	for _, key := range []string{BlobKey(abc), "thumbnails/" + abc + "/200", "blobs/00/orphan", "thumbnails/orphan/200", "tmp/interrupted"} {
+		os.Chtimes(filepath.Join(ls.Root, filepath.FromSlash(key)), old, old)
+	}
+
+	refs := []Reference{
+		{FileID: "f1", Key: BlobKey(abc), Checksum: abc},
+		{FileID: "f2", Key: BlobKey(abc), Checksum: abc},
+	}
+	if counts := CountReferences(refs); counts[BlobKey(abc)] != 2 {
+		t.Errorf("got %v", counts)
+	}
+
+	report, err := CollectGarbage(ls, refs, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Removed) != 3 || report.Blobs != 1 {
+		t.Errorf("dry run: got %+v", report)
+	}
+	if _, err := ls.Open("blobs/00/orphan"); err != nil {
+		t.Error("dry run must not remove")
+	}
+
+	if _, err := CollectGarbage(ls, refs, false); err != nil {
+		t.Fatal(err)
+	}
+	keys := []string{}
+	ls.Walk("", func(info Info) error {
+		keys = append(keys, info.Key)
+		return nil
+	})
+	expected := "blobs/00/recent," + BlobKey(abc) + ",thumbnails/" + abc + "/200"
+	if strings.Join(keys, ",") != expected {
+		t.Errorf("got %v, want %s", keys, expected)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	ls := NewLocalStorage(t.TempDir())
+	abc := "a9993e364706816aba3e25717850c26c9cd0d89d"
+	ls.Put(BlobKey(abc), strings.NewReader("abc"))
+	ls.Put("2025/10/legacy", strings.NewReader("changed on disk"))
+
+	problems, err := Verify(ls, []Reference{
+		{FileID: "ok", Key: BlobKey(abc), Checksum: abc},
+		{FileID: "wrong", Key: BlobKey(abc), Checksum: "0000000000000000000000000000000000000000"},
+		{FileID: "changed", Key: "2025/10/legacy", Checksum: abc},
+		{FileID: "missing", Key: "blobs/00/missing", Checksum: abc},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, p := range problems {
+		ids = append(ids, p.FileID)
+	}
+	if strings.Join(ids, ",") != "wrong,changed,missing" {
+		t.Errorf("got %+v", problems)
+	}
+}
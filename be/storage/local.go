@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+/*
+Storage on the local filesystem, under Root
+*/
+type LocalStorage struct {
+	Root string
+}
+
+func NewLocalStorage(root string) *LocalStorage {
+	return &LocalStorage{Root: root}
+}
+
+func (ls *LocalStorage) filename(key string) (string, error) {
+	if err := ValidateKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(ls.Root, filepath.FromSlash(key)), nil
+}
+
+/*
+Writes into a temporary file of the same directory, then renames it:
+the readers never see a partial content.
+*/
+func (ls *LocalStorage) Put(key string, r io.Reader) (int64, error) {
+	filename, err := ls.filename(key)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0o750); err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(filename), ".upload-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name()) // no-op after the rename
+
+	n, err := io.Copy(tmp, r)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Print("LocalStorage::Put: ", key, ": ", err)
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+type localContent struct {
+	*os.File
+	info fs.FileInfo
+}
+
+func (c *localContent) Size() int64        { return c.info.Size() }
+func (c *localContent) ModTime() time.Time { return c.info.ModTime() }
+
+func (ls *LocalStorage) Open(key string) (Content, error) {
+	filename, err := ls.filename(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		file.Close()
+		return nil, ErrNotFound
+	}
+	return &localContent{File: file, info: info}, nil
+}
+
+func (ls *LocalStorage) Delete(key string) error {
+	filename, err := ls.filename(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filename); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (ls *LocalStorage) Move(from string, to string) error {
+	fromFilename, err := ls.filename(from)
+	if err != nil {
+		return err
+	}
+	toFilename, err := ls.filename(to)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(toFilename), 0o750); err != nil {
+		return err
+	}
+	err = os.Rename(fromFilename, toFilename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
+/*
+Walks the files under Root: the temporary files of Put (".upload-*") are skipped
+*/
+func (ls *LocalStorage) Walk(prefix string, fn func(info Info) error) error {
+	err := filepath.WalkDir(ls.Root, func(filename string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if filename == ls.Root && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".upload-") {
+			return nil
+		}
+		rel, err := filepath.Rel(ls.Root, filename)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		return fn(Info{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+	})
+	return err
+}
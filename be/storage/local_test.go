@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateKey(t *testing.T) {
+	valid := []string{"a", "2025/10/3f2a9c1b7e4d5a60", "blobs/ab/abcdef.txt"}
+	for _, key := range valid {
+		if err := ValidateKey(key); err != nil {
+			t.Errorf("%q: unexpected error %v", key, err)
+		}
+	}
+	invalid := []string{"", "/etc/passwd", "../x", "a/../../x", "a//b", "a/./b", "a/", `a\b`, ".."}
+	for _, key := range invalid {
+		if err := ValidateKey(key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("%q: expected ErrInvalidKey, got %v", key, err)
+		}
+	}
+}
+
+func TestLocalStoragePutOpenDelete(t *testing.T) {
+	ls := NewLocalStorage(t.TempDir())
+
+	n, err := ls.Put("2025/10/abc", strings.NewReader("hello world"))
+	if err != nil || n != 11 {
+		t.Fatalf("Put: n=%d err=%v", n, err)
+	}
+	// Replaced atomically, no temporary files left
+	if _, err := ls.Put("2025/10/abc", strings.NewReader("hello")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	entries, _ := os.ReadDir(filepath.Join(ls.Root, "2025", "10"))
+	if len(entries) != 1 {
+		t.Errorf("expected 1 file, got %d", len(entries))
+	}
+
+	content, err := ls.Open("2025/10/abc")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	b, _ := io.ReadAll(content)
+	content.Close()
+	if string(b) != "hello" || content.Size() != 5 {
+		t.Errorf("got %q size %d", b, content.Size())
+	}
+
+	if err := ls.Delete("2025/10/abc"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := ls.Delete("2025/10/abc"); err != nil {
+		t.Errorf("Delete of a missing key: %v", err)
+	}
+	if _, err := ls.Open("2025/10/abc"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := ls.Open("2025/10"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("directory: expected ErrNotFound, got %v", err)
+	}
+	if _, err := ls.Put("../outside", strings.NewReader("x")); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("expected ErrInvalidKey, got %v", err)
+	}
+}
+
+func TestBlobKey(t *testing.T) {
+	if key := BlobKey("DA39A3EE5E6B4B0D3255BFEF95601890AFD80709"); key != "blobs/da/da39a3ee5e6b4b0d3255bfef95601890afd80709" {
+		t.Errorf("got %s", key)
+	}
+}
+
+func TestLocalStorageMoveWalk(t *testing.T) {
+	ls := NewLocalStorage(filepath.Join(t.TempDir(), "files"))
+	if err := ls.Walk("", func(info Info) error { return nil }); err != nil {
+		t.Errorf("Walk of a missing root: %v", err)
+	}
+
+	ls.Put("tmp/upload1", strings.NewReader("abc"))
+	ls.Put("thumbnails/x/64", strings.NewReader("thumb"))
+	if err := ls.Move("tmp/upload1", "blobs/a9/a9993e"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ls.Move("tmp/upload1", "blobs/a9/a9993e"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	keys := []string{}
+	ls.Walk("", func(info Info) error {
+		keys = append(keys, info.Key)
+		return nil
+	})
+	if strings.Join(keys, ",") != "blobs/a9/a9993e,thumbnails/x/64" {
+		t.Errorf("got %v", keys)
+	}
+	keys = keys[:0]
+	ls.Walk(BlobsPrefix, func(info Info) error {
+		keys = append(keys, info.Key)
+		if info.Size != 3 {
+			t.Errorf("%s: size %d", info.Key, info.Size)
+		}
+		return nil
+	})
+	if len(keys) != 1 {
+		t.Errorf("got %v", keys)
+	}
+}
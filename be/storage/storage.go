@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+/*
+Storage of the contents of the DBFiles: the DBFile keeps in its path the key of its content.
+The keys are relative slash separated paths, ie. "blobs/3f/3f2a9c1b...".
+
+The contents are stored by checksum (see BlobKey): the files with the same content
+share the same blob. The blobs no longer referenced by any file are removed by the gc command.
+*/
+
+var ErrNotFound = errors.New("content not found")
+var ErrInvalidKey = errors.New("invalid storage key")
+
+/*
+A content opened for reading: seekable, so it can serve the Range requests
+*/
+type Content interface {
+	io.ReadSeekCloser
+	Size() int64
+	ModTime() time.Time
+}
+
+type Storage interface {
+	// Writes the content of r under key, replacing it atomically. Returns the bytes written
+	Put(key string, r io.Reader) (int64, error)
+	// Returns ErrNotFound if the key does not exist
+	Open(key string) (Content, error)
+	// Deleting a missing key is not an error
+	Delete(key string) error
+	// Renames from to to, replacing to atomically
+	Move(from string, to string) error
+	// Calls fn for each key under prefix ("" for all), in lexical order
+	Walk(prefix string, fn func(info Info) error) error
+}
+
+type Info struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Prefix of the blobs, see BlobKey
+const BlobsPrefix = "blobs/"
+
+// Prefix of the contents being uploaded
+const TmpPrefix = "tmp/"
+
+/*
+Returns the key of the blob with the given SHA-1 (hex): "blobs/3f/3f2a9c1b..."
+*/
+func BlobKey(checksum string) string {
+	checksum = strings.ToLower(checksum)
+	if len(checksum) < 2 {
+		return BlobsPrefix + checksum
+	}
+	return BlobsPrefix + checksum[:2] + "/" + checksum
+}
+
+/*
+Checks that the key is a clean relative path: no "..", no absolute paths, no empty segments
+*/
+func ValidateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, `\`) || path.Clean(key) != key {
+		return ErrInvalidKey
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "." || segment == ".." {
+			return ErrInvalidKey
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package thumbnail
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+/*
+Thumbnails of the images of the DBFiles: decoded with the standard library (and x/image for webp),
+scaled keeping the aspect ratio, never enlarged.
+The JPEG images give JPEG thumbnails, the others PNG (to keep the transparency).
+*/
+
+var ErrUnsupported = errors.New("unsupported image type")
+var ErrTooLarge = errors.New("image too large")
+
+// Images with more pixels are not decoded: a small file can declare huge dimensions
+var MaxPixels = 50_000_000
+
+var JPEGQuality = 85
+
+type decoder struct {
+	decode       func(io.Reader) (image.Image, error)
+	decodeConfig func(io.Reader) (image.Config, error)
+}
+
+var decoders = map[string]decoder{
+	"image/jpeg": {jpeg.Decode, jpeg.DecodeConfig},
+	"image/png":  {png.Decode, png.DecodeConfig},
+	"image/gif":  {gif.Decode, gif.DecodeConfig},
+	"image/webp": {webp.Decode, webp.DecodeConfig},
+}
+
+// True if thumbnails can be made from images of the MIME type
+func Supported(mimeType string) bool {
+	_, ok := decoders[mimeType]
+	return ok
+}
+
+// The MIME type of the thumbnails of the images of the given type
+func ContentType(mimeType string) string {
+	if mimeType == "image/jpeg" {
+		return "image/jpeg"
+	}
+	return "image/png"
+}
+
+/*
+Returns the size of the thumbnail of a width x height image: at most maxWidth wide,
+with the same aspect ratio
+*/
+func Size(width int, height int, maxWidth int) (int, int) {
+	if width <= maxWidth || width <= 0 {
+		return width, height
+	}
+	h := (height*maxWidth + width/2) / width
+	if h < 1 {
+		h = 1
+	}
+	return maxWidth, h
+}
+
+/*
+Reads the image of the given MIME type from r (seekable: the header is read first to check
+the dimensions) and writes its thumbnail, at most maxWidth wide, to w.
+For the animated GIFs the first frame is used.
+*/
+func Generate(r io.ReadSeeker, mimeType string, maxWidth int, w io.Writer) error {
+	dec, ok := decoders[mimeType]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnsupported, mimeType)
+	}
+	config, err := dec.decodeConfig(r)
+	if err != nil {
+		return err
+	}
+	if config.Width*config.Height > MaxPixels {
+		return fmt.Errorf("%w: %dx%d", ErrTooLarge, config.Width, config.Height)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	src, err := dec.decode(r)
+	if err != nil {
+		return err
+	}
+
+	bounds := src.Bounds()
+	width, height := Size(bounds.Dx(), bounds.Dy(), maxWidth)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	if width == bounds.Dx() && height == bounds.Dy() {
+		draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Src)
+	} else {
+		xdraw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+	}
+
+	if ContentType(mimeType) == "image/jpeg" {
+		return jpeg.Encode(w, dst, &jpeg.Options{Quality: JPEGQuality})
+	}
+	return png.Encode(w, dst)
+}
@@ -0,0 +1,77 @@
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func TestSize(t *testing.T) {
+	cases := []struct{ w, h, max, ew, eh int }{
+		{800, 600, 200, 200, 150},
+		{600, 800, 200, 200, 267},
+		{100, 50, 200, 100, 50}, // never enlarged
+		{1000, 1, 200, 200, 1},
+	}
+	for _, c := range cases {
+		if w, h := Size(c.w, c.h, c.max); w != c.ew || h != c.eh {
+			t.Errorf("Size(%d, %d, %d) = %d, %d, want %d, %d", c.w, c.h, c.max, w, h, c.ew, c.eh)
+		}
+	}
+}
+
+func testImage(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.NRGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	return img
+}
+
+func TestGenerate(t *testing.T) {
+	var src bytes.Buffer
+	png.Encode(&src, testImage(400, 300))
+
+	var out bytes.Buffer
+	if err := Generate(bytes.NewReader(src.Bytes()), "image/png", 100, &out); err != nil {
+		t.Fatal(err)
+	}
+	thumb, format, err := image.Decode(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "png" || thumb.Bounds().Dx() != 100 || thumb.Bounds().Dy() != 75 {
+		t.Errorf("got %s %v", format, thumb.Bounds())
+	}
+
+	src.Reset()
+	jpeg.Encode(&src, testImage(50, 40), nil)
+	out.Reset()
+	if err := Generate(bytes.NewReader(src.Bytes()), "image/jpeg", 100, &out); err != nil {
+		t.Fatal(err)
+	}
+	thumb, format, err = image.Decode(&out)
+	if err != nil || format != "jpeg" || thumb.Bounds().Dx() != 50 {
+		t.Errorf("got %s %v %v", format, thumb, err)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	if err := Generate(bytes.NewReader([]byte("%PDF")), "application/pdf", 100, &bytes.Buffer{}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+
+	var src bytes.Buffer
+	png.Encode(&src, testImage(100, 100))
+	defer func(max int) { MaxPixels = max }(MaxPixels)
+	MaxPixels = 100
+	if err := Generate(bytes.NewReader(src.Bytes()), "image/png", 50, &bytes.Buffer{}); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+}
@@ -36,11 +36,11 @@ function Users() {
     try {
       const res = await api.get(
         search
-          ? `/users?search=${encodeURIComponent(search)}&order_by=login`
-          : "/users?order_by=login",
+          ? `/users?search=${encodeURIComponent(search)}&order_by=login&page_size=500`
+          : "/users?order_by=login&page_size=500",
         { headers: { Authorization: `Bearer ${token}` }, }
       );
-      setUsers(res.data || []); // supponendo che l'API restituisca un array
+      setUsers(res.data.items || []); // {items, total, next_cursor}
     } catch (err) {
       console.log("Error loading users.");
     }
@@ -49,10 +49,10 @@ function Users() {
   const fetchGroups = async () => {
     const token = localStorage.getItem("token");
     try {
-      const res = await api.get("/groups", {
+      const res = await api.get("/groups?page_size=500", {
         headers: { Authorization: `Bearer ${token}` },
       });
-      setGroups(res.data || []);
+      setGroups(res.data.items || []);
     } catch (err) {
       console.log("Error loading groups.");
     }
@@ -34,11 +34,11 @@ function Groups() {
     try {
       const res = await api.get(
         search
-          ? `/groups?search=${encodeURIComponent(search)}`
-          : "/groups",
+          ? `/groups?search=${encodeURIComponent(search)}&page_size=500`
+          : "/groups?page_size=500",
         { headers: { Authorization: `Bearer ${token}` }, }
       );
-      setGroups(res.data || []); // supponendo che l'API restituisca un array
+      setGroups(res.data.items || []); // {items, total, next_cursor}
     } catch (err) {
       console.log("Error loading groups.");
     }
@@ -47,10 +47,10 @@ function Groups() {
   const fetchUsers = async () => {
     const token = localStorage.getItem("token");
     try {
-      const res = await api.get("/users", {
+      const res = await api.get("/users?order_by=login&page_size=500", {
         headers: { Authorization: `Bearer ${token}` },
       });
-      setUsers(res.data || []);
+      setUsers(res.data.items || []);
     } catch (err) {
       console.log("Error loading users.");
     }